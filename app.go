@@ -1,16 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
+	"encoding/base64"
 	"fmt"
 	"runtime"
 	"ts-escpos/backend/config"
 	"ts-escpos/backend/receipt"
 	"ts-escpos/backend/tray"
 
+	"ts-escpos/backend/discovery"
 	"ts-escpos/backend/jobs"
 	"ts-escpos/backend/printer"
+	"ts-escpos/backend/printer/emulator"
 	"ts-escpos/backend/server"
 	"ts-escpos/backend/updater"
 
@@ -40,6 +44,12 @@ type App struct {
 // NewApp creates a new App application struct
 func NewApp() *App {
 	cfg := config.LoadConfig()
+	if len(cfg.Fonts) > 0 {
+		printer.RegisterFontFiles(cfg.Fonts)
+	}
+	if len(cfg.Profiles) > 0 {
+		printer.RegisterProfiles(cfg.Profiles)
+	}
 	store := jobs.NewStore()
 	srv := server.NewServer(store, cfg)
 	t := tray.NewTrayApp(appIcon)
@@ -91,7 +101,29 @@ func (a *App) Greet(name string) string {
 
 func (a *App) GetPrinters() ([]printer.PrinterInfo, error) {
 	a.Log("Fetching printer list...")
-	return printer.GetPrinters()
+	configs := a.server.PrinterConfigs()
+	printers, err := printer.ListPrinters(configs)
+	a.server.RefreshStatus()
+	roles := a.server.Roles()
+	for i, p := range printers {
+		printers[i] = server.WithRoles(p, roles)
+		printers[i].Profile = printer.ProfileFor(p, configs[p.Name].Profile)
+		if st, ok := a.server.DeviceStatus(p.Name); ok {
+			printers[i].SetDeviceStatus(st)
+		}
+	}
+	return printers, err
+}
+
+// DiscoverPrinters scans the local network for printers.
+func (a *App) DiscoverPrinters() ([]discovery.Device, error) {
+	a.Log("Scanning the network for printers...")
+	return a.server.Discover(a.ctx, discovery.Options{})
+}
+
+// AddDiscoveredPrinter saves a printer found by DiscoverPrinters under a name.
+func (a *App) AddDiscoveredPrinter(name, address string) error {
+	return a.server.AddPrinter(name, printer.Settings{Address: address})
 }
 
 func (a *App) GetPrintJobs() []jobs.PrintJob {
@@ -101,6 +133,20 @@ func (a *App) GetPrintJobs() []jobs.PrintJob {
 	return a.store.GetJobs()
 }
 
+// GetJobPreview renders what was sent for one of the recent jobs as a PNG
+// data URI, for the job log.
+func (a *App) GetJobPreview(jobID string) (string, error) {
+	out, ok := a.store.GetOutput(jobID)
+	if !ok {
+		return "", fmt.Errorf("no preview kept for job %s", jobID)
+	}
+	var png bytes.Buffer
+	if err := emulator.RenderPNG(&png, out.Data, emulator.Options{DotWidth: out.DotWidth, CodePage: out.CodePage}); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png.Bytes()), nil
+}
+
 // Helper to log from App
 func (a *App) Log(msg string) {
 	if a.ctx != nil {
@@ -176,7 +222,8 @@ func (a *App) GetServerStatus() map[string]interface{} {
 }
 
 func (a *App) TestPrint(printerName string) error {
-	printers, err := printer.GetPrinters()
+	configs := a.server.PrinterConfigs()
+	printers, err := printer.ListPrinters(configs)
 	if err != nil {
 		return fmt.Errorf("failed to get printers: %w", err)
 	}
@@ -202,19 +249,26 @@ func (a *App) TestPrint(printerName string) error {
 		// Let's just proceed.
 	}
 
-	adapter := printer.NewEscposAdapter()
-	// Pass the printer name to the adapter so it knows where to print
-	// adapter.SetPrinterName(printerName)
+	settings := configs[printerName]
+	settings.Profile = printer.ProfileFor(selectedPrinter, settings.Profile)
+	if settings.Paper == "" {
+		settings.Paper = "80mm" // Defaulting to 80mm for test
+	}
+	adapter := printer.NewEscposAdapterWithSettings(settings)
 	fmt.Printf("TestPrint: Generating sample receipt for %s\n", printerName)
 
 	sampleData := receipt.GetSampleOrderData()
-	receipt.RenderBill(adapter, sampleData, "80mm") // Defaulting to 80mm for test
+	receipt.RenderBill(adapter, sampleData)
 
 	fmt.Printf("TestPrint: Sending %d bytes to printer\n", len(adapter.GetBytes()))
-	return printer.PrintRaw(a.ctx, printerName, adapter.GetBytes())
+	_, err = a.server.Send(printerName, adapter.GetBytes())
+	return err
 }
 
 func (a *App) ClearPrinterQueue(printerName string) error {
 	fmt.Printf("ClearPrinterQueue: Clearing queue for %s\n", printerName)
+	if addr := a.server.PrinterConfigs()[printerName].Address; addr != "" {
+		return fmt.Errorf("printer '%s' is reached at %s and has no print queue", printerName, addr)
+	}
 	return printer.ClearPrinterQueue(a.ctx, printerName)
 }
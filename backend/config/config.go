@@ -5,11 +5,28 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"ts-escpos/backend/kitchen"
+	"ts-escpos/backend/printer"
 )
 
 type Config struct {
-	HTTPPort    int      `json:"httpPort"`
-	AllowedCors []string `json:"allowedCors"`
+	HTTPPort    int                         `json:"httpPort"`
+	AllowedCors []string                    `json:"allowedCors"`
+	Printers    map[string]printer.Settings `json:"printers,omitempty"`    // Keyed by printer name
+	Fonts       []string                    `json:"fonts,omitempty"`       // Extra font files for raster text
+	Profiles    []printer.Profile           `json:"profiles,omitempty"`    // Added to (or replacing) the built-in printer profiles
+	Stations    []kitchen.Station           `json:"stations,omitempty"`    // Kitchen stations KOT items are routed to
+	ExpoPrinter string                      `json:"expoPrinter,omitempty"` // Gets the whole KOT as well when stations are set
+	Roles       map[string]Role             `json:"roles,omitempty"`       // Logical printer names (e.g. "bill", "bar") the POS prints to
+	DefaultRole string                      `json:"defaultRole,omitempty"` // Role used when a request names no known printer or role
+}
+
+// Role maps a logical printer name to a physical printer on this machine,
+// so the POS need not know each machine's queue names.
+type Role struct {
+	Printer  string `json:"printer"`            // OS queue, or a printer named in "printers"
+	Fallback string `json:"fallback,omitempty"` // Used while Printer is not available
 }
 
 var (
@@ -43,6 +60,11 @@ func init() {
 	}
 }
 
+// Dir returns the directory holding config.json and the app's other files.
+func Dir() string {
+	return configDir
+}
+
 func LoadConfig() *Config {
 	mu.Lock()
 	defer mu.Unlock()
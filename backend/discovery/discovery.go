@@ -0,0 +1,148 @@
+// Package discovery finds printers on the local network: a scan of the
+// machine's subnets for printing ports and a DNS-SD browse over mDNS, with
+// the model read back from receipt printers that answer ESC/POS queries.
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Printing ports looked for by the scan.
+const (
+	PortRaw = 9100 // Raw socket (JetDirect), the port ESC/POS printers listen on
+	PortLPD = 515
+	PortIPP = 631
+)
+
+// Device is a printer found on the network.
+type Device struct {
+	Host    string   `json:"host"`
+	Address string   `json:"address,omitempty"` // "tcp://host:port" of the raw port of an ESC/POS printer, usable as a printer address
+	Ports   []int    `json:"ports"`             // Open printing ports
+	Name    string   `json:"name,omitempty"`    // Name the printer announces over mDNS
+	Model   string   `json:"model,omitempty"`   // Make and model, from GS I or the mDNS record
+	ESCPOS  bool     `json:"escpos"`            // Answered DLE EOT on the raw port
+	Sources []string `json:"sources"`           // "scan" and/or "mdns"
+	// ConfiguredAs names the configured printer with this address.
+	ConfiguredAs string `json:"configuredAs,omitempty"`
+}
+
+// Options limit a discovery run.
+type Options struct {
+	// Subnets to scan as CIDRs, by default the machine's own IPv4 networks.
+	Subnets []string
+	// Timeout is how long a port may take to accept (default 500ms).
+	Timeout time.Duration
+	// Browse is how long mDNS answers are collected (default 2s).
+	Browse time.Duration
+}
+
+const (
+	defaultTimeout = 500 * time.Millisecond
+	defaultBrowse  = 2 * time.Second
+	// minPrefix bounds a subnet given to scan to 4096 addresses
+	minPrefix = 20
+)
+
+// Discover scans the subnets and browses mDNS at the same time, then reads
+// the model of every ESC/POS printer found. Only ESC/POS printers get an
+// Address. Devices are sorted by address.
+func Discover(ctx context.Context, opts Options) ([]Device, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.Browse <= 0 {
+		opts.Browse = defaultBrowse
+	}
+	nets, err := subnets(opts.Subnets)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		scanned  map[string][]int
+		browsed  []Device
+		browseEr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		scanned = scan(ctx, nets, []int{PortRaw, PortLPD, PortIPP}, opts.Timeout)
+	}()
+	go func() {
+		defer wg.Done()
+		browsed, browseEr = browse(ctx, opts.Browse)
+	}()
+	wg.Wait()
+	if browseEr != nil {
+		fmt.Printf("Warning: mDNS browse failed: %v\n", browseEr)
+	}
+
+	devices := merge(scanned, browsed)
+	fingerprint(ctx, devices, opts.Timeout)
+	// Anything can listen on 9100, this app included; only a printer that
+	// answered DLE EOT is offered as one
+	for i := range devices {
+		if !devices[i].ESCPOS {
+			devices[i].Address = ""
+		}
+	}
+	return devices, ctx.Err()
+}
+
+// merge combines the hosts found by the scan and by mDNS.
+func merge(scanned map[string][]int, browsed []Device) []Device {
+	byHost := make(map[string]*Device)
+	get := func(host string) *Device {
+		d, ok := byHost[host]
+		if !ok {
+			d = &Device{Host: host}
+			byHost[host] = d
+		}
+		return d
+	}
+	for host, ports := range scanned {
+		d := get(host)
+		d.Ports = append(d.Ports, ports...)
+		d.Sources = append(d.Sources, "scan")
+	}
+	for _, b := range browsed {
+		d := get(b.Host)
+		d.Ports = append(d.Ports, b.Ports...)
+		if d.Name == "" {
+			d.Name = b.Name
+		}
+		if d.Model == "" {
+			d.Model = b.Model
+		}
+		if d.Address == "" {
+			d.Address = b.Address
+		}
+		if !slices.Contains(d.Sources, "mdns") {
+			d.Sources = append(d.Sources, "mdns")
+		}
+	}
+
+	list := make([]Device, 0, len(byHost))
+	for _, d := range byHost {
+		slices.Sort(d.Ports)
+		d.Ports = slices.Compact(d.Ports)
+		if d.Address == "" && slices.Contains(d.Ports, PortRaw) {
+			d.Address = "tcp://" + net.JoinHostPort(d.Host, fmt.Sprint(PortRaw))
+		}
+		list = append(list, *d)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := net.ParseIP(list[i].Host), net.ParseIP(list[j].Host)
+		return bytes.Compare(a.To16(), b.To16()) < 0
+	})
+	return list
+}
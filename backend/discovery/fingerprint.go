@@ -0,0 +1,91 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fingerprint asks every device with a raw port whether it speaks ESC/POS
+// and, if it does, for its maker and model. Devices that also offer IPP are
+// left alone: they are usually office printers, which would print the
+// query bytes as a page.
+func fingerprint(ctx context.Context, devices []Device, timeout time.Duration) {
+	var wg sync.WaitGroup
+	for i := range devices {
+		d := &devices[i]
+		if d.Address == "" || slices.Contains(d.Ports, PortIPP) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			escpos, model := identify(ctx, strings.TrimPrefix(d.Address, "tcp://"), timeout)
+			d.ESCPOS = escpos
+			if model != "" {
+				d.Model = model
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// identify sends DLE EOT 1 to a raw port. An ESC/POS printer answers with a
+// status byte, and is then asked for its maker and model with GS I 66 and
+// GS I 67, which many printers answer as "_" text NUL.
+func identify(ctx context.Context, addr string, timeout time.Duration) (bool, string) {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return false, ""
+	}
+	defer conn.Close()
+
+	reply, err := exchange(conn, []byte{0x10, 0x04, 0x01}, timeout)
+	// The printer status byte has bits 1 and 4 set and bits 0 and 7 clear
+	if err != nil || len(reply) != 1 || reply[0]&0x93 != 0x12 {
+		return false, ""
+	}
+
+	var parts []string
+	for _, n := range []byte{66, 67} {
+		reply, err := exchange(conn, []byte{0x1D, 0x49, n}, timeout)
+		if err != nil {
+			break
+		}
+		text := bytes.TrimSuffix(bytes.TrimPrefix(reply, []byte{'_'}), []byte{0})
+		if len(reply) > 1 && reply[0] == '_' && len(text) > 0 {
+			parts = append(parts, strings.TrimSpace(string(text)))
+		}
+	}
+	return true, strings.Join(parts, " ")
+}
+
+// exchange writes a query and reads the reply up to a NUL, or whatever
+// arrived when the printer goes quiet.
+func exchange(conn net.Conn, query []byte, timeout time.Duration) ([]byte, error) {
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	var reply []byte
+	buf := make([]byte, 64)
+	for {
+		n, err := conn.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if err != nil {
+			if len(reply) > 0 {
+				return reply, nil
+			}
+			return nil, err
+		}
+		// A status byte is a whole reply; text ends at NUL
+		if reply[0] != '_' || bytes.IndexByte(reply, 0) >= 0 {
+			return reply, nil
+		}
+	}
+}
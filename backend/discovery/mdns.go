@@ -0,0 +1,257 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Services browsed with DNS-SD.
+const (
+	serviceRaw = "_pdl-datastream._tcp.local"
+	serviceIPP = "_ipp._tcp.local"
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// DNS record types used by DNS-SD.
+const (
+	typeA   = 1
+	typePTR = 12
+	typeTXT = 16
+	typeSRV = 33
+)
+
+// browse asks for the printing services over mDNS and collects answers for
+// the given time. The query is sent from an ephemeral port, which makes
+// responders answer by unicast to it.
+func browse(ctx context.Context, wait time.Duration) ([]Device, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP(mdnsQuery(serviceRaw, serviceIPP), mdnsGroup); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+
+	answers := newDNSAnswers()
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				break
+			}
+			return nil, err
+		}
+		if err := answers.parse(buf[:n], from.IP); err != nil {
+			fmt.Printf("Warning: bad mDNS answer from %v: %v\n", from.IP, err)
+		}
+	}
+	return answers.devices(), nil
+}
+
+// mdnsQuery builds a query for the PTR records of the services, asking for
+// unicast answers.
+func mdnsQuery(services ...string) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[4:], uint16(len(services)))
+	for _, s := range services {
+		for _, label := range strings.Split(s, ".") {
+			msg = append(msg, byte(len(label)))
+			msg = append(msg, label...)
+		}
+		msg = append(msg, 0)
+		msg = binary.BigEndian.AppendUint16(msg, typePTR)
+		msg = binary.BigEndian.AppendUint16(msg, 0x8001) // QU, class IN
+	}
+	return msg
+}
+
+type srvRecord struct {
+	target string
+	port   int
+}
+
+// dnsAnswers gathers the records of every answer received.
+type dnsAnswers struct {
+	instances map[string][]string // Service to instance names
+	srv       map[string]srvRecord
+	txt       map[string]map[string]string
+	addrs     map[string]net.IP // Host name to address
+	from      map[string]net.IP // Instance to the address that announced it
+}
+
+func newDNSAnswers() *dnsAnswers {
+	return &dnsAnswers{
+		instances: make(map[string][]string),
+		srv:       make(map[string]srvRecord),
+		txt:       make(map[string]map[string]string),
+		addrs:     make(map[string]net.IP),
+		from:      make(map[string]net.IP),
+	}
+}
+
+var errShort = errors.New("message too short")
+
+// parse reads the answer, authority and additional records of a message.
+func (a *dnsAnswers) parse(msg []byte, from net.IP) error {
+	if len(msg) < 12 {
+		return errShort
+	}
+	qd := int(binary.BigEndian.Uint16(msg[4:]))
+	records := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+
+	off := 12
+	for i := 0; i < qd; i++ {
+		_, next, err := readName(msg, off)
+		if err != nil {
+			return err
+		}
+		off = next + 4
+	}
+	for i := 0; i < records; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return err
+		}
+		if next+10 > len(msg) {
+			return errShort
+		}
+		typ := binary.BigEndian.Uint16(msg[next:])
+		size := int(binary.BigEndian.Uint16(msg[next+8:]))
+		data := next + 10
+		if data+size > len(msg) {
+			return errShort
+		}
+		off = data + size
+		name = strings.ToLower(name)
+
+		switch typ {
+		case typePTR:
+			instance, _, err := readName(msg, data)
+			if err != nil {
+				return err
+			}
+			a.instances[name] = append(a.instances[name], instance)
+			a.from[strings.ToLower(instance)] = from
+		case typeSRV:
+			if size < 7 {
+				return errShort
+			}
+			target, _, err := readName(msg, data+6)
+			if err != nil {
+				return err
+			}
+			a.srv[name] = srvRecord{target: strings.ToLower(target), port: int(binary.BigEndian.Uint16(msg[data+4:]))}
+		case typeTXT:
+			a.txt[name] = parseTXT(msg[data:off])
+		case typeA:
+			if size == 4 {
+				a.addrs[name] = net.IP(append([]byte(nil), msg[data:off]...))
+			}
+		}
+	}
+	return nil
+}
+
+// devices returns a device for every instance of the printing services.
+func (a *dnsAnswers) devices() []Device {
+	var list []Device
+	for _, service := range []string{serviceRaw, serviceIPP} {
+		for _, instance := range a.instances[service] {
+			key := strings.ToLower(instance)
+			srv := a.srv[key]
+			ip := a.addrs[srv.target]
+			if ip == nil {
+				ip = a.from[key]
+			}
+			if ip == nil || srv.port == 0 {
+				continue
+			}
+			d := Device{
+				Host:  ip.String(),
+				Ports: []int{srv.port},
+				Name:  strings.TrimSuffix(instance, "."+service),
+				Model: txtModel(a.txt[key]),
+			}
+			if service == serviceRaw {
+				d.Address = "tcp://" + net.JoinHostPort(d.Host, fmt.Sprint(srv.port))
+			}
+			list = append(list, d)
+		}
+	}
+	return list
+}
+
+// txtModel reads the printer model from the TXT keys of RFC 6763 printer
+// records: "ty", else the IEEE 1284 maker and model.
+func txtModel(txt map[string]string) string {
+	if ty := txt["ty"]; ty != "" {
+		return ty
+	}
+	return strings.TrimSpace(txt["usb_mfg"] + " " + txt["usb_mdl"])
+}
+
+func parseTXT(data []byte) map[string]string {
+	kv := make(map[string]string)
+	for len(data) > 0 {
+		n := int(data[0])
+		if 1+n > len(data) {
+			break
+		}
+		key, value, _ := strings.Cut(string(data[1:1+n]), "=")
+		kv[strings.ToLower(key)] = value
+		data = data[1+n:]
+	}
+	return kv
+}
+
+// readName reads a possibly compressed domain name at off and returns it
+// without the trailing dot, with the offset after it.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errShort
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(msg) {
+				return "", 0, errShort
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			if jumps++; jumps > 32 {
+				return "", 0, errors.New("name compression loop")
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errShort
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// scanWorkers is the number of connections attempted at once.
+const scanWorkers = 256
+
+// subnets parses the given CIDRs, or returns the machine's own networks.
+func subnets(cidrs []string) ([]*net.IPNet, error) {
+	if len(cidrs) == 0 {
+		return LocalSubnets(), nil
+	}
+	var list []*net.IPNet
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %q", cidr)
+		}
+		ones, bits := n.Mask.Size()
+		if bits != 32 {
+			return nil, fmt.Errorf("subnet %q is not IPv4", cidr)
+		}
+		if ones < minPrefix {
+			return nil, fmt.Errorf("subnet %q is too large to scan (at most /%d)", cidr, minPrefix)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// LocalSubnets returns the IPv4 networks of the machine's interfaces that
+// are up. Networks larger than a /24 are narrowed to the /24 around the
+// machine's address, where the outlet's printers usually are.
+func LocalSubnets() []*net.IPNet {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var list []*net.IPNet
+	seen := make(map[string]bool)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil || ipnet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			n := &net.IPNet{IP: ipnet.IP.To4(), Mask: ipnet.Mask}
+			if ones, _ := n.Mask.Size(); ones < 24 {
+				n.Mask = net.CIDRMask(24, 32)
+			}
+			n.IP = n.IP.Mask(n.Mask)
+			if !seen[n.String()] {
+				seen[n.String()] = true
+				list = append(list, n)
+			}
+		}
+	}
+	return list
+}
+
+// hosts returns the host addresses of a network, without its network and
+// broadcast addresses.
+func hosts(n *net.IPNet) []net.IP {
+	ones, bits := n.Mask.Size()
+	start := binary.BigEndian.Uint32(n.IP.To4())
+	size := uint32(1) << (bits - ones)
+	var list []net.IP
+	for i := uint32(1); i+1 < size; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, start+i)
+		list = append(list, ip)
+	}
+	if size <= 2 {
+		// A /31 or /32 has no network or broadcast address
+		for i := uint32(0); i < size; i++ {
+			ip := make(net.IP, 4)
+			binary.BigEndian.PutUint32(ip, start+i)
+			list = append(list, ip)
+		}
+	}
+	return list
+}
+
+// localIPs returns the machine's own addresses. They are not scanned: the
+// app's own server listens on 9100 by default.
+func localIPs() map[string]bool {
+	ips := make(map[string]bool)
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok {
+			ips[ipnet.IP.String()] = true
+		}
+	}
+	return ips
+}
+
+// scan tries the ports on every host of the networks and returns the open
+// ones by host.
+func scan(ctx context.Context, nets []*net.IPNet, ports []int, timeout time.Duration) map[string][]int {
+	type target struct {
+		host string
+		port int
+	}
+	self := localIPs()
+	targets := make(chan target)
+	go func() {
+		defer close(targets)
+		for _, n := range nets {
+			for _, ip := range hosts(n) {
+				if self[ip.String()] {
+					continue
+				}
+				for _, port := range ports {
+					select {
+					case targets <- target{ip.String(), port}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		open = make(map[string][]int)
+	)
+	d := net.Dialer{Timeout: timeout}
+	for i := 0; i < scanWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range targets {
+				conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(t.host, strconv.Itoa(t.port)))
+				if err != nil {
+					continue
+				}
+				conn.Close()
+				mu.Lock()
+				open[t.host] = append(open[t.host], t.port)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return open
+}
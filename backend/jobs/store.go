@@ -11,8 +11,15 @@ const (
 	StatusSuccess    JobStatus = "success"
 	StatusFailed     JobStatus = "failed"
 	StatusProcessing JobStatus = "processing"
+	StatusHeld       JobStatus = "held" // Waiting for the printer to recover (e.g. paper out)
 )
 
+// TypeDrawer is the ReceiptType of a job that opened the cash drawer.
+const TypeDrawer = "drawer"
+
+// MaxOutputs is the number of recent jobs whose printed bytes are kept for previews.
+const MaxOutputs = 20
+
 type PrintJob struct {
 	ID          string    `json:"id"`
 	InvoiceNo   string    `json:"invoiceNo"`
@@ -21,17 +28,54 @@ type PrintJob struct {
 	Error       string    `json:"error,omitempty"`
 	Timestamp   time.Time `json:"timestamp"`
 	ReceiptType string    `json:"receiptType"`
+	Station     string    `json:"station,omitempty"`    // Kitchen station of a routed KOT
+	QueueJobID  int       `json:"queueJobId,omitempty"` // ID the print queue (CUPS or the Windows spooler) gave the job
+	HasPreview  bool      `json:"hasPreview,omitempty"` // The printed bytes are kept, see Store.GetOutput
+}
+
+// Output is what was sent to the printer for a job, with what is needed to
+// render it again.
+type Output struct {
+	Data     []byte
+	DotWidth int
+	CodePage string
 }
 
 type Store struct {
-	mu   sync.RWMutex
-	jobs []PrintJob
+	mu      sync.RWMutex
+	jobs    []PrintJob
+	outputs map[string]Output
+	order   []string // job IDs in outputs, oldest first
 }
 
 func NewStore() *Store {
 	return &Store{
-		jobs: make([]PrintJob, 0),
+		jobs:    make([]PrintJob, 0),
+		outputs: make(map[string]Output),
+	}
+}
+
+// SetOutput keeps the bytes sent for a job, dropping the oldest beyond MaxOutputs.
+func (s *Store) SetOutput(id string, out Output) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.outputs[id]; !ok {
+		s.order = append(s.order, id)
 	}
+	s.outputs[id] = out
+	for len(s.order) > MaxOutputs {
+		delete(s.outputs, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// GetOutput returns the bytes sent for a recent job.
+func (s *Store) GetOutput(id string) (Output, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out, ok := s.outputs[id]
+	return out, ok
 }
 
 func (s *Store) AddJob(job PrintJob) {
@@ -55,6 +99,9 @@ func (s *Store) GetJobs() []PrintJob {
 	// Return copy
 	jobs := make([]PrintJob, len(s.jobs))
 	copy(jobs, s.jobs)
+	for i := range jobs {
+		_, jobs[i].HasPreview = s.outputs[jobs[i].ID]
+	}
 
 	// Reverse order to have newest first (LIFO)
 	for i, j := 0, len(jobs)-1; i < j; i, j = i+1, j-1 {
@@ -67,4 +114,6 @@ func (s *Store) ClearJobs() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.jobs = make([]PrintJob, 0)
+	s.outputs = make(map[string]Output)
+	s.order = nil
 }
@@ -0,0 +1,106 @@
+package kitchen
+
+import (
+	"strings"
+
+	"ts-escpos/backend/receipt"
+)
+
+// ExpoStation names the consolidated copy of a routed KOT, printed for the
+// expeditor who checks the whole order before it leaves the kitchen.
+const ExpoStation = "Expo"
+
+// Station is a kitchen section with its own KOT printer, e.g. the tandoor.
+// An item goes to the station named by its "station" field, else to the
+// first station with a matching SKU prefix, else to the first with its
+// category.
+type Station struct {
+	Name        string   `json:"name"`
+	Printer     string   `json:"printer"`
+	Categories  []string `json:"categories,omitempty"`  // Item categories it prepares, any case
+	SkuPrefixes []string `json:"skuPrefixes,omitempty"` // e.g. "BEV-"
+	Default     bool     `json:"default,omitempty"`     // Takes the items no rule matches
+}
+
+// Ticket is the part of an order one printer receives.
+type Ticket struct {
+	Station string
+	Printer string
+	Order   receipt.OrderData // Only the station's items, with Order.Station set
+}
+
+// Route splits the items of an order into one ticket per station, in the
+// order the stations are configured. Items no station takes go to the
+// default station, or else to fallback (the printer the KOT was sent to)
+// on a ticket without a station name. Modifiers travel with their item.
+func Route(order receipt.OrderData, stations []Station, fallback string) []Ticket {
+	byStation := make([][]receipt.OrderItem, len(stations))
+	var unrouted []receipt.OrderItem
+	for _, item := range order.Items {
+		i := StationFor(item, stations)
+		if i < 0 {
+			unrouted = append(unrouted, item)
+			continue
+		}
+		byStation[i] = append(byStation[i], item)
+	}
+
+	var tickets []Ticket
+	for i, items := range byStation {
+		if len(items) == 0 {
+			continue
+		}
+		tickets = append(tickets, ticket(order, stations[i].Name, stations[i].Printer, items))
+	}
+	if len(unrouted) > 0 {
+		tickets = append(tickets, ticket(order, "", fallback, unrouted))
+	}
+	return tickets
+}
+
+// Expo returns the consolidated copy of an order for the expo printer.
+func Expo(order receipt.OrderData, printerName string) Ticket {
+	return ticket(order, ExpoStation, printerName, order.Items)
+}
+
+// StationFor returns the index of the station an item goes to, -1 when no
+// station takes it.
+func StationFor(item receipt.OrderItem, stations []Station) int {
+	if name := strings.TrimSpace(item.Station); name != "" {
+		for i, st := range stations {
+			if strings.EqualFold(st.Name, name) {
+				return i
+			}
+		}
+	}
+	if item.Sku != "" {
+		for i, st := range stations {
+			for _, prefix := range st.SkuPrefixes {
+				if prefix != "" && strings.HasPrefix(strings.ToUpper(item.Sku), strings.ToUpper(prefix)) {
+					return i
+				}
+			}
+		}
+	}
+	if category := strings.TrimSpace(item.Category); category != "" {
+		for i, st := range stations {
+			for _, c := range st.Categories {
+				if strings.EqualFold(c, category) {
+					return i
+				}
+			}
+		}
+	}
+	for i, st := range stations {
+		if st.Default {
+			return i
+		}
+	}
+	return -1
+}
+
+func ticket(order receipt.OrderData, station, printerName string, items []receipt.OrderItem) Ticket {
+	order.Items = items
+	order.Station = station
+	return Ticket{Station: station, Printer: printerName, Order: order}
+}
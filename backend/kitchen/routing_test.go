@@ -0,0 +1,117 @@
+package kitchen
+
+import (
+	"reflect"
+	"testing"
+
+	"ts-escpos/backend/receipt"
+)
+
+var stations = []Station{
+	{Name: "Tandoor", Printer: "KOT_Tandoor", Categories: []string{"Tandoor", "Breads"}, SkuPrefixes: []string{"TND-"}},
+	{Name: "Bar", Printer: "KOT_Bar", Categories: []string{"Beverages"}, SkuPrefixes: []string{"BEV-", "BAR-"}},
+	{Name: "Curry", Printer: "KOT_Curry", Categories: []string{"Curries", "Beverages"}},
+}
+
+func TestStationFor(t *testing.T) {
+	withDefault := append(append([]Station(nil), stations...), Station{Name: "Main", Printer: "KOT_Main", Default: true})
+
+	for _, tc := range []struct {
+		name     string
+		item     receipt.OrderItem
+		stations []Station
+		want     int
+	}{
+		{"station field", receipt.OrderItem{Station: "Curry", Sku: "BEV-1", Category: "Tandoor"}, stations, 2},
+		{"station field any case", receipt.OrderItem{Station: " bar "}, stations, 1},
+		{"unknown station falls through to the SKU", receipt.OrderItem{Station: "Grill", Sku: "TND-4"}, stations, 0},
+		{"SKU prefix over category", receipt.OrderItem{Sku: "BAR-12", Category: "Curries"}, stations, 1},
+		{"SKU prefix any case", receipt.OrderItem{Sku: "bev-7"}, stations, 1},
+		{"category", receipt.OrderItem{Sku: "XYZ-1", Category: "curries"}, stations, 2},
+		{"first station with the category", receipt.OrderItem{Category: "Beverages"}, stations, 1},
+		{"default station", receipt.OrderItem{Category: "Desserts"}, withDefault, 3},
+		{"rules over the default", receipt.OrderItem{Category: "Breads"}, withDefault, 0},
+		{"no station", receipt.OrderItem{Category: "Desserts"}, stations, -1},
+		{"no stations", receipt.OrderItem{Station: "Bar"}, nil, -1},
+	} {
+		if got := StationFor(tc.item, tc.stations); got != tc.want {
+			t.Errorf("%s: StationFor = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRoute(t *testing.T) {
+	order := receipt.OrderData{
+		InvoiceNo: "1042",
+		TableNo:   "7",
+		Items: []receipt.OrderItem{
+			{Name: "Masala Chai", Category: "Beverages"},
+			{Name: "Butter Naan", Category: "Breads", Children: []receipt.OrderItem{{Name: "Extra Butter"}}},
+			{Name: "Gulab Jamun", Category: "Desserts"},
+			{Name: "Paneer Tikka", Sku: "TND-2"},
+			{Name: "Kulfi"},
+		},
+	}
+
+	tickets := Route(order, stations, "KOT_Main")
+	want := []struct {
+		station, printer string
+		items            []string
+	}{
+		{"Tandoor", "KOT_Tandoor", []string{"Butter Naan", "Paneer Tikka"}},
+		{"Bar", "KOT_Bar", []string{"Masala Chai"}},
+		{"", "KOT_Main", []string{"Gulab Jamun", "Kulfi"}},
+	}
+	if len(tickets) != len(want) {
+		t.Fatalf("got %d tickets, want %d: %+v", len(tickets), len(want), tickets)
+	}
+	for i, w := range want {
+		tk := tickets[i]
+		var names []string
+		for _, item := range tk.Order.Items {
+			names = append(names, item.Name)
+		}
+		if tk.Station != w.station || tk.Printer != w.printer || !reflect.DeepEqual(names, w.items) {
+			t.Errorf("ticket %d = %q on %q with %q, want %q on %q with %q",
+				i, tk.Station, tk.Printer, names, w.station, w.printer, w.items)
+		}
+		if tk.Order.Station != w.station {
+			t.Errorf("ticket %d: Order.Station = %q, want %q", i, tk.Order.Station, w.station)
+		}
+		if tk.Order.InvoiceNo != "1042" || tk.Order.TableNo != "7" {
+			t.Errorf("ticket %d lost the order details: %+v", i, tk.Order)
+		}
+	}
+	if children := tickets[0].Order.Items[0].Children; len(children) != 1 || children[0].Name != "Extra Butter" {
+		t.Errorf("modifiers did not travel with their item: %+v", children)
+	}
+	if len(order.Items) != 5 || order.Station != "" {
+		t.Errorf("Route changed the order it was given: %+v", order)
+	}
+
+	// With a default station nothing is left for the fallback printer
+	withDefault := append(append([]Station(nil), stations...), Station{Name: "Main", Printer: "KOT_Main", Default: true})
+	for _, tk := range Route(order, withDefault, "KOT_Fallback") {
+		if tk.Station == "" {
+			t.Errorf("fallback ticket with a default station: %+v", tk)
+		}
+	}
+
+	if tickets := Route(order, nil, "KOT_Main"); len(tickets) != 1 || tickets[0].Printer != "KOT_Main" || len(tickets[0].Order.Items) != 5 {
+		t.Errorf("without stations the whole order should go to the fallback printer: %+v", tickets)
+	}
+}
+
+func TestExpo(t *testing.T) {
+	order := receipt.OrderData{
+		InvoiceNo: "1042",
+		Items:     []receipt.OrderItem{{Name: "Masala Chai"}, {Name: "Butter Naan"}},
+	}
+	tk := Expo(order, "KOT_Expo")
+	if tk.Station != ExpoStation || tk.Order.Station != ExpoStation || tk.Printer != "KOT_Expo" {
+		t.Errorf("Expo = %q on %q (Order.Station %q)", tk.Station, tk.Printer, tk.Order.Station)
+	}
+	if !reflect.DeepEqual(tk.Order.Items, order.Items) || tk.Order.InvoiceNo != "1042" {
+		t.Errorf("Expo ticket is not the whole order: %+v", tk.Order)
+	}
+}
@@ -0,0 +1,215 @@
+package printer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"ts-escpos/backend/receipt"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/code39"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/twooffive"
+)
+
+const (
+	defaultBarcodeHeight = 80
+	defaultBarcodeWidth  = 2
+	barcodeQuietZone     = 10 // modules of white space on each side of a raster barcode
+)
+
+// PrintBarcode prints a 1D barcode. Symbologies the printer profile supports are
+// sent with GS k; the rest are drawn as a raster image.
+func (e *EscposAdapter) PrintBarcode(symbology, data string, opts receipt.BarcodeOptions) {
+	if data == "" {
+		return
+	}
+	e.flushLine()
+
+	symbology = strings.ToUpper(symbology)
+	data, err := normalizeBarcodeData(symbology, data)
+	if err != nil {
+		fmt.Printf("Error printing barcode: %v\n", err)
+		return
+	}
+
+	height := opts.Height
+	if height <= 0 || height > 255 {
+		height = defaultBarcodeHeight
+	}
+	width := opts.Width
+	if width < 2 || width > 6 {
+		width = defaultBarcodeWidth
+	}
+
+	if e.profile.SupportsBarcode(symbology) {
+		// GS k carries at most 255 bytes; longer data is drawn instead
+		if m, payload := nativeBarcode(symbology, data); len(payload) <= 255 {
+			e.printNativeBarcode(m, payload, height, width, opts.HRI)
+			return
+		}
+	}
+
+	bc, err := encodeBarcode(symbology, data)
+	if err != nil {
+		fmt.Printf("Error creating %s barcode: %v\n", symbology, err)
+		return
+	}
+
+	// Narrow the modules rather than run off the paper
+	if n := bc.Bounds().Dx() + 2*barcodeQuietZone; n*width > e.printableWidth() {
+		if n > e.printableWidth() {
+			fmt.Printf("Error printing barcode: %s data too long for the paper (%d bytes)\n", symbology, len(data))
+			return
+		}
+		width = e.printableWidth() / n
+	}
+
+	if opts.HRI == "above" || opts.HRI == "both" {
+		e.Write(data + "\n")
+	}
+	e.printGraphics(barcodeImage(bc, width, height))
+	if opts.HRI == "" || opts.HRI == "below" || opts.HRI == "both" {
+		e.Write(data + "\n")
+	}
+}
+
+// nativeBarcode returns the GS k symbology number and the bytes sent for data.
+func nativeBarcode(symbology, data string) (byte, string) {
+	switch symbology {
+	case receipt.BarcodeEAN13:
+		return 67, data
+	case receipt.BarcodeCODE39:
+		return 69, data
+	case receipt.BarcodeITF:
+		return 70, data
+	case receipt.BarcodeCODE128:
+		// Start in code set B; a literal '{' has to be doubled
+		return 73, "{B" + strings.ReplaceAll(data, "{", "{{")
+	}
+	return 0, data
+}
+
+func (e *EscposAdapter) printNativeBarcode(m byte, data string, height, width int, hri string) {
+	// GS h n: bar height in dots
+	e.buf.Write([]byte{0x1D, 0x68, byte(height)})
+	// GS w n: module width (2-6)
+	e.buf.Write([]byte{0x1D, 0x77, byte(width)})
+	// GS H n: HRI position (0 none, 1 above, 2 below, 3 both)
+	e.buf.Write([]byte{0x1D, 0x48, hriPosition(hri)})
+
+	// GS k m n d1...dn (function B, length-prefixed)
+	e.buf.Write([]byte{0x1D, 0x6B, m, byte(len(data))})
+	e.buf.WriteString(data)
+}
+
+func hriPosition(hri string) byte {
+	switch hri {
+	case "none":
+		return 0
+	case "above":
+		return 1
+	case "both":
+		return 3
+	default: // below
+		return 2
+	}
+}
+
+// normalizeBarcodeData checks data against what the symbology can carry.
+func normalizeBarcodeData(symbology, data string) (string, error) {
+	switch symbology {
+	case receipt.BarcodeEAN13:
+		if !isDigits(data) || (len(data) != 12 && len(data) != 13) {
+			return "", fmt.Errorf("EAN13 needs 12 or 13 digits, got %q", data)
+		}
+		// Printers compute the check digit themselves
+		return data[:12], nil
+	case receipt.BarcodeITF:
+		if !isDigits(data) {
+			return "", fmt.Errorf("ITF needs digits only, got %q", data)
+		}
+		if len(data)%2 != 0 {
+			data = "0" + data
+		}
+		return data, nil
+	case receipt.BarcodeCODE39:
+		data = strings.ToUpper(data)
+		for _, r := range data {
+			if !strings.ContainsRune("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ -.$/+%", r) {
+				return "", fmt.Errorf("CODE39 cannot encode %q", r)
+			}
+		}
+		return data, nil
+	case receipt.BarcodeCODE128:
+		for _, r := range data {
+			if r < 32 || r > 126 {
+				return "", fmt.Errorf("CODE128 cannot encode %q", r)
+			}
+		}
+		return data, nil
+	}
+	return "", fmt.Errorf("unsupported barcode symbology %q", symbology)
+}
+
+func encodeBarcode(symbology, data string) (barcode.Barcode, error) {
+	switch symbology {
+	case receipt.BarcodeEAN13:
+		return ean.Encode(data)
+	case receipt.BarcodeCODE39:
+		return code39.Encode(data, false, false)
+	case receipt.BarcodeITF:
+		return twooffive.Encode(data, true)
+	case receipt.BarcodeCODE128:
+		return code128.Encode(data)
+	}
+	return nil, fmt.Errorf("unsupported barcode symbology %q", symbology)
+}
+
+// BarcodeImage draws a 1D barcode as it is printed when the printer lacks the
+// symbology, with modules width dots wide and bars height dots high.
+func BarcodeImage(symbology, data string, width, height int) (image.Image, error) {
+	symbology = strings.ToUpper(symbology)
+	data, err := normalizeBarcodeData(symbology, data)
+	if err != nil {
+		return nil, err
+	}
+	bc, err := encodeBarcode(symbology, data)
+	if err != nil {
+		return nil, err
+	}
+	return barcodeImage(bc, width, height), nil
+}
+
+// barcodeImage draws a 1D barcode with each module `width` dots wide.
+func barcodeImage(bc barcode.Barcode, width, height int) image.Image {
+	modules := bc.Bounds().Dx()
+	img := image.NewGray(image.Rect(0, 0, (modules+2*barcodeQuietZone)*width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	for m := 0; m < modules; m++ {
+		if c := color.GrayModel.Convert(bc.At(bc.Bounds().Min.X+m, 0)).(color.Gray); c.Y >= 128 {
+			continue
+		}
+		x0 := (barcodeQuietZone + m) * width
+		for y := 0; y < height; y++ {
+			for x := x0; x < x0+width; x++ {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+	return img
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
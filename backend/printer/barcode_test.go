@@ -0,0 +1,46 @@
+package printer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"ts-escpos/backend/receipt"
+)
+
+func TestBarcodeLength(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		symbology string
+		data      string
+		native    bool
+	}{
+		{"short CODE128", "CODE128", "INV-1001", true},
+		{"CODE128 at the limit", "CODE128", strings.Repeat("A", 253), true},
+		{"braces doubled past the limit", "CODE128", strings.Repeat("{", 253), false},
+		{"CODE39 at the limit", "CODE39", strings.Repeat("A", 255), true},
+		{"CODE39 past the limit", "CODE39", strings.Repeat("A", 256), false},
+		{"ITF past the limit", "ITF", strings.Repeat("12", 130), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEscposAdapterWithSettings(Settings{Profile: "epson"})
+			e.PrintBarcode(tc.symbology, tc.data, receipt.BarcodeOptions{HRI: "none"})
+			out := e.GetBytes()
+
+			i := bytes.Index(out, []byte{0x1D, 0x6B})
+			if !tc.native {
+				if i >= 0 {
+					t.Fatalf("sent %d bytes of data with GS k", len(tc.data))
+				}
+				return
+			}
+			if i < 0 {
+				t.Fatal("no GS k command")
+			}
+			n := int(out[i+3])
+			if got := len(out) - (i + 4); got != n {
+				t.Errorf("GS k length byte %d, %d bytes of data follow", n, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package printer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// CodePage is a single-byte character table the printer can be switched to
+// with ESC t n. Number follows the Epson numbering used by most ESC/POS clones.
+type CodePage struct {
+	Name    string
+	Number  byte
+	charmap *charmap.Charmap
+}
+
+const (
+	DefaultCodePage = "CP437"
+	DefaultFallback = "?"
+)
+
+var codePages = map[string]CodePage{
+	"CP437":      {Name: "CP437", Number: 0, charmap: charmap.CodePage437},
+	"CP850":      {Name: "CP850", Number: 2, charmap: charmap.CodePage850},
+	"CP860":      {Name: "CP860", Number: 3, charmap: charmap.CodePage860},
+	"CP863":      {Name: "CP863", Number: 4, charmap: charmap.CodePage863},
+	"CP865":      {Name: "CP865", Number: 5, charmap: charmap.CodePage865},
+	"ISO8859-7":  {Name: "ISO8859-7", Number: 15, charmap: charmap.ISO8859_7},
+	"CP1252":     {Name: "CP1252", Number: 16, charmap: charmap.Windows1252},
+	"CP866":      {Name: "CP866", Number: 17, charmap: charmap.CodePage866},
+	"CP852":      {Name: "CP852", Number: 18, charmap: charmap.CodePage852},
+	"CP858":      {Name: "CP858", Number: 19, charmap: charmap.CodePage858},
+	"CP855":      {Name: "CP855", Number: 34, charmap: charmap.CodePage855},
+	"CP862":      {Name: "CP862", Number: 36, charmap: charmap.CodePage862},
+	"ISO8859-2":  {Name: "ISO8859-2", Number: 39, charmap: charmap.ISO8859_2},
+	"ISO8859-15": {Name: "ISO8859-15", Number: 40, charmap: charmap.ISO8859_15},
+	"CP1250":     {Name: "CP1250", Number: 45, charmap: charmap.Windows1250},
+	"CP1251":     {Name: "CP1251", Number: 46, charmap: charmap.Windows1251},
+	"CP1253":     {Name: "CP1253", Number: 47, charmap: charmap.Windows1253},
+	"CP1254":     {Name: "CP1254", Number: 48, charmap: charmap.Windows1254},
+	"CP1255":     {Name: "CP1255", Number: 49, charmap: charmap.Windows1255},
+	"CP1256":     {Name: "CP1256", Number: 50, charmap: charmap.Windows1256},
+	"CP1257":     {Name: "CP1257", Number: 51, charmap: charmap.Windows1257},
+}
+
+// Common characters that no supported code page carries (or that only some do)
+// are replaced with readable ASCII before falling back to the fallback string.
+var transliterations = map[rune]string{
+	'₹':      "Rs.",
+	'€':      "EUR",
+	'‘':      "'",
+	'’':      "'",
+	'‚':      ",",
+	'“':      "\"",
+	'”':      "\"",
+	'„':      "\"",
+	'–':      "-",
+	'—':      "-",
+	'−':      "-",
+	'…':      "...",
+	'•':      "*",
+	'×':      "x",
+	'™':      "TM",
+	'\u00a0': " ",
+	'\u200b': "",
+	'\u200c': "",
+	'\u200d': "",
+	'\ufeff': "",
+}
+
+// LookupCodePage resolves a code page by name. Names are matched loosely so
+// "cp858", "PC858", "858" and "WPC1252" all work.
+func LookupCodePage(name string) (CodePage, error) {
+	key := strings.ToUpper(strings.TrimSpace(name))
+	key = strings.ReplaceAll(key, "_", "-")
+	switch {
+	case key == "":
+		key = DefaultCodePage
+	case strings.HasPrefix(key, "WPC"):
+		key = "CP" + key[3:]
+	case strings.HasPrefix(key, "PC"):
+		key = "CP" + key[2:]
+	case strings.HasPrefix(key, "WINDOWS-"):
+		key = "CP" + key[8:]
+	case strings.HasPrefix(key, "ISO-"):
+		key = "ISO" + key[4:]
+	case key[0] >= '0' && key[0] <= '9':
+		key = "CP" + key
+	}
+
+	cp, ok := codePages[key]
+	if !ok {
+		return CodePage{}, fmt.Errorf("unsupported code page %q", name)
+	}
+	return cp, nil
+}
+
+// CodePageByNumber finds the code page selected by ESC t n.
+func CodePageByNumber(n byte) (CodePage, bool) {
+	for _, cp := range codePages {
+		if cp.Number == n {
+			return cp, true
+		}
+	}
+	return CodePage{}, false
+}
+
+// CodePageNames lists the code pages the adapter can transcode to.
+func CodePageNames() []string {
+	names := make([]string, 0, len(codePages))
+	for name := range codePages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CanEncode reports whether r can be printed in this code page without substitution.
+func (c CodePage) CanEncode(r rune) bool {
+	if r < 0x80 {
+		return true
+	}
+	_, ok := c.charmap.EncodeRune(r)
+	return ok
+}
+
+// Encode transcodes UTF-8 text into the code page. Characters that cannot be
+// represented are transliterated where possible, otherwise replaced by fallback.
+func (c CodePage) Encode(s string, fallback string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r < 0x80 {
+			out = append(out, byte(r))
+			continue
+		}
+		if b, ok := c.charmap.EncodeRune(r); ok {
+			out = append(out, b)
+			continue
+		}
+		if sub, ok := transliterations[r]; ok {
+			out = append(out, c.Encode(sub, fallback)...)
+			continue
+		}
+		out = append(out, fallback...)
+	}
+	return out
+}
+
+// Decode transcodes bytes in the code page back to UTF-8.
+func (c CodePage) Decode(b []byte) string {
+	var sb strings.Builder
+	for _, v := range b {
+		if v < 0x80 {
+			sb.WriteByte(v)
+			continue
+		}
+		sb.WriteRune(c.charmap.DecodeByte(v))
+	}
+	return sb.String()
+}
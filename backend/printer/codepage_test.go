@@ -0,0 +1,71 @@
+package printer
+
+import "testing"
+
+// The ESC t n table of the Epson ESC/POS Command Reference, for the code
+// pages the adapter supports.
+var epsonCodePages = []struct {
+	name   string
+	number byte
+}{
+	{"PC437", 0},
+	{"PC850", 2},
+	{"PC860", 3},
+	{"PC863", 4},
+	{"PC865", 5},
+	{"ISO8859-7", 15},
+	{"WPC1252", 16},
+	{"PC866", 17},
+	{"PC852", 18},
+	{"PC858", 19},
+	{"PC855", 34},
+	{"PC862", 36},
+	{"ISO8859-2", 39},
+	{"ISO8859-15", 40},
+	{"WPC1250", 45},
+	{"WPC1251", 46},
+	{"WPC1253", 47},
+	{"WPC1254", 48},
+	{"WPC1255", 49},
+	{"WPC1256", 50},
+	{"WPC1257", 51},
+}
+
+func TestCodePageNumbers(t *testing.T) {
+	if len(epsonCodePages) != len(codePages) {
+		t.Fatalf("table covers %d code pages, adapter supports %d", len(epsonCodePages), len(codePages))
+	}
+	for _, tc := range epsonCodePages {
+		cp, err := LookupCodePage(tc.name)
+		if err != nil {
+			t.Errorf("LookupCodePage(%q): %v", tc.name, err)
+			continue
+		}
+		if cp.Number != tc.number {
+			t.Errorf("%s: ESC t %d, want %d", tc.name, cp.Number, tc.number)
+		}
+		if back, ok := CodePageByNumber(tc.number); !ok || back.Name != cp.Name {
+			t.Errorf("CodePageByNumber(%d) = %q, %v; want %q", tc.number, back.Name, ok, cp.Name)
+		}
+	}
+}
+
+func TestCodePageRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		page string
+		text string
+	}{
+		{"CP1251", "Счёт"},
+		{"CP1253", "Λογαριασμός"},
+		{"CP858", "Café €"},
+		{"ISO8859-2", "Żółć"},
+	} {
+		cp, err := LookupCodePage(tc.page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cp.Decode(cp.Encode(tc.text, DefaultFallback)); got != tc.text {
+			t.Errorf("%s: round trip of %q gave %q", tc.page, tc.text, got)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+//go:build linux
+
+package printer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// DeviceTransport writes jobs straight to a printer's device file: a usblp
+// device (/dev/usb/lp0) or a serial port (/dev/ttyS0, /dev/ttyUSB0). The
+// device is kept open for the jobs that follow in quick succession, then
+// closed so the spooler and other programs can use it. Status is read back
+// over it with DLE EOT, both ways being supported by usblp and serial lines.
+type DeviceTransport struct {
+	path         string
+	serial       *SerialSettings // nil for a USB printer
+	writeTimeout time.Duration
+
+	mu        sync.Mutex
+	f         *os.File
+	idleTimer *time.Timer
+}
+
+// NewDeviceTransport creates a transport for "usb:///dev/usb/lp0" or
+// "serial:///dev/ttyUSB0". Serial ports are set up with the printer's
+// serial settings.
+func NewDeviceTransport(addr string, s Settings) (Transport, error) {
+	t := &DeviceTransport{writeTimeout: defaultWriteTimeout}
+	if s.WriteTimeout > 0 {
+		t.writeTimeout = time.Duration(s.WriteTimeout) * time.Second
+	}
+	if path, ok := strings.CutPrefix(addr, "usb://"); ok {
+		t.path = path
+	} else {
+		t.path = strings.TrimPrefix(addr, "serial://")
+		t.serial = &SerialSettings{}
+		if s.Serial != nil {
+			t.serial = s.Serial
+		}
+		if _, _, err := serialTermios(t.serial); err != nil {
+			return nil, err
+		}
+	}
+	if !strings.HasPrefix(t.path, "/dev/") {
+		return nil, fmt.Errorf("invalid device address %q", addr)
+	}
+	return t, nil
+}
+
+func (t *DeviceTransport) Send(ctx context.Context, data []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	logToFrontend(ctx, fmt.Sprintf("[Printer] Printing %d bytes to %s", len(data), t.path))
+	for attempt := 0; ; attempt++ {
+		f, err := t.open()
+		if err != nil {
+			return 0, fmt.Errorf("failed to open %s: %w", t.path, err)
+		}
+		_ = f.SetWriteDeadline(time.Now().Add(t.writeTimeout))
+		n, err := f.Write(data)
+		_ = f.SetWriteDeadline(time.Time{})
+		if err == nil {
+			logToFrontend(ctx, fmt.Sprintf("[Printer] Successfully sent job to %s", t.path))
+			t.closeWhenIdle(f)
+			return 0, nil
+		}
+		t.closeFile()
+		// A printer unplugged and plugged back in leaves the kept file dead;
+		// try once more on a new one
+		if n > 0 || attempt > 0 {
+			logToFrontend(ctx, fmt.Sprintf("[Printer] Error printing to %s: %v", t.path, err))
+			return 0, fmt.Errorf("failed to print to %s: %w", t.path, err)
+		}
+	}
+}
+
+func (t *DeviceTransport) Status() (DeviceStatus, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, err := t.open()
+	if err != nil {
+		return DeviceStatus{}, err
+	}
+	// Leave the device free for the spooler and other programs
+	defer t.closeFile()
+	return queryStatus(f, defaultStatusTimeout)
+}
+
+func (t *DeviceTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeFile()
+	return nil
+}
+
+// open opens the device non-blocking, so reads and writes honour deadlines,
+// and sets up the line of a serial port.
+func (t *DeviceTransport) open() (*os.File, error) {
+	if t.f != nil {
+		return t.f, nil
+	}
+	fd, err := unix.Open(t.path, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	if t.serial != nil {
+		if err := configureSerial(fd, t.serial); err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("failed to configure %s: %w", t.path, err)
+		}
+	}
+	t.f = os.NewFile(uintptr(fd), t.path)
+	return t.f, nil
+}
+
+// closeWhenIdle closes f unless another job uses it within idleTimeout.
+// Called with t.mu held.
+func (t *DeviceTransport) closeWhenIdle(f *os.File) {
+	if t.idleTimer != nil {
+		t.idleTimer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(idleTimeout, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		// A timer replaced by a later job's fired while that job held the lock
+		if t.idleTimer == timer && t.f == f {
+			t.closeFile()
+		}
+	})
+	t.idleTimer = timer
+}
+
+func (t *DeviceTransport) closeFile() {
+	if t.idleTimer != nil {
+		t.idleTimer.Stop()
+		t.idleTimer = nil
+	}
+	if t.f != nil {
+		t.f.Close()
+		t.f = nil
+	}
+}
+
+var baudRates = map[int]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+}
+
+// serialTermios returns the control and input flags of a serial line in
+// raw mode: baud rate, data bits, parity, stop bits and flow control.
+func serialTermios(s *SerialSettings) (cflag, iflag uint32, err error) {
+	baud := 9600
+	if s.BaudRate != 0 {
+		baud = s.BaudRate
+	}
+	speed, ok := baudRates[baud]
+	if !ok {
+		return 0, 0, fmt.Errorf("unsupported baud rate %d", baud)
+	}
+	cflag = speed | unix.CREAD | unix.CLOCAL
+
+	switch s.DataBits {
+	case 0, 8:
+		cflag |= unix.CS8
+	case 7:
+		cflag |= unix.CS7
+	default:
+		return 0, 0, fmt.Errorf("unsupported data bits %d", s.DataBits)
+	}
+
+	switch strings.ToLower(s.Parity) {
+	case "", "none":
+	case "even":
+		cflag |= unix.PARENB
+	case "odd":
+		cflag |= unix.PARENB | unix.PARODD
+	default:
+		return 0, 0, fmt.Errorf("unsupported parity %q", s.Parity)
+	}
+
+	switch s.StopBits {
+	case 0, 1:
+	case 2:
+		cflag |= unix.CSTOPB
+	default:
+		return 0, 0, fmt.Errorf("unsupported stop bits %d", s.StopBits)
+	}
+
+	switch strings.ToLower(s.FlowControl) {
+	case "", "none":
+	case "rtscts":
+		cflag |= unix.CRTSCTS
+	case "xonxoff":
+		iflag |= unix.IXON | unix.IXOFF
+	default:
+		return 0, 0, fmt.Errorf("unsupported flow control %q", s.FlowControl)
+	}
+	return cflag, iflag, nil
+}
+
+// configureSerial puts a serial port in raw mode with the given line settings.
+func configureSerial(fd int, s *SerialSettings) error {
+	cflag, iflag, err := serialTermios(s)
+	if err != nil {
+		return err
+	}
+	tio, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	tio.Cflag = cflag
+	tio.Iflag = iflag
+	tio.Oflag = 0
+	tio.Lflag = 0
+	tio.Ispeed = cflag & unix.CBAUD
+	tio.Ospeed = cflag & unix.CBAUD
+	tio.Cc[unix.VMIN] = 1
+	tio.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(fd, unix.TCSETS, tio)
+}
+
+// ListDevices finds the printers attached to this machine without a driver:
+// usblp devices, named after their device file ("lp0") and reached by
+// address until given a name in config. Serial ports are left out, as
+// nothing tells a printer from a modem or a scale on one; they are listed
+// once configured with a "serial://" address.
+func ListDevices() []PrinterInfo {
+	var list []PrinterInfo
+	usb, _ := filepath.Glob("/dev/usb/lp*")
+	for _, path := range usb {
+		name := filepath.Base(path)
+		list = append(list, PrinterInfo{
+			Name:     name,
+			UniqueID: "usb://" + path,
+			Status:   "Unknown",
+			Driver:   usbModel(name),
+			Address:  "usb://" + path,
+		})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// usbModel reads the maker and model a usblp printer reports in its
+// IEEE 1284 device ID ("MFG:EPSON;MDL:TM-T20II;...").
+func usbModel(name string) string {
+	id, err := os.ReadFile(filepath.Join("/sys/class/usbmisc", name, "device/ieee1284_id"))
+	if err != nil {
+		return ""
+	}
+	var mfg, mdl string
+	for _, field := range strings.Split(strings.TrimSpace(string(id)), ";") {
+		key, value, _ := strings.Cut(field, ":")
+		switch strings.ToUpper(strings.TrimSpace(key)) {
+		case "MFG", "MANUFACTURER":
+			mfg = strings.TrimSpace(value)
+		case "MDL", "MODEL":
+			mdl = strings.TrimSpace(value)
+		}
+	}
+	return strings.TrimSpace(mfg + " " + mdl)
+}
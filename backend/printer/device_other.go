@@ -0,0 +1,17 @@
+//go:build !linux
+
+package printer
+
+import "fmt"
+
+// NewDeviceTransport is only available on Linux, where printers without a
+// driver are reached through their device file.
+func NewDeviceTransport(addr string, s Settings) (Transport, error) {
+	return nil, fmt.Errorf("printer address %q is only supported on Linux", addr)
+}
+
+// ListDevices finds no devices outside Linux; their printers are installed
+// with a driver and listed by the OS.
+func ListDevices() []PrinterInfo {
+	return nil
+}
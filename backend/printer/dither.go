@@ -0,0 +1,173 @@
+package printer
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"strings"
+
+	"ts-escpos/backend/receipt"
+)
+
+const defaultThreshold = 128
+
+// bayer8 is the 8x8 ordered dithering matrix (values 0-63).
+var bayer8 = [8][8]float64{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// mergeImageOptions fills the fields opts leaves unset from base, so a
+// per-image setting overrides the printer's, which overrides the default.
+func mergeImageOptions(opts, base receipt.ImageOptions) receipt.ImageOptions {
+	if opts.Dither == "" {
+		opts.Dither = base.Dither
+	}
+	if opts.Threshold == 0 {
+		opts.Threshold = base.Threshold
+	}
+	if opts.Gamma == 0 {
+		opts.Gamma = base.Gamma
+	}
+	if opts.Brightness == 0 {
+		opts.Brightness = base.Brightness
+	}
+	if opts.Contrast == 0 {
+		opts.Contrast = base.Contrast
+	}
+	return opts
+}
+
+// ditherImage adjusts img and reduces it to black (0) and white (255) pixels.
+// Transparent areas are treated as paper.
+func ditherImage(img image.Image, opts receipt.ImageOptions) *image.Gray {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	draw.Draw(gray, gray.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(gray, gray.Bounds(), img, b.Min, draw.Over)
+
+	levels := adjustLevels(gray, opts)
+
+	threshold := float64(opts.Threshold)
+	if opts.Threshold <= 0 || opts.Threshold > 255 {
+		threshold = defaultThreshold
+	}
+
+	switch normalizeDither(opts.Dither) {
+	case receipt.DitherThreshold:
+		for i, v := range levels {
+			gray.Pix[i] = dot(v < threshold)
+		}
+	case receipt.DitherOrdered:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				t := threshold + ((bayer8[y%8][x%8]+0.5)/64-0.5)*255
+				gray.Pix[y*w+x] = dot(levels[y*w+x] < t)
+			}
+		}
+	case receipt.DitherAtkinson:
+		// Spreads 6/8 of the error, keeping highlights and shadows clean
+		diffuse(levels, gray.Pix, w, h, threshold, []diffusion{
+			{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8},
+			{-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8},
+			{0, 2, 1.0 / 8},
+		})
+	default: // Floyd-Steinberg
+		diffuse(levels, gray.Pix, w, h, threshold, []diffusion{
+			{1, 0, 7.0 / 16},
+			{-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+		})
+	}
+	return gray
+}
+
+func normalizeDither(name string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "fs", "floyd-steinberg", "floydsteinberg", "floyd_steinberg":
+		return receipt.DitherFloydSteinberg
+	case "threshold", "none":
+		return receipt.DitherThreshold
+	case "atkinson":
+		return receipt.DitherAtkinson
+	case "ordered", "bayer":
+		return receipt.DitherOrdered
+	}
+	fmt.Printf("Warning: unknown dither method %q, using %s\n", name, receipt.DitherFloydSteinberg)
+	return receipt.DitherFloydSteinberg
+}
+
+// adjustLevels applies brightness, contrast and gamma, returning grey
+// levels (0-255) in row order.
+func adjustLevels(gray *image.Gray, opts receipt.ImageOptions) []float64 {
+	brightness := float64(clamp(opts.Brightness, -100, 100)) / 100
+	contrast := float64(clamp(opts.Contrast, -100, 99))
+	factor := 1.0
+	if contrast > 0 {
+		factor = 100 / (100 - contrast)
+	} else if contrast < 0 {
+		factor = (100 + contrast) / 100
+	}
+	gamma := opts.Gamma
+	if gamma <= 0 {
+		gamma = 1
+	}
+
+	levels := make([]float64, len(gray.Pix))
+	for i, p := range gray.Pix {
+		v := float64(p)/255 + brightness
+		v = (v-0.5)*factor + 0.5
+		v = math.Pow(math.Max(0, math.Min(1, v)), 1/gamma)
+		levels[i] = v * 255
+	}
+	return levels
+}
+
+type diffusion struct {
+	dx, dy int
+	weight float64
+}
+
+// diffuse thresholds each pixel and pushes the rounding error onto its
+// not-yet-visited neighbours.
+func diffuse(levels []float64, out []byte, w, h int, threshold float64, kernel []diffusion) {
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			v := levels[i]
+			black := v < threshold
+			out[i] = dot(black)
+
+			err := v - 255
+			if black {
+				err = v
+			}
+			for _, k := range kernel {
+				nx, ny := x+k.dx, y+k.dy
+				if nx < 0 || nx >= w || ny >= h {
+					continue
+				}
+				levels[ny*w+nx] += err * k.weight
+			}
+		}
+	}
+}
+
+func dot(black bool) byte {
+	if black {
+		return 0
+	}
+	return 0xFF
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(hi, v))
+}
@@ -0,0 +1,278 @@
+package printer
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"ts-escpos/backend/receipt"
+
+	"github.com/nfnt/resize"
+)
+
+// Output formats a receipt can be rendered to.
+const (
+	FormatESCPOS = "escpos"
+	FormatText   = "text"
+	FormatHTML   = "html"
+	FormatPDF    = "pdf"
+)
+
+// Document is a receipt.Printer that produces a file instead of printer commands.
+type Document interface {
+	receipt.Printer
+	GetBytes() []byte
+	ContentType() string
+}
+
+// NewDocument creates the printer for an output format other than ESC/POS.
+func NewDocument(format string, s Settings) (Document, error) {
+	switch strings.ToLower(format) {
+	case FormatText:
+		return NewTextPrinter(documentLayout(s).ColumnsA), nil
+	case FormatHTML:
+		return NewHTMLPrinter(s), nil
+	case FormatPDF:
+		return NewPDFPrinter(s), nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// documentLayout resolves the paper of a document the way the adapter does,
+// without a printer profile.
+func documentLayout(s Settings) Layout {
+	var layout Layout
+	if s.Paper != "" {
+		paper, err := PaperLayout(s.Paper)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		layout = paper
+	}
+	return layout.Merge(s.Layout).Resolve()
+}
+
+// docStyle is the text style of a run in a document.
+type docStyle struct {
+	bold      bool
+	underline bool
+	reverse   bool
+	font      string
+	width     uint8 // GS ! multipliers, 0 for normal
+	height    uint8
+}
+
+type docRun struct {
+	text  string
+	style docStyle
+}
+
+// docBlock is a line of text, a picture or a cut.
+type docBlock struct {
+	align string
+	runs  []docRun
+	image image.Image
+	alt   string // Text standing in for the picture where it cannot be shown
+	cut   bool
+}
+
+// document records what a template writes as styled lines and pictures. The
+// text, HTML and PDF printers lay the blocks out in their own format.
+// Commands with no meaning on screen or paper files (drawer kicks, spacing,
+// rotation) are accepted and ignored.
+type document struct {
+	layout Layout
+	style  docStyle
+	align  string
+	line   []docRun
+	blocks []docBlock
+}
+
+func newDocument(layout Layout) *document {
+	d := &document{layout: layout}
+	d.Init()
+	return d
+}
+
+func (d *document) Init() {
+	d.flushLine()
+	d.style = docStyle{font: "A"}
+	d.align = "left"
+}
+
+func (d *document) Columns() int {
+	if d.style.font == "B" {
+		return d.layout.ColumnsB
+	}
+	return d.layout.ColumnsA
+}
+
+func (d *document) SetAlign(align string)        { d.align = align }
+func (d *document) SetFont(font string)          { d.style.font = font }
+func (d *document) SetBold(bold bool)            { d.style.bold = bold }
+func (d *document) SetDoubleStrike(bool)         {}
+func (d *document) SetSize(width, height uint8)  { d.style.width, d.style.height = width, height }
+func (d *document) SetUnderline(mode uint8)      { d.style.underline = mode > 0 }
+func (d *document) SetReverse(enabled bool)      { d.style.reverse = enabled }
+func (d *document) SetUpsideDown(bool)           {}
+func (d *document) SetRotate90(bool)             {}
+func (d *document) SetLineSpacing(int)           {}
+func (d *document) SetCharSpacing(int)           {}
+func (d *document) SetPrintArea(int, int)        {}
+func (d *document) OpenCashDrawer(int, int, int) {}
+func (d *document) Beep(int, int)                {}
+
+func (d *document) Write(data string) {
+	for {
+		i := strings.IndexByte(data, '\n')
+		part := data
+		if i >= 0 {
+			part = data[:i]
+		}
+		if part != "" {
+			d.line = append(d.line, docRun{text: part, style: d.style})
+		}
+		if i < 0 {
+			return
+		}
+		d.blocks = append(d.blocks, docBlock{align: d.align, runs: d.line})
+		d.line = nil
+		data = data[i+1:]
+	}
+}
+
+func (d *document) WriteRaster(data string) {
+	d.flushLine()
+	d.Write(strings.TrimSuffix(data, "\n") + "\n")
+}
+
+// flushLine ends a line left open without a newline.
+func (d *document) flushLine() {
+	if len(d.line) > 0 {
+		d.blocks = append(d.blocks, docBlock{align: d.align, runs: d.line})
+		d.line = nil
+	}
+}
+
+func (d *document) Feed(n uint8) {
+	d.flushLine()
+	for i := uint8(0); i < n; i++ {
+		d.blocks = append(d.blocks, docBlock{align: d.align})
+	}
+}
+
+func (d *document) Cut() {
+	d.flushLine()
+	d.blocks = append(d.blocks, docBlock{cut: true})
+}
+
+func (d *document) addImage(img image.Image, alt string) {
+	d.flushLine()
+	d.blocks = append(d.blocks, docBlock{align: d.align, image: img, alt: alt})
+}
+
+func (d *document) PrintQRCode(data string, opts receipt.QRCodeOptions) {
+	if data == "" {
+		return
+	}
+	size := opts.Size
+	if size <= 0 || size > 16 {
+		size = defaultQRModuleSize
+	}
+	img, err := QRCodeImage(data, opts.ECC, size)
+	if err != nil {
+		fmt.Printf("Error creating QR code: %v\n", err)
+		return
+	}
+	d.addImage(img, data)
+}
+
+func (d *document) PrintBarcode(symbology, data string, opts receipt.BarcodeOptions) {
+	if data == "" {
+		return
+	}
+	height := opts.Height
+	if height <= 0 || height > 255 {
+		height = defaultBarcodeHeight
+	}
+	img, err := BarcodeImage(symbology, data, defaultBarcodeWidth, height)
+	if err != nil {
+		fmt.Printf("Error creating %s barcode: %v\n", symbology, err)
+		return
+	}
+	if opts.HRI == "above" || opts.HRI == "both" {
+		d.Write(data + "\n")
+	}
+	d.addImage(img, "")
+	if opts.HRI == "" || opts.HRI == "below" || opts.HRI == "both" {
+		d.Write(data + "\n")
+	}
+}
+
+func (d *document) PrintPDF417(data string, opts receipt.PDF417Options) {
+	if data == "" {
+		return
+	}
+	moduleWidth := opts.ModuleWidth
+	if moduleWidth < 1 || moduleWidth > 8 {
+		moduleWidth = defaultPDF417ModuleWidth
+	}
+	ecc := opts.ECC
+	if ecc <= 0 || ecc > 8 {
+		ecc = defaultPDF417ECC
+	}
+	img, err := PDF417Image(data, ecc, moduleWidth, defaultPDF417RowHeight, d.layout.PrintableWidth())
+	if err != nil {
+		fmt.Printf("Error creating PDF417: %v\n", err)
+		return
+	}
+	d.addImage(img, data)
+}
+
+func (d *document) PrintDataMatrix(data string, opts receipt.DataMatrixOptions) {
+	if data == "" {
+		return
+	}
+	moduleSize := opts.ModuleSize
+	if moduleSize <= 0 || moduleSize > 16 {
+		moduleSize = defaultDataMatrixModule
+	}
+	img, err := DataMatrixImage(data, moduleSize, d.layout.PrintableWidth())
+	if err != nil {
+		fmt.Printf("Error creating DataMatrix: %v\n", err)
+		return
+	}
+	d.addImage(img, data)
+}
+
+// PrintImage embeds a picture in greyscale, scaled down to the paper width.
+// Documents are read on screen, so it is not dithered.
+func (d *document) PrintImage(urlStr string, opts receipt.ImageOptions) {
+	if urlStr == "" {
+		return
+	}
+	img, err := getImageFromURL(urlStr)
+	if err != nil {
+		fmt.Printf("Error processing image from URL %s: %v\n", urlStr, err)
+		return
+	}
+	if maxWidth := uint(d.layout.PrintableWidth()); img.Bounds().Dx() > int(maxWidth) {
+		img = resize.Resize(maxWidth, 0, img, resize.Lanczos3)
+	}
+	d.addImage(img, "")
+}
+
+// finish closes the last line and returns every block.
+func (d *document) finish() []docBlock {
+	d.flushLine()
+	return d.blocks
+}
+
+// lineText joins the runs of a line.
+func lineText(runs []docRun) string {
+	var sb strings.Builder
+	for _, r := range runs {
+		sb.WriteString(r.text)
+	}
+	return sb.String()
+}
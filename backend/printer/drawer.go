@@ -0,0 +1,64 @@
+package printer
+
+import "fmt"
+
+const (
+	// Pulse lengths most drawer solenoids open reliably with
+	defaultDrawerOnMs  = 120
+	defaultDrawerOffMs = 240
+	defaultBeepMs      = 100
+)
+
+// OpenCashDrawer sends a kick pulse to the drawer wired to the printer's
+// RJ11 port (ESC p). pin selects connector pin 2 (default) or 5; zero
+// durations use the defaults.
+func (e *EscposAdapter) OpenCashDrawer(pin, onMs, offMs int) {
+	e.flushLine()
+
+	var m byte // 0 = pin 2, 1 = pin 5
+	if pin == 5 {
+		m = 1
+	}
+	if onMs <= 0 {
+		onMs = defaultDrawerOnMs
+	}
+	if offMs <= 0 {
+		offMs = defaultDrawerOffMs
+	}
+
+	// ESC p m t1 t2: on and off times in 2ms units
+	e.buf.Write([]byte{0x1B, 0x70, m, byte(clamp(onMs/2, 1, 255)), byte(clamp(offMs/2, 1, 255))})
+	e.kicks++
+}
+
+// DrawerKicks returns how many drawer kick pulses the adapter has sent.
+func (e *EscposAdapter) DrawerKicks() int {
+	return e.kicks
+}
+
+// HasBuzzer reports whether the profile knows the printer's beep command.
+func (e *EscposAdapter) HasBuzzer() bool {
+	return e.profile.Buzzer == BuzzerEpson || e.profile.Buzzer == BuzzerClone
+}
+
+// Beep sounds the printer's buzzer times times for durationMs each, using the
+// command of the profile's buzzer type. Printers without a buzzer ignore it.
+func (e *EscposAdapter) Beep(times, durationMs int) {
+	e.flushLine()
+
+	times = clamp(times, 1, 9)
+	if durationMs <= 0 {
+		durationMs = defaultBeepMs
+	}
+
+	switch e.profile.Buzzer {
+	case BuzzerEpson:
+		// ESC ( A pL pH n m t: pattern A, m times, t x 100ms
+		e.buf.Write([]byte{0x1B, 0x28, 0x41, 0x03, 0x00, 0x61, byte(times), byte(clamp(durationMs/100, 1, 255))})
+	case BuzzerClone:
+		// ESC B n t: n times, t x 50ms
+		e.buf.Write([]byte{0x1B, 0x42, byte(times), byte(clamp(durationMs/50, 1, 9))})
+	default:
+		fmt.Printf("Warning: profile %s has no buzzer, skipping beep\n", e.profile.Name)
+	}
+}
@@ -0,0 +1,281 @@
+// Package emulator decodes ESC/POS byte streams and draws the paper they would
+// print, so a job can be inspected without a printer at hand.
+package emulator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTruncated is returned when the stream ends in the middle of a command.
+var ErrTruncated = errors.New("truncated ESC/POS command")
+
+// Command is one decoded ESC/POS command, or a run of printable text.
+type Command struct {
+	Offset int    `json:"offset"`         // Position of the first byte in the stream
+	Name   string `json:"name"`           // e.g. "ESC a", "GS v 0", "TEXT"
+	Args   []byte `json:"args,omitempty"` // Parameter bytes
+	Data   []byte `json:"data,omitempty"` // Text, image or symbol data
+}
+
+func (c Command) String() string {
+	if c.Name == "TEXT" {
+		return fmt.Sprintf("%d: TEXT %q", c.Offset, c.Data)
+	}
+	s := fmt.Sprintf("%d: %s % X", c.Offset, c.Name, c.Args)
+	if len(c.Data) > 0 {
+		s += fmt.Sprintf(" (%d data bytes)", len(c.Data))
+	}
+	return s
+}
+
+// Control codes
+const (
+	ht  = 0x09
+	lf  = 0x0A
+	ff  = 0x0C
+	cr  = 0x0D
+	dle = 0x10
+	esc = 0x1B
+	fs  = 0x1C
+	gs  = 0x1D
+)
+
+// Fixed parameter counts of the ESC and GS commands without a data block.
+var (
+	escArgs = map[byte]int{
+		'@': 0, '2': 0, '3': 1, ' ': 1, '!': 1, '-': 1, 'a': 1, 'd': 1, 'e': 1, 'E': 1,
+		'G': 1, 'J': 1, 'M': 1, 'R': 1, 'r': 1, 't': 1, 'U': 1, 'V': 1, '{': 1,
+		'$': 2, '\\': 2, 'B': 2, 'c': 2, 'p': 3,
+	}
+	gsArgs = map[byte]int{
+		'!': 1, 'B': 1, 'a': 1, 'b': 1, 'f': 1, 'H': 1, 'h': 1, 'I': 1, 'r': 1, 'w': 1,
+		'L': 2, 'W': 2, 'P': 2,
+	}
+	fsArgs = map[byte]int{'.': 0, '&': 0, 'p': 2}
+)
+
+// Decode splits an ESC/POS stream into commands. When the stream ends inside
+// a command, the commands before it are returned with ErrTruncated.
+func Decode(data []byte) ([]Command, error) {
+	d := decoder{data: data}
+	var cmds []Command
+	for d.pos < len(data) {
+		cmd, err := d.next()
+		if err != nil {
+			return cmds, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+// take returns the next n bytes.
+func (d *decoder) take(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, ErrTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// takeUntilNUL returns the bytes up to a NUL, which is consumed.
+func (d *decoder) takeUntilNUL() ([]byte, error) {
+	for i := d.pos; i < len(d.data); i++ {
+		if d.data[i] == 0 {
+			b := d.data[d.pos:i]
+			d.pos = i + 1
+			return b, nil
+		}
+	}
+	return nil, ErrTruncated
+}
+
+func (d *decoder) next() (Command, error) {
+	start := d.pos
+	cmd := Command{Offset: start}
+	b := d.data[d.pos]
+
+	switch b {
+	case ht, lf, ff, cr:
+		d.pos++
+		cmd.Name = map[byte]string{ht: "HT", lf: "LF", ff: "FF", cr: "CR"}[b]
+		return cmd, nil
+	case dle, esc, gs, fs:
+		d.pos++
+		if d.pos >= len(d.data) {
+			return cmd, ErrTruncated
+		}
+		var err error
+		switch b {
+		case dle:
+			err = d.dleCommand(&cmd)
+		case esc:
+			err = d.escCommand(&cmd)
+		case gs:
+			err = d.gsCommand(&cmd)
+		case fs:
+			err = d.fsCommand(&cmd)
+		}
+		return cmd, err
+	}
+
+	if b < 0x20 {
+		d.pos++
+		cmd.Name = fmt.Sprintf("0x%02X", b)
+		return cmd, nil
+	}
+
+	// Printable text runs until the next control code
+	end := d.pos
+	for end < len(d.data) && d.data[end] >= 0x20 {
+		end++
+	}
+	cmd.Name = "TEXT"
+	cmd.Data = d.data[d.pos:end]
+	d.pos = end
+	return cmd, nil
+}
+
+func (d *decoder) dleCommand(cmd *Command) error {
+	c := d.data[d.pos]
+	d.pos++
+	var err error
+	switch c {
+	case 0x04: // DLE EOT n
+		cmd.Name = "DLE EOT"
+		cmd.Args, err = d.take(1)
+	case 0x14: // DLE DC4 fn m t
+		cmd.Name = "DLE DC4"
+		cmd.Args, err = d.take(3)
+	default:
+		cmd.Name = fmt.Sprintf("DLE 0x%02X", c)
+	}
+	return err
+}
+
+func (d *decoder) escCommand(cmd *Command) error {
+	c := d.data[d.pos]
+	d.pos++
+	cmd.Name = "ESC " + string(rune(c))
+	if c == ' ' {
+		cmd.Name = "ESC SP"
+	}
+
+	if n, ok := escArgs[c]; ok {
+		var err error
+		cmd.Args, err = d.take(n)
+		return err
+	}
+
+	var err error
+	switch c {
+	case '*': // ESC * m nL nH d1...dk
+		if cmd.Args, err = d.take(3); err != nil {
+			return err
+		}
+		n := int(cmd.Args[1]) + int(cmd.Args[2])*256
+		if cmd.Args[0] >= 32 {
+			n *= 3
+		}
+		cmd.Data, err = d.take(n)
+	case '(': // ESC ( fn pL pH p1...pk
+		var head []byte
+		if head, err = d.take(3); err != nil {
+			return err
+		}
+		cmd.Name += " " + string(rune(head[0]))
+		cmd.Args, err = d.take(int(head[1]) + int(head[2])*256)
+	case 'D': // ESC D n1...nk NUL: tab positions
+		cmd.Args, err = d.takeUntilNUL()
+	default:
+		cmd.Name = fmt.Sprintf("ESC 0x%02X", c)
+	}
+	return err
+}
+
+func (d *decoder) gsCommand(cmd *Command) error {
+	c := d.data[d.pos]
+	d.pos++
+	cmd.Name = "GS " + string(rune(c))
+
+	if n, ok := gsArgs[c]; ok {
+		var err error
+		cmd.Args, err = d.take(n)
+		return err
+	}
+
+	var err error
+	switch c {
+	case 'V': // GS V m [n]
+		if cmd.Args, err = d.take(1); err != nil {
+			return err
+		}
+		if cmd.Args[0] >= 65 {
+			var n []byte
+			if n, err = d.take(1); err != nil {
+				return err
+			}
+			cmd.Args = append(cmd.Args[:1:1], n...)
+		}
+	case 'v': // GS v 0 m xL xH yL yH d1...dk
+		if cmd.Args, err = d.take(6); err != nil {
+			return err
+		}
+		cmd.Name = "GS v 0"
+		cmd.Args = cmd.Args[1:]
+		x := int(cmd.Args[1]) + int(cmd.Args[2])*256
+		y := int(cmd.Args[3]) + int(cmd.Args[4])*256
+		cmd.Data, err = d.take(x * y)
+	case 'k': // GS k m d1...dk NUL (m <= 6) or GS k m n d1...dn
+		if cmd.Args, err = d.take(1); err != nil {
+			return err
+		}
+		if cmd.Args[0] <= 6 {
+			cmd.Data, err = d.takeUntilNUL()
+			return err
+		}
+		var n []byte
+		if n, err = d.take(1); err != nil {
+			return err
+		}
+		cmd.Data, err = d.take(int(n[0]))
+	case '(': // GS ( fn pL pH p1...pk
+		var head []byte
+		if head, err = d.take(3); err != nil {
+			return err
+		}
+		cmd.Name += " " + string(rune(head[0]))
+		cmd.Args, err = d.take(int(head[1]) + int(head[2])*256)
+	case '8': // GS 8 L p1 p2 p3 p4 m fn ...
+		var head []byte
+		if head, err = d.take(5); err != nil {
+			return err
+		}
+		cmd.Name += " " + string(rune(head[0]))
+		n := int(head[1]) | int(head[2])<<8 | int(head[3])<<16 | int(head[4])<<24
+		cmd.Args, err = d.take(n)
+	default:
+		cmd.Name = fmt.Sprintf("GS 0x%02X", c)
+	}
+	return err
+}
+
+func (d *decoder) fsCommand(cmd *Command) error {
+	c := d.data[d.pos]
+	d.pos++
+	cmd.Name = "FS " + string(rune(c))
+	if n, ok := fsArgs[c]; ok {
+		var err error
+		cmd.Args, err = d.take(n)
+		return err
+	}
+	cmd.Name = fmt.Sprintf("FS 0x%02X", c)
+	return nil
+}
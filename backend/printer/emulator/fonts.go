@@ -0,0 +1,147 @@
+package emulator
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Character cells of the printer's built-in fonts, in dots.
+const (
+	fontAWidth, fontAHeight = 12, 24
+	fontBWidth, fontBHeight = 9, 17
+)
+
+// Go Mono at these pixel sizes advances exactly one cell.
+const (
+	fontASize = 20
+	fontBSize = 15
+)
+
+var (
+	monoOnce sync.Once
+	mono     *opentype.Font
+)
+
+// fonts draws characters into printer cells. Glyphs are cached per renderer
+// because font.Face is not safe for concurrent use.
+type fonts struct {
+	faces  [2]font.Face
+	glyphs map[glyphKey]*image.Alpha
+}
+
+type glyphKey struct {
+	r     rune
+	fontB bool
+}
+
+func newFonts() *fonts {
+	monoOnce.Do(func() {
+		mono, _ = opentype.Parse(gomono.TTF)
+	})
+	f := &fonts{glyphs: make(map[glyphKey]*image.Alpha)}
+	for i, size := range []float64{fontASize, fontBSize} {
+		face, err := opentype.NewFace(mono, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err == nil {
+			f.faces[i] = face
+		}
+	}
+	return f
+}
+
+func (f *fonts) cellWidth(fontB bool) int {
+	if fontB {
+		return fontBWidth
+	}
+	return fontAWidth
+}
+
+func (f *fonts) cellHeight(fontB bool) int {
+	if fontB {
+		return fontBHeight
+	}
+	return fontAHeight
+}
+
+// glyph returns the cell-sized mask of a character.
+func (f *fonts) glyph(r rune, fontB bool) *image.Alpha {
+	key := glyphKey{r, fontB}
+	if g, ok := f.glyphs[key]; ok {
+		return g
+	}
+
+	w, h := f.cellWidth(fontB), f.cellHeight(fontB)
+	g := image.NewAlpha(image.Rect(0, 0, w, h))
+	face := f.faces[0]
+	if fontB {
+		face = f.faces[1]
+	}
+	if face != nil {
+		m := face.Metrics()
+		// Centre the font's ascent + descent in the cell
+		baseline := (fixed.I(h)-m.Ascent-m.Descent)/2 + m.Ascent
+		d := font.Drawer{Dst: g, Src: image.Opaque, Face: face, Dot: fixed.Point26_6{Y: baseline}}
+		d.DrawString(string(r))
+	}
+	f.glyphs[key] = g
+	return g
+}
+
+// draw paints a character into cell, scaled to it; spacing is the blank part
+// on the right that ESC SP adds.
+func (f *fonts) draw(dst *image.Gray, cell image.Rectangle, g glyph, spacing int) {
+	mask := f.glyph(g.r, g.style.fontB)
+	if g.style.rotate {
+		mask = rotate90(mask)
+	}
+
+	ink := cell
+	ink.Max.X -= spacing
+	mw, mh := mask.Bounds().Dx(), mask.Bounds().Dy()
+	for y := ink.Min.Y; y < ink.Max.Y; y++ {
+		for x := ink.Min.X; x < ink.Max.X; x++ {
+			sx := (x - ink.Min.X) * mw / max(1, ink.Dx())
+			sy := (y - ink.Min.Y) * mh / max(1, ink.Dy())
+			if mask.AlphaAt(sx, sy).A < 128 {
+				continue
+			}
+			dst.Pix[dst.PixOffset(x, y)] = 0
+			if g.style.bold && x+1 < ink.Max.X {
+				dst.Pix[dst.PixOffset(x+1, y)] = 0
+			}
+		}
+	}
+
+	if g.style.underline > 0 {
+		line := image.Rect(cell.Min.X, cell.Max.Y-g.style.underline, cell.Max.X, cell.Max.Y)
+		draw.Draw(dst, line, image.Black, image.Point{}, draw.Src)
+	}
+	if g.style.reverse {
+		for y := cell.Min.Y; y < cell.Max.Y; y++ {
+			for x := cell.Min.X; x < cell.Max.X; x++ {
+				o := dst.PixOffset(x, y)
+				dst.Pix[o] = 255 - dst.Pix[o]
+			}
+		}
+	}
+}
+
+// rotate90 turns a mask 90° clockwise, keeping its size so it still fills the cell.
+func rotate90(src *image.Alpha) *image.Alpha {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewAlpha(src.Bounds())
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Source point for (x, y) in a clockwise turn, stretched back to w x h
+			sx := y * w / h
+			sy := (w - 1 - x) * h / w
+			dst.SetAlpha(x, y, src.AlphaAt(sx, sy))
+		}
+	}
+	return dst
+}
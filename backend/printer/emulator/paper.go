@@ -0,0 +1,66 @@
+package emulator
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// paper is a roll that grows downwards as lines are printed.
+type paper struct {
+	width int // printable dots
+	img   *image.Gray
+	y     int // top of the next line
+}
+
+func newPaper(width int) *paper {
+	p := &paper{width: width}
+	p.img = image.NewGray(image.Rect(0, 0, width, 1024))
+	draw.Draw(p.img, p.img.Bounds(), image.White, image.Point{}, draw.Src)
+	return p
+}
+
+// ensure grows the roll to at least h dots.
+func (p *paper) ensure(h int) {
+	if h <= p.img.Bounds().Dy() {
+		return
+	}
+	grown := image.NewGray(image.Rect(0, 0, p.width, max(h, 2*p.img.Bounds().Dy())))
+	draw.Draw(grown, grown.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(grown, p.img.Bounds(), p.img, image.Point{}, draw.Src)
+	p.img = grown
+}
+
+// blit draws src with its top-left corner at x on the current line. Only
+// black dots are copied, like a print head adding dots to the paper.
+func (p *paper) blit(src image.Image, x int) {
+	b := src.Bounds()
+	p.ensure(p.y + b.Dy())
+	r := image.Rect(x, p.y, x+b.Dx(), p.y+b.Dy()).Intersect(p.img.Bounds())
+	draw.DrawMask(p.img, r, image.Black, image.Point{}, inkMask{src}, b.Min, draw.Over)
+}
+
+// image returns the printed part of the roll with a margin around it.
+func (p *paper) image() *image.Gray {
+	out := image.NewGray(image.Rect(0, 0, p.width+2*paperPadding, p.y+2*paperPadding))
+	draw.Draw(out, out.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(out, image.Rect(paperPadding, paperPadding, paperPadding+p.width, paperPadding+p.y),
+		p.img, image.Point{}, draw.Src)
+	return out
+}
+
+// inkMask turns dark pixels of an image into an opaque mask.
+type inkMask struct {
+	image.Image
+}
+
+func (m inkMask) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (m inkMask) At(x, y int) color.Color {
+	if color.GrayModel.Convert(m.Image.At(x, y)).(color.Gray).Y < 128 {
+		return color.Opaque
+	}
+	return color.Transparent
+}
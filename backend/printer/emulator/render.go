@@ -0,0 +1,478 @@
+package emulator
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+
+	"ts-escpos/backend/printer"
+	"ts-escpos/backend/receipt"
+)
+
+const (
+	defaultDotWidth    = 576
+	defaultLineSpacing = 30 // dots; roughly 1/6 inch at 203 dpi
+	paperPadding       = 16 // blank dots around the printable area in the picture
+	tabWidth           = 8  // characters between default tab stops
+)
+
+// Options describes the paper a stream is rendered onto.
+type Options struct {
+	DotWidth int    // Printable width in dots, 576 (80mm) when zero
+	CodePage string // Character table in effect before any ESC t, CP437 when empty
+}
+
+// RenderPNG renders a stream and writes the picture as PNG. A truncated
+// stream is drawn up to the broken command.
+func RenderPNG(w io.Writer, data []byte, opts Options) error {
+	img, err := Render(data, opts)
+	if err != nil && !errors.Is(err, ErrTruncated) {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// Render draws the paper an ESC/POS stream would print. Commands that do not
+// put anything on paper (status requests, drawer kicks, buzzers) are skipped.
+// The image is returned even when the stream is truncated.
+func Render(data []byte, opts Options) (*image.Gray, error) {
+	cmds, decodeErr := Decode(data)
+
+	if opts.DotWidth <= 0 {
+		opts.DotWidth = defaultDotWidth
+	}
+	cp, err := printer.LookupCodePage(opts.CodePage)
+	if err != nil {
+		cp, _ = printer.LookupCodePage(printer.DefaultCodePage)
+	}
+
+	r := newRenderer(opts.DotWidth, cp)
+	for _, cmd := range cmds {
+		r.apply(cmd)
+	}
+	r.flushLine()
+	return r.paper.image(), decodeErr
+}
+
+// style is the text style in effect for a character.
+type style struct {
+	fontB      bool
+	bold       bool
+	width      int // multiplier, 1-8
+	height     int
+	underline  int
+	reverse    bool
+	upsideDown bool
+	rotate     bool
+}
+
+type glyph struct {
+	r     rune
+	style style
+}
+
+type renderer struct {
+	paper    *paper
+	fonts    *fonts
+	codePage printer.CodePage
+	defCP    printer.CodePage
+
+	style        style
+	align        string
+	lineSpacing  int
+	charSpacing  int
+	left, area   int
+	line         []glyph
+	lineGraphics int // height of ESC * strips already drawn on the current line
+
+	// Symbol and barcode settings
+	barcodeHeight, barcodeWidth int
+	hri                         byte
+	qrSize                      int
+	qrECC                       string
+	qrData                      []byte
+	pdfModule, pdfRow, pdfECC   int
+	pdfData                     []byte
+	graphicsBuffer              image.Image
+}
+
+func newRenderer(dotWidth int, cp printer.CodePage) *renderer {
+	r := &renderer{paper: newPaper(dotWidth), fonts: newFonts(), codePage: cp, defCP: cp}
+	r.reset()
+	return r
+}
+
+// reset applies ESC @.
+func (r *renderer) reset() {
+	r.style = style{width: 1, height: 1}
+	r.align = "left"
+	r.lineSpacing = defaultLineSpacing
+	r.charSpacing = 0
+	r.left, r.area = 0, r.paper.width
+	r.codePage = r.defCP
+	r.barcodeHeight, r.barcodeWidth, r.hri = 162, 3, 0
+	r.qrSize, r.qrECC = 3, "L"
+	r.pdfModule, r.pdfRow, r.pdfECC = 3, 3, 1
+}
+
+func (r *renderer) apply(cmd Command) {
+	arg := func(i int) int {
+		if i < len(cmd.Args) {
+			return int(cmd.Args[i])
+		}
+		return 0
+	}
+
+	switch cmd.Name {
+	case "TEXT":
+		for _, c := range r.codePage.Decode(cmd.Data) {
+			r.addGlyph(c)
+		}
+	case "HT":
+		n := len(r.line)
+		for i := 0; i < tabWidth-n%tabWidth; i++ {
+			r.addGlyph(' ')
+		}
+	case "LF", "FF":
+		r.newLine()
+	case "ESC @":
+		r.flushLine()
+		r.reset()
+	case "ESC t":
+		if cp, ok := printer.CodePageByNumber(byte(arg(0))); ok {
+			r.codePage = cp
+		}
+	case "ESC a":
+		r.align = [...]string{"left", "center", "right"}[min(arg(0)%48, 2)]
+	case "ESC E":
+		r.style.bold = arg(0)&1 != 0
+	case "ESC M":
+		r.style.fontB = arg(0)%48 == 1
+	case "ESC !":
+		n := arg(0)
+		r.style.fontB = n&0x01 != 0
+		r.style.bold = n&0x08 != 0
+		r.style.height = 1 + (n>>4)&1
+		r.style.width = 1 + (n>>5)&1
+		if n&0x80 != 0 {
+			r.style.underline = 1
+		} else {
+			r.style.underline = 0
+		}
+	case "GS !":
+		r.style.width = 1 + arg(0)&0x07
+		r.style.height = 1 + (arg(0)>>4)&0x07
+	case "ESC -":
+		r.style.underline = min(arg(0)%48, 2)
+	case "GS B":
+		r.style.reverse = arg(0)&1 != 0
+	case "ESC {":
+		r.style.upsideDown = arg(0)&1 != 0
+	case "ESC V":
+		r.style.rotate = arg(0)%48 == 1
+	case "ESC 3":
+		r.lineSpacing = arg(0)
+	case "ESC 2":
+		r.lineSpacing = defaultLineSpacing
+	case "ESC SP":
+		r.charSpacing = arg(0)
+	case "GS L":
+		r.flushLine()
+		r.left = min(arg(0)+arg(1)*256, r.paper.width-1)
+		r.area = min(r.area, r.paper.width-r.left)
+	case "GS W":
+		r.flushLine()
+		r.area = max(1, min(arg(0)+arg(1)*256, r.paper.width-r.left))
+	case "ESC d":
+		r.flushLine()
+		r.paper.y += arg(0) * r.lineSpacing
+	case "ESC J":
+		r.flushLine()
+		r.paper.y += arg(0)
+	case "GS V":
+		r.flushLine()
+		r.cut(arg(0) == 0 || arg(0) == 48 || arg(0) == 65)
+	case "GS v 0":
+		r.flushLine()
+		r.drawImage(rasterImage(cmd.Data, arg(1)+arg(2)*256, arg(3)+arg(4)*256, arg(0)))
+	case "ESC *":
+		r.columnStrip(arg(0), arg(1)+arg(2)*256, cmd.Data)
+	case "GS ( L", "GS 8 L":
+		r.bufferedGraphics(cmd.Args)
+	case "GS h":
+		r.barcodeHeight = max(1, arg(0))
+	case "GS w":
+		r.barcodeWidth = max(1, arg(0))
+	case "GS H":
+		r.hri = byte(arg(0) % 48)
+	case "GS k":
+		r.flushLine()
+		r.barcode(byte(arg(0)), cmd.Data)
+	case "GS ( k":
+		r.symbol(cmd.Args)
+	}
+}
+
+// addGlyph adds a character to the current line, wrapping when it is full.
+func (r *renderer) addGlyph(c rune) {
+	g := glyph{r: c, style: r.style}
+	if r.lineWidth()+r.glyphWidth(g) > r.area && len(r.line) > 0 {
+		r.newLine()
+	}
+	r.line = append(r.line, g)
+}
+
+func (r *renderer) glyphWidth(g glyph) int {
+	return (r.fonts.cellWidth(g.style.fontB) + r.charSpacing) * g.style.width
+}
+
+func (r *renderer) lineWidth() int {
+	w := 0
+	for _, g := range r.line {
+		w += r.glyphWidth(g)
+	}
+	return w
+}
+
+// newLine prints the current line and moves to the next one, as LF does.
+func (r *renderer) newLine() {
+	if len(r.line) == 0 {
+		r.paper.y += max(r.lineSpacing, r.lineGraphics)
+		r.lineGraphics = 0
+		return
+	}
+	r.flushLine()
+}
+
+// flushLine draws the buffered characters and advances by one line.
+func (r *renderer) flushLine() {
+	if len(r.line) == 0 {
+		if r.lineGraphics > 0 {
+			r.paper.y += r.lineGraphics
+			r.lineGraphics = 0
+		}
+		return
+	}
+
+	height := 0
+	for _, g := range r.line {
+		height = max(height, r.fonts.cellHeight(g.style.fontB)*g.style.height)
+	}
+	img := image.NewGray(image.Rect(0, 0, max(1, r.lineWidth()), height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	x := 0
+	for _, g := range r.line {
+		w := r.glyphWidth(g)
+		h := r.fonts.cellHeight(g.style.fontB) * g.style.height
+		cell := image.Rect(x, height-h, x+w, height)
+		r.fonts.draw(img, cell, g, r.charSpacing*g.style.width)
+		x += w
+	}
+
+	upsideDown := r.line[0].style.upsideDown
+	if upsideDown {
+		img = rotate180(img)
+	}
+	r.paper.blit(img, r.alignedX(img.Bounds().Dx()))
+	r.paper.y += max(r.lineSpacing, height)
+	r.line = r.line[:0]
+	r.lineGraphics = 0
+}
+
+// alignedX is the left edge of something w dots wide under the current alignment.
+func (r *renderer) alignedX(w int) int {
+	switch r.align {
+	case "center":
+		return r.left + max(0, (r.area-w)/2)
+	case "right":
+		return r.left + max(0, r.area-w)
+	}
+	return r.left
+}
+
+// drawImage prints a picture on its own, below the current position.
+func (r *renderer) drawImage(img image.Image) {
+	if img == nil || img.Bounds().Empty() {
+		return
+	}
+	r.paper.blit(img, r.alignedX(img.Bounds().Dx()))
+	r.paper.y += img.Bounds().Dy()
+}
+
+// cut marks the cut with a dashed line.
+func (r *renderer) cut(full bool) {
+	r.paper.y += 4
+	r.paper.ensure(r.paper.y + 1)
+	dash := 6
+	if full {
+		dash = 12
+	}
+	for x := 0; x < r.paper.img.Bounds().Dx(); x++ {
+		if (x/dash)%2 == 0 {
+			r.paper.img.SetGray(x, r.paper.y, color.Gray{Y: 0x80})
+		}
+	}
+	r.paper.y += 12
+}
+
+// rasterImage decodes GS v 0 data: widthBytes x rows, most significant bit on the left.
+func rasterImage(data []byte, widthBytes, rows, mode int) image.Image {
+	sx, sy := 1, 1
+	if mode%48 == 1 || mode%48 == 3 {
+		sx = 2
+	}
+	if mode%48 == 2 || mode%48 == 3 {
+		sy = 2
+	}
+	img := image.NewGray(image.Rect(0, 0, widthBytes*8*sx, rows*sy))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < widthBytes*8; x++ {
+			i := y*widthBytes + x/8
+			if i >= len(data) || data[i]&(0x80>>(x%8)) == 0 {
+				continue
+			}
+			draw.Draw(img, image.Rect(x*sx, y*sy, (x+1)*sx, (y+1)*sy), image.Black, image.Point{}, draw.Src)
+		}
+	}
+	return img
+}
+
+// columnStrip draws an ESC * bit image at the current line. 8-dot modes use
+// one byte per column, 24-dot modes three.
+func (r *renderer) columnStrip(mode, columns int, data []byte) {
+	dots, sx := 8, 2
+	if mode >= 32 {
+		dots = 24
+	}
+	if mode == 1 || mode == 33 {
+		sx = 1
+	}
+	bytesPerCol := dots / 8
+	img := image.NewGray(image.Rect(0, 0, columns*sx, dots))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for c := 0; c < columns; c++ {
+		for bit := 0; bit < dots; bit++ {
+			i := c*bytesPerCol + bit/8
+			if i < len(data) && data[i]&(0x80>>(bit%8)) != 0 {
+				draw.Draw(img, image.Rect(c*sx, bit, (c+1)*sx, bit+1), image.Black, image.Point{}, draw.Src)
+			}
+		}
+	}
+	r.paper.blit(img, r.alignedX(img.Bounds().Dx()))
+	r.lineGraphics = max(r.lineGraphics, dots)
+}
+
+// bufferedGraphics handles the GS ( L / GS 8 L functions that store raster
+// data in the graphics buffer (112) and print it (50).
+func (r *renderer) bufferedGraphics(p []byte) {
+	if len(p) < 2 {
+		return
+	}
+	switch p[1] {
+	case 0x70: // store
+		if len(p) < 10 {
+			return
+		}
+		w := int(p[6]) + int(p[7])*256
+		h := int(p[8]) + int(p[9])*256
+		r.graphicsBuffer = rasterImage(p[10:], (w+7)/8, h, 0)
+	case 0x32, 0x02: // print
+		r.flushLine()
+		r.drawImage(r.graphicsBuffer)
+		r.graphicsBuffer = nil
+	}
+}
+
+// barcode draws a GS k barcode with its human readable text.
+func (r *renderer) barcode(m byte, data []byte) {
+	symbology := map[byte]string{
+		2: receipt.BarcodeEAN13, 67: receipt.BarcodeEAN13,
+		4: receipt.BarcodeCODE39, 69: receipt.BarcodeCODE39,
+		5: receipt.BarcodeITF, 70: receipt.BarcodeITF,
+		73: receipt.BarcodeCODE128,
+	}[m]
+	text := string(data)
+	if symbology == receipt.BarcodeCODE128 {
+		// Drop the code set selector and undo '{' escaping
+		if len(text) >= 2 && text[0] == '{' {
+			text = text[2:]
+		}
+		text = strings.ReplaceAll(text, "{{", "{")
+	}
+
+	img, err := printer.BarcodeImage(symbology, text, r.barcodeWidth, r.barcodeHeight)
+	if symbology == "" || err != nil {
+		r.hriLine("[barcode " + text + "]")
+		return
+	}
+	if r.hri == 1 || r.hri == 3 {
+		r.hriLine(text)
+	}
+	r.drawImage(img)
+	if r.hri == 2 || r.hri == 3 {
+		r.hriLine(text)
+	}
+}
+
+// hriLine prints a line of plain Font A text, leaving the current style alone.
+func (r *renderer) hriLine(text string) {
+	saved := r.style
+	r.style = style{width: 1, height: 1}
+	for _, c := range text {
+		r.addGlyph(c)
+	}
+	r.flushLine()
+	r.style = saved
+}
+
+// symbol handles GS ( k for QR codes (cn 49) and PDF417 (cn 48).
+func (r *renderer) symbol(p []byte) {
+	if len(p) < 2 {
+		return
+	}
+	cn, fn, rest := p[0], p[1], p[2:]
+	switch {
+	case cn == 49 && fn == 67 && len(rest) > 0:
+		r.qrSize = int(rest[0])
+	case cn == 49 && fn == 69 && len(rest) > 0:
+		r.qrECC = map[byte]string{48: "L", 49: "M", 50: "Q", 51: "H"}[rest[0]]
+	case cn == 49 && fn == 80 && len(rest) > 0:
+		r.qrData = append([]byte(nil), rest[1:]...)
+	case cn == 49 && fn == 81:
+		r.flushLine()
+		if img, err := printer.QRCodeImage(string(r.qrData), r.qrECC, max(1, r.qrSize)); err == nil {
+			r.drawImage(img)
+		}
+	case cn == 48 && fn == 67 && len(rest) > 0:
+		r.pdfModule = int(rest[0])
+	case cn == 48 && fn == 68 && len(rest) > 0:
+		r.pdfRow = int(rest[0])
+	case cn == 48 && fn == 69 && len(rest) > 1:
+		r.pdfECC = max(0, int(rest[1])-48)
+	case cn == 48 && fn == 80 && len(rest) > 0:
+		r.pdfData = append([]byte(nil), rest[1:]...)
+	case cn == 48 && fn == 81:
+		r.flushLine()
+		img, err := printer.PDF417Image(string(r.pdfData), max(1, r.pdfECC), max(1, r.pdfModule), max(2, r.pdfRow), r.area)
+		if err == nil {
+			r.drawImage(img)
+		}
+	}
+}
+
+func rotate180(src *image.Gray) *image.Gray {
+	b := src.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			dst.SetGray(b.Dx()-1-x, b.Dy()-1-y, src.GrayAt(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	_ "golang.org/x/image/webp"
 
@@ -24,23 +25,165 @@ import (
 
 var _ receipt.Printer = (*EscposAdapter)(nil)
 
+// Images are sent in bands so tall ones do not overflow the printer's input buffer.
+const (
+	minBandHeight     = 24
+	maxBandHeight     = 256
+	defaultBandHeight = 128
+)
+
+// defaultDotWidth is the printable width of a 58mm head, used when neither the
+// profile nor the settings give one.
+const defaultDotWidth = 384
+
 // EscposAdapter implements receipt.Printer interface
 // It generates ESC/POS commands into a buffer
 type EscposAdapter struct {
-	buf *bytes.Buffer
+	buf      *bytes.Buffer
+	profile  Profile
+	layout   Layout
+	graphics string
+	band     int // rows of an image sent per graphics command
+	codePage CodePage
+	fallback string
+	image    receipt.ImageOptions
+	kicks    int // drawer kick pulses sent
+
+	// Current text style, needed to re-create it after a line is printed as an image
+	align        string
+	font         string
+	bold         bool
+	doubleStrike bool
+	width        uint8
+	height       uint8
+	underline    uint8
+	reverse      bool
+	upsideDown   bool
+	rotate       bool
+	lineSpacing  int // dots, 0 for the printer's default
+	charSpacing  int
+	areaWidth    int // print area set by the template, 0 for the layout's
+
+	// The line being written. It is sent as text as it arrives; if it turns out
+	// to hold characters the printer cannot render, everything from lineStart
+	// is replaced by a raster image of the line.
+	lineStart  int
+	line       []textRun
+	lineRaster bool
 }
 
 func NewEscposAdapter() *EscposAdapter {
+	return NewEscposAdapterWithSettings(Settings{})
+}
+
+// NewEscposAdapterWithSettings creates an adapter that encodes text for a
+// specific printer. An unknown code page falls back to the default.
+func NewEscposAdapterWithSettings(s Settings) *EscposAdapter {
+	profile, err := LookupProfile(s.Profile)
+	if err != nil {
+		fmt.Printf("Warning: %v, using %s\n", err, DefaultProfile)
+		profile, _ = LookupProfile(DefaultProfile)
+	}
+
+	cp, err := LookupCodePage(s.CodePage)
+	if err != nil {
+		fmt.Printf("Warning: %v, using %s\n", err, DefaultCodePage)
+		cp, _ = LookupCodePage(DefaultCodePage)
+	}
+
+	if !profile.SupportsCodePage(cp.Name) {
+		fmt.Printf("Warning: profile %s does not list code page %s\n", profile.Name, cp.Name)
+	}
+
+	graphics := s.Graphics
+	if graphics == "" {
+		graphics = profile.Graphics
+	}
+	switch graphics {
+	case GraphicsRaster, GraphicsColumn, GraphicsBuffer:
+	case "":
+		graphics = GraphicsRaster
+	default:
+		fmt.Printf("Warning: unknown graphics mode %q, using %s\n", graphics, GraphicsRaster)
+		graphics = GraphicsRaster
+	}
+
+	bandHeight := s.BandHeight
+	if bandHeight == 0 {
+		bandHeight = profile.BandHeight
+	}
+	if bandHeight == 0 {
+		bandHeight = defaultBandHeight
+	}
+	bandHeight = clamp(bandHeight, minBandHeight, maxBandHeight)
+
+	// A model's fixed head width beats the paper size; explicit settings beat both
+	var layout Layout
+	if s.Paper != "" {
+		paper, err := PaperLayout(s.Paper)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		layout = paper
+	}
+	layout = layout.Merge(profile.Layout).Merge(s.Layout).Resolve()
+
+	fallback := s.Fallback
+	if fallback == "" {
+		fallback = DefaultFallback
+	}
+
 	return &EscposAdapter{
-		buf: new(bytes.Buffer),
+		buf:      new(bytes.Buffer),
+		profile:  profile,
+		layout:   layout,
+		graphics: graphics,
+		band:     bandHeight,
+		codePage: cp,
+		fallback: fallback,
+		image:    s.Image,
 	}
 }
 
 func (e *EscposAdapter) Init() {
+	e.flushLine()
 	e.buf.Write([]byte{0x1B, 0x40}) // ESC @
+	// ESC @ resets the character table, so select ours again
+	e.buf.Write([]byte{0x1B, 0x74, e.codePage.Number}) // ESC t n
+	if e.layout.MarginLeft > 0 || e.layout.MarginRight > 0 {
+		e.writePrintArea(e.layout.MarginLeft, e.layout.PrintableWidth())
+	}
+	e.align, e.font, e.bold, e.doubleStrike, e.width, e.height = "left", "A", false, false, 0, 0
+	e.underline, e.reverse, e.upsideDown, e.rotate = 0, false, false, false
+	e.lineSpacing, e.charSpacing, e.areaWidth = 0, 0, 0
+	e.lineStart = e.buf.Len()
+}
+
+// Layout returns the resolved printable area of the printer.
+func (e *EscposAdapter) Layout() Layout {
+	return e.layout
+}
+
+// Columns returns the number of characters that fit on a line in the current
+// font, print area and character spacing.
+func (e *EscposAdapter) Columns() int {
+	cols := e.layout.ColumnsA
+	if e.font == "B" {
+		cols = e.layout.ColumnsB
+	}
+	if e.areaWidth == 0 && e.charSpacing == 0 {
+		return cols
+	}
+	cell := e.layout.PrintableWidth() / max(cols, 1)
+	return max(1, e.printableWidth()/(cell+e.charSpacing))
 }
 
 func (e *EscposAdapter) SetAlign(align string) {
+	e.align = align
+	e.writeAlign(align)
+}
+
+func (e *EscposAdapter) writeAlign(align string) {
 	switch align {
 	case "center":
 		e.buf.Write([]byte{0x1B, 0x61, 0x01})
@@ -52,6 +195,10 @@ func (e *EscposAdapter) SetAlign(align string) {
 }
 
 func (e *EscposAdapter) SetFont(font string) {
+	if !e.profile.HasFont(font) {
+		font = "A"
+	}
+	e.font = font
 	// 0 = Font A, 1 = Font B
 	if font == "B" {
 		e.buf.Write([]byte{0x1B, 0x4D, 0x01})
@@ -61,6 +208,7 @@ func (e *EscposAdapter) SetFont(font string) {
 }
 
 func (e *EscposAdapter) SetBold(bold bool) {
+	e.bold = bold
 	if bold {
 		e.buf.Write([]byte{0x1B, 0x45, 0x01})
 	} else {
@@ -69,6 +217,7 @@ func (e *EscposAdapter) SetBold(bold bool) {
 }
 
 func (e *EscposAdapter) SetDoubleStrike(enabled bool) {
+	e.doubleStrike = enabled
 	// ESC G n
 	if enabled {
 		e.buf.Write([]byte{0x1B, 0x47, 0x01})
@@ -78,6 +227,7 @@ func (e *EscposAdapter) SetDoubleStrike(enabled bool) {
 }
 
 func (e *EscposAdapter) SetSize(width, height uint8) {
+	e.width, e.height = width, height
 	// ESC ! n (Select print mode) or GS ! n (Select character size)
 	// GS ! n
 	// 0-7: Width, 4-7: Height (bits)
@@ -88,48 +238,275 @@ func (e *EscposAdapter) SetSize(width, height uint8) {
 }
 
 func (e *EscposAdapter) Write(data string) {
-	// Handle encoding if necessary. For now assume UTF-8/ASCII
-	// Some printers need specific codepages for non-ASCII.
-	e.buf.WriteString(data)
+	for {
+		i := strings.IndexByte(data, '\n')
+		part := data
+		if i >= 0 {
+			part = data[:i]
+		}
+
+		if part != "" {
+			e.line = append(e.line, e.textRun(part))
+			if !e.lineRaster && e.needsRaster(part) {
+				e.lineRaster = true
+			}
+			if !e.lineRaster {
+				// Input is UTF-8; the printer only understands the selected single-byte code page
+				e.buf.Write(e.codePage.Encode(part, e.fallback))
+			}
+		}
+
+		if i < 0 {
+			return
+		}
+		if e.lineRaster {
+			e.flushLine()
+		} else {
+			e.buf.WriteByte('\n')
+			e.resetLine()
+		}
+		data = data[i+1:]
+	}
+}
+
+// WriteRaster prints text as an image regardless of whether the code page
+// could encode it, using the current font, size and alignment.
+func (e *EscposAdapter) WriteRaster(data string) {
+	e.flushLine()
+	for _, line := range strings.Split(strings.TrimSuffix(data, "\n"), "\n") {
+		e.line = []textRun{e.textRun(line)}
+		e.lineRaster = true
+		e.flushLine()
+	}
+}
+
+func (e *EscposAdapter) textRun(text string) textRun {
+	return textRun{text: text, bold: e.bold, font: e.font, width: e.width, height: e.height,
+		underline: e.underline, reverse: e.reverse}
+}
+
+// needsRaster reports whether text holds characters that the code page cannot
+// encode or transliterate but one of the raster fonts can draw.
+func (e *EscposAdapter) needsRaster(text string) bool {
+	for _, r := range text {
+		if e.codePage.CanEncode(r) {
+			continue
+		}
+		if _, ok := transliterations[r]; ok {
+			continue
+		}
+		if hasRasterGlyph(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// flushLine completes a pending line that has to be printed as an image.
+// Lines sent as plain text need no action; the printer holds them until
+// the next newline, feed or cut.
+func (e *EscposAdapter) flushLine() {
+	if e.lineRaster && len(e.line) > 0 {
+		// Drop the text (and style commands) already written for this line
+		e.buf.Truncate(e.lineStart)
+
+		img, rtl := rasterizeText(e.line)
+		if e.upsideDown {
+			// ESC { does not turn images, so the line is turned before it is sent
+			img = rotate180(img)
+		}
+		align := e.align
+		if rtl && (align == "" || align == "left") {
+			// Right-to-left text starts at the right margin
+			align = "right"
+		}
+		e.writeAlign(align)
+		e.printGraphics(img)
+		e.restoreStyle()
+	}
+	e.resetLine()
+}
+
+func (e *EscposAdapter) resetLine() {
+	e.line = e.line[:0]
+	e.lineRaster = false
+	e.lineStart = e.buf.Len()
+}
+
+// restoreStyle re-sends the current text style, which may have been
+// discarded together with a line that was replaced by an image.
+func (e *EscposAdapter) restoreStyle() {
+	e.writeAlign(e.align)
+	if e.font == "B" {
+		e.buf.Write([]byte{0x1B, 0x4D, 0x01})
+	} else {
+		e.buf.Write([]byte{0x1B, 0x4D, 0x00})
+	}
+	if e.bold {
+		e.buf.Write([]byte{0x1B, 0x45, 0x01})
+	} else {
+		e.buf.Write([]byte{0x1B, 0x45, 0x00})
+	}
+	if e.doubleStrike {
+		e.buf.Write([]byte{0x1B, 0x47, 0x01})
+	} else {
+		e.buf.Write([]byte{0x1B, 0x47, 0x00})
+	}
+	e.buf.Write([]byte{0x1D, 0x21, (e.height << 4) | e.width})
+	e.buf.Write([]byte{0x1B, 0x2D, e.underline})
+	e.buf.Write([]byte{0x1D, 0x42, boolByte(e.reverse)})
+	e.buf.Write([]byte{0x1B, 0x7B, boolByte(e.upsideDown)})
+	e.buf.Write([]byte{0x1B, 0x56, boolByte(e.rotate)})
+	e.buf.Write([]byte{0x1B, 0x20, byte(e.charSpacing)})
 }
 
 func (e *EscposAdapter) Feed(n uint8) {
+	e.flushLine()
 	// ESC d n
 	e.buf.Write([]byte{0x1B, 0x64, n})
 }
 
 func (e *EscposAdapter) Cut() {
-	// GS V m
-	// 66: Feed paper to (cutting position + n x vertical motion unit) and perform a partial cut
-	e.buf.Write([]byte{0x1D, 0x56, 0x42, 0x00})
+	e.flushLine()
+	// GS V m n
+	switch e.profile.Cut {
+	case CutNone:
+		// Tear-off printers: the preceding feed already clears the tear bar
+	case CutFull:
+		// 65: Feed paper to (cutting position + n x vertical motion unit) and perform a full cut
+		e.buf.Write([]byte{0x1D, 0x56, 0x41, 0x00})
+	default:
+		// 66: Feed paper to (cutting position + n x vertical motion unit) and perform a partial cut
+		e.buf.Write([]byte{0x1D, 0x56, 0x42, 0x00})
+	}
+}
+
+// Profile returns the capability profile the adapter was created with.
+func (e *EscposAdapter) Profile() Profile {
+	return e.profile
 }
 
-func (e *EscposAdapter) PrintQRCode(data string) {
+func (e *EscposAdapter) PrintQRCode(data string, opts receipt.QRCodeOptions) {
 	if data == "" {
 		return
 	}
+	e.flushLine()
 
-	// Native QR code commands often fail on generic printers.
-	// Falling back to raster mode for cross-printer compatibility.
+	moduleSize := opts.Size
+	if moduleSize <= 0 {
+		moduleSize = defaultQRModuleSize
+	} else if moduleSize > 16 {
+		moduleSize = 16
+	}
 
-	// Create QR code
-	// Size 256 is reasonable for most papers (approx 32mm width)
-	qr, err := qrcode.New(data, qrcode.Medium)
+	// Native QR code commands often fail on generic printers,
+	// so they are only used when the printer profile vouches for them.
+	if e.profile.NativeQR && len(data) <= qrMaxBytes(opts.ECC) {
+		e.printNativeQRCode(data, moduleSize, opts.ECC)
+		return
+	}
+
+	// Raster fallback for cross-printer compatibility
+	qr, err := qrcode.New(data, qrRecoveryLevel(opts.ECC))
 	if err != nil {
 		fmt.Printf("Error creating QR code: %v\n", err)
 		return
 	}
+	// Shrink the modules rather than run off the paper; the bitmap includes
+	// the quiet zone
+	if n := len(qr.Bitmap()); n*moduleSize > e.printableWidth() {
+		if n > e.printableWidth() {
+			fmt.Printf("Error printing QR code: %d modules do not fit %d dots\n", n, e.printableWidth())
+			return
+		}
+		moduleSize = e.printableWidth() / n
+	}
+	e.printGraphics(qr.Image(-moduleSize))
+}
+
+// QRCodeImage draws a QR code with each module moduleSize dots wide.
+func QRCodeImage(data, ecc string, moduleSize int) (image.Image, error) {
+	qr, err := qrcode.New(data, qrRecoveryLevel(ecc))
+	if err != nil {
+		return nil, err
+	}
+	// A negative size makes each module exactly that many dots wide
+	return qr.Image(-moduleSize), nil
+}
+
+const (
+	// 6 dots per module gives roughly the 256px code printed before module sizes were configurable
+	defaultQRModuleSize = 6
+)
+
+// printNativeQRCode stores the data in the printer's symbol buffer and prints it
+// using the GS ( k functions for QR Code (cn = 49).
+func (e *EscposAdapter) printNativeQRCode(data string, moduleSize int, ecc string) {
+	// Function 165: select model 2
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00})
+	// Function 167: module size in dots
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x43, byte(moduleSize)})
+	// Function 169: error correction level (48=L, 49=M, 50=Q, 51=H)
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x45, qrECCByte(ecc)})
+
+	// Function 180: store data; pL pH count the 3 bytes cn fn m as well
+	n := len(data) + 3
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, byte(n % 256), byte(n / 256), 0x31, 0x50, 0x30})
+	e.buf.WriteString(data)
+
+	// Function 181: print the stored symbol
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x51, 0x30})
+}
+
+func qrECCByte(ecc string) byte {
+	switch strings.ToUpper(ecc) {
+	case "L":
+		return 48
+	case "Q":
+		return 50
+	case "H":
+		return 51
+	default: // M
+		return 49
+	}
+}
+
+// qrMaxBytes is the most data a version 40 QR code holds in byte mode, the
+// mode GS ( k stores data in, at each error correction level.
+func qrMaxBytes(ecc string) int {
+	switch strings.ToUpper(ecc) {
+	case "L":
+		return 2953
+	case "Q":
+		return 1663
+	case "H":
+		return 1273
+	default: // M
+		return 2331
+	}
+}
 
-	img := qr.Image(256)
-	e.printGraphics(img)
+func qrRecoveryLevel(ecc string) qrcode.RecoveryLevel {
+	switch strings.ToUpper(ecc) {
+	case "L":
+		return qrcode.Low
+	case "Q":
+		return qrcode.High
+	case "H":
+		return qrcode.Highest
+	default: // M
+		return qrcode.Medium
+	}
 }
 
 // PrintImage downloads (or uses cache), resizes, dithers, and prints an image from a URL
-// It assumes a max width of 384 dots (typical for 58mm, looks ok centered on 80mm).
-func (e *EscposAdapter) PrintImage(urlStr string) {
+// Images wider than the printable width are scaled down to fit.
+// opts overrides the printer's image settings for this image.
+func (e *EscposAdapter) PrintImage(urlStr string, opts receipt.ImageOptions) {
 	if urlStr == "" {
 		return
 	}
+	e.flushLine()
 
 	img, err := getImageFromURL(urlStr)
 	if err != nil {
@@ -137,27 +514,95 @@ func (e *EscposAdapter) PrintImage(urlStr string) {
 		return
 	}
 
-	// Resize if necessary (max width 384 for broad compatibility)
-	maxWidth := uint(384)
+	// Resize if necessary
+	maxWidth := uint(e.printableWidth())
 	if img.Bounds().Dx() > int(maxWidth) {
 		img = resize.Resize(maxWidth, 0, img, resize.Lanczos3)
 	}
 
-	e.printGraphics(img)
+	e.printGraphics(ditherImage(img, mergeImageOptions(opts, e.image)))
 }
 
+// printGraphics prints a black and white image with the command the printer supports.
 func (e *EscposAdapter) printGraphics(img image.Image) {
+	if e.graphics == GraphicsColumn {
+		e.printColumnGraphics(img)
+		return
+	}
+
 	// Convert to monochrome raster
 	rasterData, widthBytes, height := convertToRaster(img)
 
-	// GS v 0 m xL xH yL yH d1...dk
-	// m = 0 (Normal)
-	// xL, xH = bytes horizontal
-	// yL, yH = dots vertical
-	e.buf.Write([]byte{0x1D, 0x76, 0x30, 0x00})
-	e.buf.Write([]byte{byte(widthBytes % 256), byte(widthBytes / 256)})
-	e.buf.Write([]byte{byte(height % 256), byte(height / 256)})
-	e.buf.Write(rasterData)
+	for top := 0; top < height; top += e.band {
+		rows := min(e.band, height-top)
+		band := rasterData[top*widthBytes : (top+rows)*widthBytes]
+		if e.graphics == GraphicsBuffer {
+			e.printBufferedGraphics(band, widthBytes, rows)
+			continue
+		}
+
+		// GS v 0 m xL xH yL yH d1...dk
+		// m = 0 (Normal)
+		// xL, xH = bytes horizontal
+		// yL, yH = dots vertical
+		e.buf.Write([]byte{0x1D, 0x76, 0x30, 0x00})
+		e.buf.Write([]byte{byte(widthBytes % 256), byte(widthBytes / 256)})
+		e.buf.Write([]byte{byte(rows % 256), byte(rows / 256)})
+		e.buf.Write(band)
+	}
+}
+
+// printBufferedGraphics stores one band in the graphics print buffer
+// (GS ( L / GS 8 L function 112) and prints it (function 50).
+func (e *EscposAdapter) printBufferedGraphics(data []byte, widthBytes, rows int) {
+	// m fn a bx by c xL xH yL yH: monochrome, 1x1 scale, colour 1
+	width := widthBytes * 8
+	params := []byte{0x30, 0x70, 0x30, 0x01, 0x01, 0x31,
+		byte(width % 256), byte(width / 256), byte(rows % 256), byte(rows / 256)}
+
+	n := len(params) + len(data)
+	if n <= 0xFFFF {
+		// GS ( L pL pH
+		e.buf.Write([]byte{0x1D, 0x28, 0x4C, byte(n), byte(n >> 8)})
+	} else {
+		// GS 8 L p1 p2 p3 p4 for bands over 64KB
+		e.buf.Write([]byte{0x1D, 0x38, 0x4C, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)})
+	}
+	e.buf.Write(params)
+	e.buf.Write(data)
+
+	// GS ( L pL pH m fn: print the buffered graphics
+	e.buf.Write([]byte{0x1D, 0x28, 0x4C, 0x02, 0x00, 0x30, 0x32})
+}
+
+// printColumnGraphics prints an image as 24-dot high strips with
+// ESC * 33 (24-dot double density), for printers that ignore GS v 0.
+func (e *EscposAdapter) printColumnGraphics(img image.Image) {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+
+	// ESC 3 n: advance exactly one strip per line so strips join without gaps
+	e.buf.Write([]byte{0x1B, 0x33, 24})
+	for top := 0; top < bounds.Dy(); top += 24 {
+		// ESC * m nL nH d1...dk, 3 bytes per column, most significant bit on top
+		e.buf.Write([]byte{0x1B, 0x2A, 33, byte(width % 256), byte(width / 256)})
+		for x := 0; x < width; x++ {
+			var col [3]byte
+			for bit := 0; bit < 24; bit++ {
+				y := top + bit
+				if y >= bounds.Dy() {
+					break
+				}
+				if isDark(img.At(x+bounds.Min.X, y+bounds.Min.Y)) {
+					col[bit/8] |= 0x80 >> (bit % 8)
+				}
+			}
+			e.buf.Write(col[:])
+		}
+		e.buf.WriteByte('\n')
+	}
+	// Back to the line spacing for text
+	e.writeLineSpacing()
 }
 
 func getImageFromURL(urlStr string) (image.Image, error) {
@@ -218,12 +663,8 @@ func convertToRaster(img image.Image) ([]byte, int, int) {
 
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			c := img.At(x+bounds.Min.X, y+bounds.Min.Y)
-			g := color.GrayModel.Convert(c).(color.Gray)
-			// Thresholding (128) - 1 is black (print), 0 is white (no print)
 			// In ESC/POS raster, 1 means print dot.
-			// Light pixels (high value) are white. Dark pixels (low value) are black.
-			if g.Y < 128 {
+			if isDark(img.At(x+bounds.Min.X, y+bounds.Min.Y)) {
 				byteIndex := (y * widthBytes) + (x / 8)
 				bitIndex := 7 - (x % 8)
 				data[byteIndex] |= (1 << bitIndex)
@@ -233,6 +674,13 @@ func convertToRaster(img image.Image) ([]byte, int, int) {
 	return data, widthBytes, height
 }
 
+// isDark thresholds a pixel at 128 - light pixels (high value) are white,
+// dark pixels (low value) are black.
+func isDark(c color.Color) bool {
+	return color.GrayModel.Convert(c).(color.Gray).Y < 128
+}
+
 func (e *EscposAdapter) GetBytes() []byte {
+	e.flushLine()
 	return e.buf.Bytes()
 }
@@ -0,0 +1,28 @@
+package printer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"ts-escpos/backend/receipt"
+)
+
+func TestQRCodeFitsPaper(t *testing.T) {
+	// A GST e-invoice QR carries a signed token of about 900 bytes
+	data := strings.Repeat("eyJhbGciOiJSUzI1NiIsImtpZCI6IkVEQzU3REUxMzU4QjMwMEJBOUY3OTM0MEE2Njk2ODMxRjNDODUwNDciLCJ0eXAiOiJKV1QifQ", 9)
+	for _, paper := range []string{"58mm", "80mm"} {
+		e := NewEscposAdapterWithSettings(Settings{Paper: paper})
+		e.PrintQRCode(data, receipt.QRCodeOptions{})
+		out := e.GetBytes()
+		// GS v 0 m xL xH yL yH: raster bit image, width in bytes
+		i := bytes.Index(out, []byte{0x1D, 0x76, 0x30})
+		if i < 0 {
+			t.Fatalf("%s: no raster image", paper)
+		}
+		width := (int(out[i+4]) + int(out[i+5])<<8) * 8
+		if limit := e.printableWidth(); width > limit {
+			t.Errorf("%s: QR code is %d dots wide, paper prints %d", paper, width, limit)
+		}
+	}
+}
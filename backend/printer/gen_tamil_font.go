@@ -0,0 +1,298 @@
+//go:build ignore
+
+// This program generates fonts/NotoSansTamil-Regular.ttf. Run it with
+// "go generate" in backend/printer.
+//
+// The source is Noto Sans Tamil MN 2.000 (Google, SIL Open Font License),
+// the copy HarfBuzz keeps as a test font and go-text republishes in
+// github.com/go-text/typesetting-utils:
+//
+//	harfbuzz/harfbuzz_reference/in-house/fonts/e6185e88b04432fbf373594d5971686bb7dd698d.ttf
+//	sha256 4f306d2c9aea996578b62e064739fd2fa96bfa11eeb16cb7b6d1bea8a2ca2d7b
+//
+// Its ligatures are in an AAT morx table, on which the go-text shaper
+// panics for some lines (e.g. "கொத்து பரோட்டா"). The program removes morx,
+// and DSIG which no longer matches, and adds a GSUB "pres" lookup with the
+// same ligatures, found by glyph name: consonant + i, ii, u and uu signs,
+// k.ssa, k.ssa + ii, and shri. The glyphs, metrics and hinting are
+// unchanged. The output is
+//
+//	sha256 a0d45fcc186643de5b13842df7ff29b747f4cf790fdc33eca260014fcf3cc2b3
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+)
+
+const (
+	sourceModule = "github.com/go-text/typesetting-utils@v0.0.0-20260419141703-4ffe8874dabc"
+	sourceFile   = "harfbuzz/harfbuzz_reference/in-house/fonts/e6185e88b04432fbf373594d5971686bb7dd698d.ttf"
+	sourceSHA256 = "4f306d2c9aea996578b62e064739fd2fa96bfa11eeb16cb7b6d1bea8a2ca2d7b"
+	output       = "fonts/NotoSansTamil-Regular.ttf"
+)
+
+// ligature replaces a sequence of glyphs with one.
+type ligature struct {
+	glyph      uint16
+	components []uint16
+}
+
+func main() {
+	log.SetFlags(0)
+	data, err := os.ReadFile(sourcePath())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != sourceSHA256 {
+		log.Fatalf("%s does not have the expected checksum", sourceFile)
+	}
+
+	tables := readTables(data)
+	ligatures := tamilLigatures(data, int(binary.BigEndian.Uint16(tables["maxp"][4:])))
+	delete(tables, "morx")
+	delete(tables, "DSIG")
+	tables["GSUB"] = gsubTable(ligatures)
+
+	out := writeFont(data[:4], tables)
+	if err := os.WriteFile(output, out, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	sum := sha256.Sum256(out)
+	fmt.Printf("%s: %d ligatures, sha256 %x\n", output, len(ligatures), sum)
+}
+
+// sourcePath finds the source font in the module cache, downloading the
+// module if needed.
+func sourcePath() string {
+	cmd := exec.Command("go", "mod", "download", "-json", sourceModule)
+	cmd.Stderr = os.Stderr
+	js, err := cmd.Output()
+	if err != nil {
+		log.Fatalf("go mod download %s: %v", sourceModule, err)
+	}
+	var mod struct{ Dir string }
+	if err := json.Unmarshal(js, &mod); err != nil {
+		log.Fatal(err)
+	}
+	return filepath.Join(mod.Dir, filepath.FromSlash(sourceFile))
+}
+
+// readTables returns the tables of a TrueType font by tag.
+func readTables(data []byte) map[string][]byte {
+	tables := make(map[string][]byte)
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < n; i++ {
+		record := data[12+16*i:]
+		offset, length := binary.BigEndian.Uint32(record[8:]), binary.BigEndian.Uint32(record[12:])
+		tables[string(record[:4])] = append([]byte(nil), data[offset:offset+length]...)
+	}
+	return tables
+}
+
+// tamilLigatures lists the ligatures of the font by the names of its glyphs,
+// e.g. "ka_uMatra-tamil" for ka + u sign.
+func tamilLigatures(data []byte, numGlyphs int) []ligature {
+	loader, err := ot.NewLoader(bytes.NewReader(data))
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := font.NewFont(loader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	face := font.NewFace(f)
+	glyphs := make(map[string]uint16, numGlyphs)
+	for g := 0; g < numGlyphs; g++ {
+		glyphs[face.GlyphName(font.GID(g))] = uint16(g)
+	}
+	glyph := func(name string) uint16 {
+		g, ok := glyphs[name]
+		if !ok {
+			log.Fatalf("no glyph %s", name)
+		}
+		return g
+	}
+
+	var ligatures []ligature
+	vowelSign := regexp.MustCompile(`^([a-z]+)_(i|ii|u|uu)Matra-tamil$`)
+	for name, g := range glyphs {
+		if m := vowelSign.FindStringSubmatch(name); m != nil {
+			ligatures = append(ligatures, ligature{g, []uint16{glyph(m[1] + "-tamil"), glyph(m[2] + "Matra-tamil")}})
+		}
+	}
+	ka, pulli, ssa, ra, ii := glyph("ka-tamil"), glyph("pulli-tamil"), glyph("ssa-tamil"), glyph("ra-tamil"), glyph("iiMatra-tamil")
+	shri := glyph("sh_ra_iiMatra-tamil")
+	ligatures = append(ligatures,
+		ligature{glyph("k_ssa-tamil"), []uint16{ka, pulli, ssa}},
+		ligature{glyph("k_ssa_iiMatra-tamil"), []uint16{ka, pulli, ssa, ii}},
+		// Shri is written with sha or sa
+		ligature{shri, []uint16{glyph("sha-tamil"), pulli, ra, ii}},
+		ligature{shri, []uint16{glyph("sa-tamil"), pulli, ra, ii}},
+	)
+	return ligatures
+}
+
+func u16(b []byte, v int) []byte { return binary.BigEndian.AppendUint16(b, uint16(v)) }
+
+// gsubTable builds a GSUB table applying the ligatures in one "pres" lookup
+// for the default, Tamil and Tamil v2 scripts.
+func gsubTable(ligatures []ligature) []byte {
+	// Ligature sets by first glyph, longest ligature first
+	sets := make(map[uint16][]ligature)
+	for _, l := range ligatures {
+		sets[l.components[0]] = append(sets[l.components[0]], l)
+	}
+	var firsts []int
+	for g := range sets {
+		firsts = append(firsts, int(g))
+	}
+	sort.Ints(firsts)
+
+	var ligatureSets [][]byte
+	for _, first := range firsts {
+		set := sets[uint16(first)]
+		sort.Slice(set, func(i, j int) bool {
+			if len(set[i].components) != len(set[j].components) {
+				return len(set[i].components) > len(set[j].components)
+			}
+			return set[i].glyph < set[j].glyph
+		})
+		head := u16(nil, len(set))
+		var body []byte
+		for _, l := range set {
+			head = u16(head, 2+2*len(set)+len(body))
+			body = u16(body, int(l.glyph))
+			body = u16(body, len(l.components))
+			for _, c := range l.components[1:] {
+				body = u16(body, int(c))
+			}
+		}
+		ligatureSets = append(ligatureSets, append(head, body...))
+	}
+
+	coverage := u16(nil, 1)
+	coverage = u16(coverage, len(firsts))
+	for _, first := range firsts {
+		coverage = u16(coverage, first)
+	}
+
+	// LigatureSubstFormat1
+	headLen := 6 + 2*len(ligatureSets)
+	subtable := u16(nil, 1)
+	subtable = u16(subtable, headLen)
+	subtable = u16(subtable, len(ligatureSets))
+	var body []byte
+	for _, set := range ligatureSets {
+		subtable = u16(subtable, headLen+len(coverage)+len(body))
+		body = append(body, set...)
+	}
+	subtable = append(subtable, coverage...)
+	subtable = append(subtable, body...)
+
+	// Lookup type 4 (ligature), no flags, one subtable
+	lookupList := u16(nil, 1)
+	lookupList = u16(lookupList, 4)
+	lookupList = u16(lookupList, 4)
+	lookupList = u16(lookupList, 0)
+	lookupList = u16(lookupList, 1)
+	lookupList = u16(lookupList, 8)
+	lookupList = append(lookupList, subtable...)
+
+	featureList := u16(nil, 1)
+	featureList = append(featureList, "pres"...)
+	featureList = u16(featureList, 8)
+	featureList = u16(featureList, 0) // No feature parameters
+	featureList = u16(featureList, 1)
+	featureList = u16(featureList, 0)
+
+	// A default language system with the one feature
+	script := u16(nil, 4)
+	script = u16(script, 0)
+	script = u16(script, 0)
+	script = u16(script, 0xFFFF) // No required feature
+	script = u16(script, 1)
+	script = u16(script, 0)
+	tags := []string{"DFLT", "taml", "tml2"}
+	scriptList := u16(nil, len(tags))
+	for i, tag := range tags {
+		scriptList = append(scriptList, tag...)
+		scriptList = u16(scriptList, 2+6*len(tags)+i*len(script))
+	}
+	for range tags {
+		scriptList = append(scriptList, script...)
+	}
+
+	gsub := u16(nil, 1)
+	gsub = u16(gsub, 0)
+	gsub = u16(gsub, 10)
+	gsub = u16(gsub, 10+len(scriptList))
+	gsub = u16(gsub, 10+len(scriptList)+len(featureList))
+	gsub = append(gsub, scriptList...)
+	gsub = append(gsub, featureList...)
+	return append(gsub, lookupList...)
+}
+
+// checksum is the TrueType sum of a table, padded to four bytes.
+func checksum(b []byte) uint32 {
+	padded := append(append([]byte(nil), b...), make([]byte, (4-len(b)%4)%4)...)
+	var sum uint32
+	for i := 0; i < len(padded); i += 4 {
+		sum += binary.BigEndian.Uint32(padded[i:])
+	}
+	return sum
+}
+
+// writeFont lays out the tables in tag order with their checksums and sets
+// the font checksum in head.
+func writeFont(version []byte, tables map[string][]byte) []byte {
+	binary.BigEndian.PutUint32(tables["head"][8:], 0)
+	var tags []string
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	selector := 0
+	for 1<<(selector+1) <= n {
+		selector++
+	}
+	out := append([]byte(nil), version...)
+	out = u16(out, n)
+	out = u16(out, 16<<selector)
+	out = u16(out, selector)
+	out = u16(out, 16*n-16<<selector)
+
+	var body []byte
+	headAt := 0
+	for _, tag := range tags {
+		b := tables[tag]
+		at := 12 + 16*n + len(body)
+		if tag == "head" {
+			headAt = at
+		}
+		out = append(out, tag...)
+		out = binary.BigEndian.AppendUint32(out, checksum(b))
+		out = binary.BigEndian.AppendUint32(out, uint32(at))
+		out = binary.BigEndian.AppendUint32(out, uint32(len(b)))
+		body = append(body, b...)
+		body = append(body, make([]byte, (4-len(b)%4)%4)...)
+	}
+	out = append(out, body...)
+	binary.BigEndian.PutUint32(out[headAt+8:], 0xB1B0AFBA-checksum(out))
+	return out
+}
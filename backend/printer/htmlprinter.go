@@ -0,0 +1,114 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image/png"
+	"strings"
+)
+
+// HTMLPrinter renders a receipt as a standalone HTML page that looks like the
+// printed slip: a monospace column as wide as the paper, with pictures and
+// codes embedded as PNG images.
+type HTMLPrinter struct {
+	*document
+}
+
+func NewHTMLPrinter(s Settings) *HTMLPrinter {
+	return &HTMLPrinter{document: newDocument(documentLayout(s))}
+}
+
+func (h *HTMLPrinter) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+const htmlStyle = `body{background:#eee;margin:0;padding:16px}
+.receipt{background:#fff;margin:0 auto;padding:12px;font:14px/1.4 monospace;white-space:pre;box-shadow:0 1px 4px rgba(0,0,0,.2)}
+.receipt div{min-height:1.4em}
+.receipt img{image-rendering:pixelated;max-width:100%}
+.receipt hr{border:0;border-top:1px dashed #999;margin:8px -12px}
+.rev{background:#000;color:#fff}
+.dh{display:inline-block;transform:scaleY(2);margin:.35em 0}`
+
+func (h *HTMLPrinter) GetBytes() []byte {
+	cols := h.layout.ColumnsA
+	dots := h.layout.PrintableWidth()
+
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>Receipt</title>\n<style>")
+	sb.WriteString(htmlStyle)
+	fmt.Fprintf(&sb, "</style></head><body>\n<div class=\"receipt\" style=\"width:%dch\">\n", cols)
+	for _, b := range h.finish() {
+		switch {
+		case b.cut:
+			sb.WriteString("<hr>\n")
+		case b.image != nil:
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, b.image); err != nil {
+				fmt.Printf("Error encoding image: %v\n", err)
+				continue
+			}
+			// Scale by dots so a logo covers as much of the line as on paper
+			width := float64(b.image.Bounds().Dx()) * float64(cols) / float64(dots)
+			fmt.Fprintf(&sb, "<div style=\"text-align:%s\"><img src=\"data:image/png;base64,%s\" alt=\"%s\" style=\"width:%.1fch\"></div>\n",
+				cssAlign(b.align), base64.StdEncoding.EncodeToString(buf.Bytes()), html.EscapeString(b.alt), width)
+		default:
+			fmt.Fprintf(&sb, "<div style=\"text-align:%s\">", cssAlign(b.align))
+			for _, r := range b.runs {
+				sb.WriteString(htmlRun(r))
+			}
+			sb.WriteString("</div>\n")
+		}
+	}
+	sb.WriteString("</div>\n</body></html>\n")
+	return []byte(sb.String())
+}
+
+func cssAlign(align string) string {
+	switch align {
+	case "center", "right":
+		return align
+	}
+	return "left"
+}
+
+// htmlRun wraps text in a span carrying its styles.
+func htmlRun(r docRun) string {
+	text := html.EscapeString(r.text)
+	var class, style []string
+	if r.style.bold {
+		style = append(style, "font-weight:bold")
+	}
+	if r.style.underline {
+		style = append(style, "text-decoration:underline")
+	}
+	if r.style.font == "B" {
+		style = append(style, "font-size:.75em")
+	}
+	if r.style.width > 0 {
+		style = append(style, fmt.Sprintf("letter-spacing:%dch", r.style.width))
+	}
+	if r.style.reverse {
+		class = append(class, "rev")
+	}
+	if r.style.height > 0 {
+		class = append(class, "dh")
+	}
+	if len(class) == 0 && len(style) == 0 {
+		return text
+	}
+	var sb strings.Builder
+	sb.WriteString("<span")
+	if len(class) > 0 {
+		fmt.Fprintf(&sb, " class=\"%s\"", strings.Join(class, " "))
+	}
+	if len(style) > 0 {
+		fmt.Fprintf(&sb, " style=\"%s\"", strings.Join(style, ";"))
+	}
+	sb.WriteString(">")
+	sb.WriteString(text)
+	sb.WriteString("</span>")
+	return sb.String()
+}
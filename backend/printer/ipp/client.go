@@ -0,0 +1,313 @@
+package ipp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Printer states (printer-state).
+const (
+	PrinterIdle       = 3
+	PrinterProcessing = 4
+	PrinterStopped    = 5
+)
+
+// Job states (job-state).
+const (
+	JobPending    = 3
+	JobHeld       = 4
+	JobProcessing = 5
+	JobStopped    = 6
+	JobCanceled   = 7
+	JobAborted    = 8
+	JobCompleted  = 9
+)
+
+// Printer is a CUPS queue.
+type Printer struct {
+	Name         string
+	State        int      // PrinterIdle, PrinterProcessing or PrinterStopped
+	StateReasons []string // Keywords such as "media-empty-error" or "offline-report"
+	Accepting    bool     // Takes new jobs
+	MakeAndModel string
+	Info         string
+	Location     string
+}
+
+// Job is a job in a CUPS queue.
+type Job struct {
+	ID      int
+	Name    string
+	State   int // JobPending through JobCompleted
+	Printer string
+	User    string
+}
+
+// StatusError is an IPP error status returned by the server.
+type StatusError struct {
+	Op      uint16
+	Status  uint16
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("ipp: operation %#04x failed: %s (status %#04x)", e.Op, e.Message, e.Status)
+	}
+	return fmt.Sprintf("ipp: operation %#04x failed with status %#04x", e.Op, e.Status)
+}
+
+// NotAuthorized reports whether the server refused the operation to this user.
+func (e *StatusError) NotAuthorized() bool {
+	switch e.Status {
+	case 0x0401, 0x0402, 0x0403: // forbidden, not-authenticated, not-authorized
+		return true
+	}
+	return false
+}
+
+// NotFound reports whether the printer or job does not exist.
+func (e *StatusError) NotFound() bool {
+	return e.Status == 0x0406
+}
+
+// Client talks IPP/1.1 to a CUPS server.
+type Client struct {
+	host string // For printer URIs, e.g. "localhost:631"
+	base string // URL requests are posted under
+	user string
+	http *http.Client
+	id   atomic.Uint32
+}
+
+// cupsSockets are where CUPS listens locally on Linux and macOS.
+var cupsSockets = []string{"/run/cups/cups.sock", "/var/run/cups/cups.sock", "/private/var/run/cupsd"}
+
+// NewClient returns a client for a CUPS server given as a socket path
+// ("/run/cups/cups.sock"), "host[:port]" or an http:// or ipp:// URL. An
+// empty server means $CUPS_SERVER, else the local socket, else
+// localhost:631.
+func NewClient(server string) *Client {
+	if server == "" {
+		server = os.Getenv("CUPS_SERVER")
+	}
+	if server == "" {
+		server = "localhost:631"
+		for _, path := range cupsSockets {
+			if _, err := os.Stat(path); err == nil {
+				server = path
+				break
+			}
+		}
+	}
+
+	c := &Client{user: "anonymous"}
+	if u, err := user.Current(); err == nil {
+		c.user = u.Username
+	}
+	if strings.HasPrefix(server, "/") {
+		socket := server
+		c.host, c.base = "localhost", "http://localhost"
+		c.http = &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		}
+		return c
+	}
+
+	host := server
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "631")
+	}
+	c.host, c.base = host, "http://"+host
+	c.http = &http.Client{Timeout: 30 * time.Second}
+	return c
+}
+
+// Printers lists the CUPS queues with CUPS-Get-Printers.
+func (c *Client) Printers(ctx context.Context) ([]Printer, error) {
+	req := c.request(OpCUPSGetPrinters)
+	req.Group(TagOperation).Add(TagKeyword, "requested-attributes", printerAttributes...)
+	resp, err := c.do(ctx, "/", req, nil)
+	if err != nil {
+		return nil, err
+	}
+	var list []Printer
+	for _, g := range resp.GroupsOf(TagPrinter) {
+		list = append(list, printerFrom(g))
+	}
+	return list, nil
+}
+
+// Printer reads one queue's attributes with Get-Printer-Attributes.
+func (c *Client) Printer(ctx context.Context, name string) (Printer, error) {
+	req := c.printerRequest(OpGetPrinterAttributes, name)
+	req.Group(TagOperation).Add(TagKeyword, "requested-attributes", printerAttributes...)
+	resp, err := c.do(ctx, printerPath(name), req, nil)
+	if err != nil {
+		return Printer{}, err
+	}
+	return printerFrom(*resp.Group(TagPrinter)), nil
+}
+
+var printerAttributes = []interface{}{
+	"printer-name", "printer-state", "printer-state-reasons", "printer-is-accepting-jobs",
+	"printer-make-and-model", "printer-info", "printer-location",
+}
+
+func printerFrom(g Group) Printer {
+	return Printer{
+		Name:         g.String("printer-name"),
+		State:        g.Int("printer-state"),
+		StateReasons: g.Strings("printer-state-reasons"),
+		Accepting:    g.Bool("printer-is-accepting-jobs"),
+		MakeAndModel: g.String("printer-make-and-model"),
+		Info:         g.String("printer-info"),
+		Location:     g.String("printer-location"),
+	}
+}
+
+// PrintRaw submits a document that is passed to the printer unchanged and
+// returns the ID CUPS gave the job.
+func (c *Client) PrintRaw(ctx context.Context, printer, jobName string, data []byte) (int, error) {
+	req := c.printerRequest(OpPrintJob, printer)
+	op := req.Group(TagOperation)
+	op.Add(TagName, "job-name", jobName)
+	op.Add(TagMimeType, "document-format", "application/vnd.cups-raw")
+	resp, err := c.do(ctx, printerPath(printer), req, data)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Group(TagJob).Int("job-id"), nil
+}
+
+// Jobs lists a queue's jobs: those not completed yet, or with completed
+// the finished ones.
+func (c *Client) Jobs(ctx context.Context, printer string, completed bool) ([]Job, error) {
+	which := "not-completed"
+	if completed {
+		which = "completed"
+	}
+	req := c.printerRequest(OpGetJobs, printer)
+	op := req.Group(TagOperation)
+	op.Add(TagKeyword, "which-jobs", which)
+	op.Add(TagKeyword, "requested-attributes",
+		"job-id", "job-name", "job-state", "job-originating-user-name")
+	resp, err := c.do(ctx, printerPath(printer), req, nil)
+	if err != nil {
+		return nil, err
+	}
+	var list []Job
+	for _, g := range resp.GroupsOf(TagJob) {
+		list = append(list, Job{
+			ID:      g.Int("job-id"),
+			Name:    g.String("job-name"),
+			State:   g.Int("job-state"),
+			Printer: printer,
+			User:    g.String("job-originating-user-name"),
+		})
+	}
+	return list, nil
+}
+
+// CancelJob cancels one job of a queue.
+func (c *Client) CancelJob(ctx context.Context, printer string, id int) error {
+	req := c.printerRequest(OpCancelJob, printer)
+	req.Group(TagOperation).Add(TagInteger, "job-id", id)
+	_, err := c.do(ctx, printerPath(printer), req, nil)
+	return err
+}
+
+// PurgeJobs removes every job of a queue. CUPS only allows it to
+// administrators.
+func (c *Client) PurgeJobs(ctx context.Context, printer string) error {
+	_, err := c.do(ctx, printerPath(printer), c.printerRequest(OpPurgeJobs, printer), nil)
+	return err
+}
+
+func (c *Client) request(op uint16) *Message {
+	m := &Message{Code: op, RequestID: c.id.Add(1)}
+	g := m.Group(TagOperation)
+	// The charset and language must come first
+	g.Add(TagCharset, "attributes-charset", "utf-8")
+	g.Add(TagLanguage, "attributes-natural-language", "en")
+	return m
+}
+
+func (c *Client) printerRequest(op uint16, printer string) *Message {
+	m := c.request(op)
+	g := m.Group(TagOperation)
+	g.Add(TagURI, "printer-uri", "ipp://"+c.host+printerPath(printer))
+	g.Add(TagName, "requesting-user-name", c.user)
+	return m
+}
+
+func printerPath(name string) string {
+	return "/printers/" + url.PathEscape(name)
+}
+
+// do posts a request, followed by a document if given, and returns the
+// response when its status is a success.
+func (c *Client) do(ctx context.Context, path string, req *Message, document []byte) (*Message, error) {
+	var body bytes.Buffer
+	if err := req.Encode(&body); err != nil {
+		return nil, err
+	}
+	body.Write(document)
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+path, &body)
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/ipp")
+	hresp, err := c.http.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer hresp.Body.Close()
+	switch hresp.StatusCode {
+	case http.StatusOK:
+	// CUPS asks for credentials over HTTP, before reading the request
+	case http.StatusUnauthorized:
+		io.Copy(io.Discard, hresp.Body)
+		return nil, &StatusError{Op: req.Code, Status: 0x0402, Message: "HTTP " + hresp.Status}
+	case http.StatusForbidden:
+		io.Copy(io.Discard, hresp.Body)
+		return nil, &StatusError{Op: req.Code, Status: 0x0401, Message: "HTTP " + hresp.Status}
+	default:
+		io.Copy(io.Discard, hresp.Body)
+		return nil, fmt.Errorf("ipp: %s returned HTTP %s", path, hresp.Status)
+	}
+
+	resp, err := Decode(hresp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// Codes up to 0x00FF are successes, some with remarks such as
+	// attributes ignored
+	if resp.Code > 0x00FF {
+		return nil, &StatusError{Op: req.Code, Status: resp.Code, Message: resp.Group(TagOperation).String("status-message")}
+	}
+	return resp, nil
+}
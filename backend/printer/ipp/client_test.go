@@ -0,0 +1,336 @@
+package ipp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	m := &Message{Code: OpPrintJob, RequestID: 7}
+	op := m.Group(TagOperation)
+	op.Add(TagCharset, "attributes-charset", "utf-8")
+	op.Add(TagLanguage, "attributes-natural-language", "en")
+	op.Add(TagKeyword, "requested-attributes", "printer-name", "printer-state")
+	p := m.Group(TagPrinter)
+	p.Add(TagEnum, "printer-state", PrinterStopped)
+	p.Add(TagInteger, "queued-job-count", -1)
+	p.Add(TagBoolean, "printer-is-accepting-jobs", true)
+	p.Add(TagName, "printer-name", "Kitchen Printer")
+
+	var buf bytes.Buffer
+	if err := m.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("document")
+	br := bufio.NewReader(&buf)
+	got, err := Decode(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("decoded %+v, want %+v", got, m)
+	}
+	if rest, _ := io.ReadAll(br); string(rest) != "document" {
+		t.Errorf("document after the attributes = %q", rest)
+	}
+
+	g := got.Group(TagPrinter)
+	if g.Int("printer-state") != PrinterStopped || g.Int("queued-job-count") != -1 ||
+		!g.Bool("printer-is-accepting-jobs") || g.String("printer-name") != "Kitchen Printer" {
+		t.Errorf("printer group read back wrong: %+v", g)
+	}
+	if names := got.Group(TagOperation).Strings("requested-attributes"); !reflect.DeepEqual(names, []string{"printer-name", "printer-state"}) {
+		t.Errorf("requested-attributes = %q", names)
+	}
+}
+
+func TestDecodeSkipsCollections(t *testing.T) {
+	var b bytes.Buffer
+	b.Write([]byte{1, 1, 0, 0, 0, 0, 0, 1, TagPrinter})
+	attr := func(tag byte, name, value string) {
+		b.WriteByte(tag)
+		b.Write([]byte{byte(len(name) >> 8), byte(len(name))})
+		b.WriteString(name)
+		b.Write([]byte{byte(len(value) >> 8), byte(len(value))})
+		b.WriteString(value)
+	}
+	attr(TagName, "printer-name", "Bar")
+	attr(TagBeginCollection, "media-col-default", "")
+	attr(0x4A, "", "media-size")
+	attr(TagBeginCollection, "", "")
+	attr(0x4A, "", "x-dimension")
+	attr(TagInteger, "", "\x00\x00\x1b\x58")
+	attr(TagEndCollection, "", "")
+	attr(TagEndCollection, "", "")
+	attr(0x13, "printer-info", "") // no-value
+	attr(TagText, "printer-location", "Bar counter")
+	b.WriteByte(TagEnd)
+
+	m, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := m.Group(TagPrinter)
+	want := []Attribute{
+		{TagName, "printer-name", []interface{}{"Bar"}},
+		{0x13, "printer-info", []interface{}{nil}},
+		{TagText, "printer-location", []interface{}{"Bar counter"}},
+	}
+	if !reflect.DeepEqual(g.Attrs, want) {
+		t.Errorf("attributes %+v, want %+v", g.Attrs, want)
+	}
+
+	if _, err := Decode(bytes.NewReader(b.Bytes()[:5])); err == nil {
+		t.Error("truncated message decoded without error")
+	}
+}
+
+// fakeCUPS is a stand-in CUPS server with in-memory queues.
+type fakeCUPS struct {
+	t *testing.T
+
+	mu        sync.Mutex
+	jobs      map[string][]Job
+	documents map[int][]byte
+	nextID    int
+	// adminOnly refuses Purge-Jobs with HTTP 401, as CUPS does for users
+	// who are not administrators
+	adminOnly bool
+}
+
+var fakePrinters = map[string]Printer{
+	"Bill Printer": {Name: "Bill Printer", State: PrinterIdle, StateReasons: []string{"none"}, Accepting: true, MakeAndModel: "Generic ESC/POS"},
+	"Kitchen":      {Name: "Kitchen", State: PrinterStopped, StateReasons: []string{"media-empty-error", "paused"}, Location: "Hot line"},
+}
+
+func newFakeCUPS(t *testing.T) (*fakeCUPS, *Client) {
+	f := &fakeCUPS{t: t, jobs: map[string][]Job{}, documents: map[int][]byte{}, nextID: 41}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, NewClient(srv.URL)
+}
+
+func (f *fakeCUPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/ipp" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	br := bufio.NewReader(r.Body)
+	req, err := Decode(br)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	op := req.Group(TagOperation)
+	if first := op.Attrs; len(first) < 2 || first[0].Name != "attributes-charset" || first[1].Name != "attributes-natural-language" {
+		f.t.Errorf("operation %#04x: charset and language do not come first", req.Code)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &Message{Code: 0, RequestID: req.RequestID}
+	resp.Group(TagOperation).Add(TagCharset, "attributes-charset", "utf-8")
+
+	var name string
+	if req.Code != OpCUPSGetPrinters {
+		if uri, want := op.String("printer-uri"), "ipp://"+r.Host+r.URL.EscapedPath(); uri != want {
+			f.t.Errorf("operation %#04x: printer-uri %q, want %q", req.Code, uri, want)
+		}
+		if op.String("requesting-user-name") == "" {
+			f.t.Errorf("operation %#04x: no requesting-user-name", req.Code)
+		}
+		name = r.URL.Path[len("/printers/"):]
+		if _, ok := fakePrinters[name]; !ok {
+			resp.Code = 0x0406
+			resp.Group(TagOperation).Add(TagText, "status-message", "The printer or class does not exist.")
+			f.write(w, resp)
+			return
+		}
+	}
+
+	switch req.Code {
+	case OpCUPSGetPrinters:
+		for _, n := range []string{"Bill Printer", "Kitchen"} {
+			addPrinter(resp, fakePrinters[n])
+		}
+	case OpGetPrinterAttributes:
+		addPrinter(resp, fakePrinters[name])
+	case OpPrintJob:
+		if format := op.String("document-format"); format != "application/vnd.cups-raw" {
+			f.t.Errorf("document-format %q", format)
+		}
+		doc, _ := io.ReadAll(br)
+		f.nextID++
+		f.documents[f.nextID] = doc
+		f.jobs[name] = append(f.jobs[name], Job{ID: f.nextID, Name: op.String("job-name"), State: JobPending, Printer: name, User: op.String("requesting-user-name")})
+		g := resp.Group(TagJob)
+		g.Add(TagInteger, "job-id", f.nextID)
+		g.Add(TagEnum, "job-state", JobPending)
+	case OpGetJobs:
+		for _, j := range f.jobs[name] {
+			g := Group{Tag: TagJob}
+			g.Add(TagInteger, "job-id", j.ID)
+			g.Add(TagName, "job-name", j.Name)
+			g.Add(TagEnum, "job-state", j.State)
+			g.Add(TagName, "job-originating-user-name", j.User)
+			resp.Groups = append(resp.Groups, g)
+		}
+	case OpCancelJob:
+		id := op.Int("job-id")
+		list := f.jobs[name]
+		resp.Code = 0x0406
+		for i, j := range list {
+			if j.ID == id {
+				f.jobs[name] = append(list[:i], list[i+1:]...)
+				resp.Code = 0
+			}
+		}
+	case OpPurgeJobs:
+		if f.adminOnly {
+			w.Header().Set("WWW-Authenticate", `Basic realm="CUPS"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		delete(f.jobs, name)
+	default:
+		resp.Code = 0x0501 // operation-not-supported
+	}
+	f.write(w, resp)
+}
+
+func (f *fakeCUPS) write(w http.ResponseWriter, m *Message) {
+	w.Header().Set("Content-Type", "application/ipp")
+	if err := m.Encode(w); err != nil {
+		f.t.Error(err)
+	}
+}
+
+func addPrinter(m *Message, p Printer) {
+	g := Group{Tag: TagPrinter}
+	g.Add(TagName, "printer-name", p.Name)
+	g.Add(TagEnum, "printer-state", p.State)
+	reasons := make([]interface{}, len(p.StateReasons))
+	for i, r := range p.StateReasons {
+		reasons[i] = r
+	}
+	g.Add(TagKeyword, "printer-state-reasons", reasons...)
+	g.Add(TagBoolean, "printer-is-accepting-jobs", p.Accepting)
+	g.Add(TagText, "printer-make-and-model", p.MakeAndModel)
+	g.Add(TagText, "printer-info", p.Info)
+	g.Add(TagText, "printer-location", p.Location)
+	m.Groups = append(m.Groups, g)
+}
+
+func TestClientPrinters(t *testing.T) {
+	_, c := newFakeCUPS(t)
+	ctx := context.Background()
+
+	list, err := c.Printers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Printer{fakePrinters["Bill Printer"], fakePrinters["Kitchen"]}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("Printers() = %+v, want %+v", list, want)
+	}
+
+	p, err := c.Printer(ctx, "Kitchen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, fakePrinters["Kitchen"]) {
+		t.Errorf("Printer(Kitchen) = %+v", p)
+	}
+
+	_, err = c.Printer(ctx, "Missing")
+	var serr *StatusError
+	if !errors.As(err, &serr) || !serr.NotFound() || serr.Op != OpGetPrinterAttributes {
+		t.Errorf("Printer(Missing) error = %v, want not found", err)
+	}
+}
+
+func TestClientJobs(t *testing.T) {
+	f, c := newFakeCUPS(t)
+	ctx := context.Background()
+
+	var ids []int
+	for _, doc := range []string{"\x1b@first\x1dV\x00", "\x1b@second\x1dV\x00"} {
+		id, err := c.PrintRaw(ctx, "Bill Printer", "INV-1", []byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(f.documents[id]); got != doc {
+			t.Errorf("job %d printed %q, want %q", id, got, doc)
+		}
+		ids = append(ids, id)
+	}
+	if !reflect.DeepEqual(ids, []int{42, 43}) {
+		t.Errorf("job IDs %v, want [42 43]", ids)
+	}
+
+	jobs, err := c.Jobs(ctx, "Bill Printer", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != 42 || jobs[0].Name != "INV-1" || jobs[0].State != JobPending || jobs[0].Printer != "Bill Printer" || jobs[0].User != c.user {
+		t.Errorf("Jobs() = %+v", jobs)
+	}
+
+	if err := c.CancelJob(ctx, "Bill Printer", 42); err != nil {
+		t.Fatal(err)
+	}
+	if jobs, _ := c.Jobs(ctx, "Bill Printer", false); len(jobs) != 1 || jobs[0].ID != 43 {
+		t.Errorf("after cancelling job 42, jobs are %+v", jobs)
+	}
+	var serr *StatusError
+	if err := c.CancelJob(ctx, "Bill Printer", 42); !errors.As(err, &serr) || !serr.NotFound() {
+		t.Errorf("cancelling job 42 again: %v, want not found", err)
+	}
+
+	if err := c.PurgeJobs(ctx, "Bill Printer"); err != nil {
+		t.Fatal(err)
+	}
+	if jobs, _ := c.Jobs(ctx, "Bill Printer", false); len(jobs) != 0 {
+		t.Errorf("after purging, jobs are %+v", jobs)
+	}
+}
+
+func TestClientPurgeNotAuthorized(t *testing.T) {
+	f, c := newFakeCUPS(t)
+	f.adminOnly = true
+	ctx := context.Background()
+	if _, err := c.PrintRaw(ctx, "Kitchen", "KOT-1", []byte("kot")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := c.PurgeJobs(ctx, "Kitchen")
+	var serr *StatusError
+	if !errors.As(err, &serr) || !serr.NotAuthorized() || serr.Op != OpPurgeJobs {
+		t.Fatalf("PurgeJobs() error = %v, want not authorized", err)
+	}
+	if jobs, _ := c.Jobs(ctx, "Kitchen", false); len(jobs) != 1 {
+		t.Errorf("refused purge changed the queue: %+v", jobs)
+	}
+}
+
+func TestNotAuthorized(t *testing.T) {
+	for status, want := range map[uint16]bool{
+		0x0400: false, // bad-request
+		0x0401: true,  // forbidden
+		0x0402: true,  // not-authenticated
+		0x0403: true,  // not-authorized
+		0x0406: false, // not-found
+	} {
+		if got := (&StatusError{Status: status}).NotAuthorized(); got != want {
+			t.Errorf("status %#04x: NotAuthorized() = %v, want %v", status, got, want)
+		}
+	}
+}
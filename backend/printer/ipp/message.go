@@ -0,0 +1,286 @@
+// Package ipp is a small IPP/1.1 client for the local CUPS server: listing
+// printers and their state, submitting raw documents and managing jobs.
+package ipp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Operations used with CUPS.
+const (
+	OpPrintJob             uint16 = 0x0002
+	OpCancelJob            uint16 = 0x0008
+	OpGetJobs              uint16 = 0x000A
+	OpGetPrinterAttributes uint16 = 0x000B
+	OpPurgeJobs            uint16 = 0x0012
+	OpCUPSGetPrinters      uint16 = 0x4002
+)
+
+// Attribute group tags.
+const (
+	TagOperation   byte = 0x01
+	TagJob         byte = 0x02
+	TagEnd         byte = 0x03
+	TagPrinter     byte = 0x04
+	TagUnsupported byte = 0x05
+)
+
+// Value tags.
+const (
+	TagInteger         byte = 0x21
+	TagBoolean         byte = 0x22
+	TagEnum            byte = 0x23
+	TagBeginCollection byte = 0x34
+	TagEndCollection   byte = 0x37
+	TagText            byte = 0x41
+	TagName            byte = 0x42
+	TagKeyword         byte = 0x44
+	TagURI             byte = 0x45
+	TagCharset         byte = 0x47
+	TagLanguage        byte = 0x48
+	TagMimeType        byte = 0x49
+)
+
+// Attribute is a named attribute with one or more values. Values are int
+// for integer and enum tags, bool for booleans and string for the rest.
+type Attribute struct {
+	Tag    byte
+	Name   string
+	Values []interface{}
+}
+
+// Group is a group of attributes, e.g. the attributes of one printer.
+type Group struct {
+	Tag   byte
+	Attrs []Attribute
+}
+
+// Add appends an attribute to the group.
+func (g *Group) Add(tag byte, name string, values ...interface{}) {
+	g.Attrs = append(g.Attrs, Attribute{Tag: tag, Name: name, Values: values})
+}
+
+// Values returns the values of an attribute, nil when it is missing.
+func (g Group) Values(name string) []interface{} {
+	for _, a := range g.Attrs {
+		if a.Name == name {
+			return a.Values
+		}
+	}
+	return nil
+}
+
+// String returns the first value of a string attribute.
+func (g Group) String(name string) string {
+	s, _ := first(g.Values(name)).(string)
+	return s
+}
+
+// Strings returns the values of a multi-valued string attribute.
+func (g Group) Strings(name string) []string {
+	var list []string
+	for _, v := range g.Values(name) {
+		if s, ok := v.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// Int returns the first value of an integer or enum attribute.
+func (g Group) Int(name string) int {
+	n, _ := first(g.Values(name)).(int)
+	return n
+}
+
+// Bool returns the first value of a boolean attribute.
+func (g Group) Bool(name string) bool {
+	b, _ := first(g.Values(name)).(bool)
+	return b
+}
+
+func first(values []interface{}) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// Message is an IPP request or response.
+type Message struct {
+	Code      uint16 // Operation of a request, status of a response
+	RequestID uint32
+	Groups    []Group
+}
+
+// Group returns the first group with the tag, adding it to a request when
+// there is none.
+func (m *Message) Group(tag byte) *Group {
+	for i := range m.Groups {
+		if m.Groups[i].Tag == tag {
+			return &m.Groups[i]
+		}
+	}
+	m.Groups = append(m.Groups, Group{Tag: tag})
+	return &m.Groups[len(m.Groups)-1]
+}
+
+// GroupsOf returns every group with the tag, such as one per printer.
+func (m *Message) GroupsOf(tag byte) []Group {
+	var list []Group
+	for _, g := range m.Groups {
+		if g.Tag == tag {
+			list = append(list, g)
+		}
+	}
+	return list
+}
+
+// Encode writes the message as IPP/1.1, up to the end of the attributes.
+// A document, if any, follows it.
+func (m *Message) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.Write([]byte{1, 1})
+	binary.Write(bw, binary.BigEndian, m.Code)
+	binary.Write(bw, binary.BigEndian, m.RequestID)
+	for _, g := range m.Groups {
+		bw.WriteByte(g.Tag)
+		for _, a := range g.Attrs {
+			for i, v := range a.Values {
+				name := a.Name
+				if i > 0 {
+					name = ""
+				}
+				value, err := encodeValue(a.Tag, v)
+				if err != nil {
+					return fmt.Errorf("attribute %s: %w", a.Name, err)
+				}
+				bw.WriteByte(a.Tag)
+				binary.Write(bw, binary.BigEndian, uint16(len(name)))
+				bw.WriteString(name)
+				binary.Write(bw, binary.BigEndian, uint16(len(value)))
+				bw.Write(value)
+			}
+		}
+	}
+	bw.WriteByte(TagEnd)
+	return bw.Flush()
+}
+
+func encodeValue(tag byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case int:
+		return binary.BigEndian.AppendUint32(nil, uint32(int32(v))), nil
+	case bool:
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("unsupported value %T for tag %#x", v, tag)
+}
+
+var errTruncated = errors.New("ipp: truncated message")
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// Decode reads a message up to the end of its attributes. Collections are
+// skipped. A document after the attributes can be read from r when it is
+// an io.ByteReader, such as a bufio.Reader.
+func Decode(r io.Reader) (*Message, error) {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	var head [8]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		return nil, errTruncated
+	}
+	m := &Message{
+		Code:      binary.BigEndian.Uint16(head[2:4]),
+		RequestID: binary.BigEndian.Uint32(head[4:8]),
+	}
+
+	var group *Group
+	depth := 0 // Nesting of the collection being skipped
+	for {
+		tag, err := br.ReadByte()
+		if err != nil {
+			return nil, errTruncated
+		}
+		if tag == TagEnd {
+			return m, nil
+		}
+		if tag < 0x10 {
+			m.Groups = append(m.Groups, Group{Tag: tag})
+			group = &m.Groups[len(m.Groups)-1]
+			continue
+		}
+
+		name, err := readString(br)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readString(br)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case tag == TagBeginCollection:
+			depth++
+			continue
+		case tag == TagEndCollection:
+			depth--
+			continue
+		case depth > 0:
+			continue
+		case group == nil:
+			return nil, errors.New("ipp: attribute outside a group")
+		}
+
+		v := decodeValue(tag, []byte(value))
+		if name == "" && len(group.Attrs) > 0 {
+			last := &group.Attrs[len(group.Attrs)-1]
+			last.Values = append(last.Values, v)
+			continue
+		}
+		group.Attrs = append(group.Attrs, Attribute{Tag: tag, Name: name, Values: []interface{}{v}})
+	}
+}
+
+func readString(br byteReader) (string, error) {
+	var n uint16
+	if err := binary.Read(br, binary.BigEndian, &n); err != nil {
+		return "", errTruncated
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return "", errTruncated
+	}
+	return string(buf), nil
+}
+
+func decodeValue(tag byte, b []byte) interface{} {
+	switch tag {
+	case TagInteger, TagEnum:
+		if len(b) == 4 {
+			return int(int32(binary.BigEndian.Uint32(b)))
+		}
+	case TagBoolean:
+		return len(b) == 1 && b[0] != 0
+	}
+	if tag < 0x20 {
+		// Out-of-band values such as no-value and unknown
+		return nil
+	}
+	return string(b)
+}
@@ -0,0 +1,102 @@
+package printer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Layout describes the printable area of a printer. Zero fields are derived:
+// the dot width defaults to a 58mm head and the columns follow from the width
+// left between the margins.
+type Layout struct {
+	DotWidth    int `json:"dotWidth,omitempty"`    // Printable width of the head in dots
+	ColumnsA    int `json:"columnsA,omitempty"`    // Characters per line in Font A
+	ColumnsB    int `json:"columnsB,omitempty"`    // Characters per line in Font B
+	DPI         int `json:"dpi,omitempty"`         // Horizontal resolution
+	MarginLeft  int `json:"marginLeft,omitempty"`  // Dots left blank at the left edge
+	MarginRight int `json:"marginRight,omitempty"` // Dots left blank at the right edge
+}
+
+const (
+	defaultDPI   = 203
+	fontAWidth   = 12 // dots per character cell
+	fontBWidth   = 9
+	fontAHeight  = 24
+	fontBHeight  = 17
+	paperBorder  = 8 // mm of a roll a thermal head cannot reach
+	dotsPerBlock = 8
+)
+
+// Common paper widths. Thermal heads print 203 dpi over all but ~4mm each side;
+// 76mm rolls are used by impact printers (e.g. TM-U220) with their own fonts.
+var paperLayouts = map[string]Layout{
+	"58mm":  {DotWidth: 384, DPI: defaultDPI},
+	"80mm":  {DotWidth: 576, DPI: defaultDPI},
+	"76mm":  {DotWidth: 200, ColumnsA: 40, ColumnsB: 33, DPI: 80},
+	"112mm": {DotWidth: 832, DPI: defaultDPI},
+}
+
+// PaperLayout returns the layout for a paper width such as "80mm". Widths
+// without a preset are assumed to be thermal rolls with a 4mm border.
+func PaperLayout(paper string) (Layout, error) {
+	key := strings.ToLower(strings.ReplaceAll(paper, " ", ""))
+	if l, ok := paperLayouts[key]; ok {
+		return l, nil
+	}
+	mm, err := strconv.Atoi(strings.TrimSuffix(key, "mm"))
+	if err != nil || mm <= paperBorder {
+		return Layout{}, fmt.Errorf("unknown paper size %q", paper)
+	}
+	dots := (mm - paperBorder) * defaultDPI * 10 / 254
+	return Layout{DotWidth: dots - dots%dotsPerBlock, DPI: defaultDPI}, nil
+}
+
+// Merge returns l with the fields set in over replacing its own. Columns that
+// were derived for the old width are dropped when over changes the width.
+func (l Layout) Merge(over Layout) Layout {
+	if over.DotWidth != 0 {
+		l.DotWidth = over.DotWidth
+		l.ColumnsA, l.ColumnsB = 0, 0
+	}
+	if over.ColumnsA != 0 {
+		l.ColumnsA = over.ColumnsA
+	}
+	if over.ColumnsB != 0 {
+		l.ColumnsB = over.ColumnsB
+	}
+	if over.DPI != 0 {
+		l.DPI = over.DPI
+	}
+	if over.MarginLeft != 0 {
+		l.MarginLeft = over.MarginLeft
+	}
+	if over.MarginRight != 0 {
+		l.MarginRight = over.MarginRight
+	}
+	return l
+}
+
+// Resolve fills in every derived field.
+func (l Layout) Resolve() Layout {
+	if l.DotWidth <= 0 {
+		l.DotWidth = defaultDotWidth
+	}
+	if l.DPI <= 0 {
+		l.DPI = defaultDPI
+	}
+	l.MarginLeft = clamp(l.MarginLeft, 0, l.DotWidth/2)
+	l.MarginRight = clamp(l.MarginRight, 0, l.DotWidth/2)
+	if l.ColumnsA <= 0 {
+		l.ColumnsA = l.PrintableWidth() / fontAWidth
+	}
+	if l.ColumnsB <= 0 {
+		l.ColumnsB = l.PrintableWidth() / fontBWidth
+	}
+	return l
+}
+
+// PrintableWidth is the number of dots between the margins.
+func (l Layout) PrintableWidth() int {
+	return l.DotWidth - l.MarginLeft - l.MarginRight
+}
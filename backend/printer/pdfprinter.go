@@ -0,0 +1,256 @@
+package printer
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/draw"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Spacing of the PDF layout in printer dots.
+const (
+	pdfLineGap   = 6  // between text lines, as ESC 2 leaves on most printers
+	pdfCutHeight = 24 // room for the dashed cut line
+	pdfMargin    = 16 // above and below the receipt
+)
+
+// PDFPrinter renders a receipt as a single-page PDF the size of the paper
+// roll. Text uses the standard Courier fonts scaled to the printer's character
+// cells; lines that Windows-1252 cannot encode (Arabic, CJK, ...) are drawn
+// as images like the ESC/POS raster fallback.
+type PDFPrinter struct {
+	*document
+}
+
+func NewPDFPrinter(s Settings) *PDFPrinter {
+	return &PDFPrinter{document: newDocument(documentLayout(s))}
+}
+
+func (p *PDFPrinter) ContentType() string {
+	return "application/pdf"
+}
+
+// pdfItem is a block placed at a height, in dots from the top of the page.
+type pdfItem struct {
+	block docBlock
+	y     int
+	h     int
+}
+
+func (p *PDFPrinter) GetBytes() []byte {
+	// Lay the blocks out first; the page height is needed to flip the y axis
+	var items []pdfItem
+	y := pdfMargin
+	for _, b := range p.finish() {
+		if len(b.runs) > 0 && !pdfEncodable(b.runs) {
+			img, rtl := rasterizeText(textRuns(b.runs))
+			if rtl && b.align == "left" {
+				b.align = "right"
+			}
+			b = docBlock{align: b.align, image: img}
+		}
+		h := pdfBlockHeight(b)
+		items = append(items, pdfItem{block: b, y: y, h: h})
+		y += h
+	}
+	pageHeight := y + pdfMargin
+
+	var images []image.Image
+	var content bytes.Buffer
+	// Draw in dots; the page itself is measured in points
+	scale := 72 / float64(p.layout.DPI)
+	fmt.Fprintf(&content, "%.4f 0 0 %.4f 0 0 cm\n", scale, scale)
+	for _, it := range items {
+		top := pageHeight - it.y // PDF y grows upwards
+		switch b := it.block; {
+		case b.cut:
+			mid := top - it.h/2
+			fmt.Fprintf(&content, "q [6 4] 0 d 1 w 0.6 G 0 %d m %d %d l S Q\n", mid, p.layout.DotWidth, mid)
+		case b.image != nil:
+			w, h := b.image.Bounds().Dx(), b.image.Bounds().Dy()
+			images = append(images, b.image)
+			fmt.Fprintf(&content, "q %d 0 0 %d %d %d cm /Im%d Do Q\n", w, h, p.alignX(w, b.align), top-h, len(images))
+		default:
+			p.writeLine(&content, b, top, it.h)
+		}
+	}
+
+	width := float64(p.layout.DotWidth) * scale
+	height := float64(pageHeight) * scale
+	return buildPDF(width, height, content.Bytes(), images)
+}
+
+func pdfBlockHeight(b docBlock) int {
+	switch {
+	case b.cut:
+		return pdfCutHeight
+	case b.image != nil:
+		return b.image.Bounds().Dy()
+	}
+	h := fontAHeight
+	for _, r := range b.runs {
+		h = max(h, cellHeight(r.style.font)*(int(r.style.height)+1))
+	}
+	return h + pdfLineGap
+}
+
+// alignX returns the left edge of something w dots wide on the line.
+func (p *PDFPrinter) alignX(w int, align string) int {
+	room := p.layout.PrintableWidth() - w
+	switch align {
+	case "center":
+		return p.layout.MarginLeft + room/2
+	case "right":
+		return p.layout.MarginLeft + room
+	}
+	return p.layout.MarginLeft
+}
+
+// writeLine draws the runs of a text line with their bottoms on the line's
+// baseline, so double-height text grows upwards as on paper.
+func (p *PDFPrinter) writeLine(w *bytes.Buffer, b docBlock, top, h int) {
+	width := 0
+	for _, r := range b.runs {
+		width += utf8.RuneCountInString(r.text) * cellWidth(r.style.font) * (int(r.style.width) + 1)
+	}
+	x := p.alignX(width, b.align)
+	bottom := top - h + pdfLineGap
+
+	for _, r := range b.runs {
+		sx, sy := int(r.style.width)+1, int(r.style.height)+1
+		cw, ch := cellWidth(r.style.font)*sx, cellHeight(r.style.font)*sy
+		runWidth := utf8.RuneCountInString(r.text) * cw
+		size := float64(cellWidth(r.style.font)) / 0.6 * float64(sy) // Courier advances 0.6 em
+		// Centre Courier's ascent and descent in the cell
+		baseline := float64(bottom) + (float64(ch)-0.786*size)/2 + 0.157*size
+
+		if r.style.reverse {
+			fmt.Fprintf(w, "0 g %d %d %d %d re f\n", x, bottom, runWidth, ch)
+		}
+		font := "F1"
+		if r.style.bold {
+			font = "F2"
+		}
+		fill := "0 g"
+		if r.style.reverse {
+			fill = "1 g"
+		}
+		enc, _ := charmap.Windows1252.NewEncoder().String(r.text)
+		fmt.Fprintf(w, "BT %s /%s %.2f Tf %d Tz %d %.2f Td (%s) Tj ET\n",
+			fill, font, size, 100*sx/sy, x, baseline, pdfEscape(enc))
+		if r.style.underline {
+			fmt.Fprintf(w, "0 g %d %d %d 2 re f\n", x, bottom, runWidth)
+		}
+		x += runWidth
+	}
+}
+
+func cellWidth(font string) int {
+	if font == "B" {
+		return fontBWidth
+	}
+	return fontAWidth
+}
+
+func cellHeight(font string) int {
+	if font == "B" {
+		return fontBHeight
+	}
+	return fontAHeight
+}
+
+// pdfEncodable reports whether the Courier fonts can show every character of a line.
+func pdfEncodable(runs []docRun) bool {
+	for _, r := range runs {
+		if _, err := charmap.Windows1252.NewEncoder().String(r.text); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// textRuns converts a line for rasterizeText.
+func textRuns(runs []docRun) []textRun {
+	out := make([]textRun, len(runs))
+	for i, r := range runs {
+		out[i] = textRun{text: r.text, bold: r.style.bold, font: r.style.font,
+			width: r.style.width, height: r.style.height, reverse: r.style.reverse}
+		if r.style.underline {
+			out[i].underline = 1
+		}
+	}
+	return out
+}
+
+func pdfEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`).Replace(s)
+}
+
+// buildPDF writes a one-page PDF 1.4 file around a content stream. Images
+// are referenced from the stream as /Im1, /Im2, ... in order.
+func buildPDF(width, height float64, content []byte, images []image.Image) []byte {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string, stream []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			out.WriteString("stream\n")
+			out.Write(stream)
+			out.WriteString("\nendstream\n")
+		}
+		out.WriteString("endobj\n")
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-6 are fixed; images follow from 7
+	var xobjects strings.Builder
+	for i := range images {
+		fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", i+1, i+7)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	obj("<< /Type /Pages /Kids [3 0 R] /Count 1 >>", nil)
+	obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Contents 4 0 R "+
+		"/Resources << /Font << /F1 5 0 R /F2 6 0 R >> /XObject << %s>> >> >>", width, height, xobjects.String()), nil)
+	data := deflate(content)
+	obj(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>", len(data)), data)
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>", nil)
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>", nil)
+	for _, img := range images {
+		gray := toGray(img)
+		data := deflate(gray.Pix)
+		obj(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray "+
+			"/BitsPerComponent 8 /Length %d /Filter /FlateDecode >>", gray.Rect.Dx(), gray.Rect.Dy(), len(data)), data)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// toGray flattens an image onto white, with rows packed tightly.
+func toGray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(gray, gray.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(gray, gray.Bounds(), img, b.Min, draw.Over)
+	return gray
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
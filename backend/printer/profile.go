@@ -0,0 +1,184 @@
+package printer
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Profile describes the capabilities of a printer model, so the adapter can
+// pick a command path the printer actually understands.
+type Profile struct {
+	Name string `json:"name"`
+	// Match lists case-insensitive fragments of printer or driver names
+	// (e.g. "tm-t82") identifying printers of this model.
+	Match []string `json:"match,omitempty"`
+	// Layout is the model's printable area; most models leave it to the paper size.
+	Layout
+	// NativeQR is set when the printer renders QR codes itself (GS ( k).
+	// Otherwise QR codes are sent as raster images.
+	NativeQR bool `json:"nativeQR"`
+	// NativePDF417 is set when the printer renders PDF417 symbols itself (GS ( k).
+	NativePDF417 bool `json:"nativePDF417"`
+	// Barcodes lists the 1D symbologies the printer prints with GS k.
+	// Anything else is drawn as a raster image.
+	Barcodes []string `json:"barcodes"`
+	// Graphics selects the image command: "raster" (GS v 0), "buffer"
+	// (GS ( L graphics buffer) or "column" (ESC * 24-dot strips) for printers
+	// that ignore GS v 0.
+	Graphics string `json:"graphics,omitempty"`
+	// BandHeight is the number of image rows sent per command (24-256).
+	BandHeight int `json:"bandHeight,omitempty"`
+	// Cut is the cutter type: "partial" (default), "full" or "none".
+	Cut string `json:"cut,omitempty"`
+	// Buzzer is the beep command: "epson" (ESC ( A), "clone" (ESC B) or empty
+	// when the printer has no buzzer.
+	Buzzer string `json:"buzzer,omitempty"`
+	// Fonts lists the built-in fonts ("A", "B"). Empty means both.
+	Fonts []string `json:"fonts,omitempty"`
+	// CodePages lists the character tables the printer has. Empty means unknown.
+	CodePages []string `json:"codePages,omitempty"`
+}
+
+const DefaultProfile = "generic"
+
+// Graphics command paths.
+const (
+	GraphicsRaster = "raster"
+	GraphicsColumn = "column"
+	GraphicsBuffer = "buffer"
+)
+
+// Buzzer commands.
+const (
+	BuzzerEpson = "epson"
+	BuzzerClone = "clone"
+)
+
+// Cutter types.
+const (
+	CutPartial = "partial"
+	CutFull    = "full"
+	CutNone    = "none"
+)
+
+//go:embed profiles.json
+var builtinProfilesJSON []byte
+
+var (
+	profilesMux sync.RWMutex
+	// Searched in order, so profiles from config.json come first
+	profiles []Profile
+)
+
+func init() {
+	if err := json.Unmarshal(builtinProfilesJSON, &profiles); err != nil {
+		panic(fmt.Sprintf("invalid built-in printer profiles: %v", err))
+	}
+}
+
+// RegisterProfiles adds profiles from config.json. A profile with the name of
+// a built-in one replaces it.
+func RegisterProfiles(custom []Profile) {
+	profilesMux.Lock()
+	defer profilesMux.Unlock()
+
+	var merged []Profile
+	for _, p := range custom {
+		if p.Name == "" {
+			fmt.Println("Warning: ignoring printer profile without a name")
+			continue
+		}
+		p.Name = strings.ToLower(p.Name)
+		merged = append(merged, p)
+	}
+	for _, p := range profiles {
+		if !containsFold(profileNames(merged), p.Name) {
+			merged = append(merged, p)
+		}
+	}
+	profiles = merged
+}
+
+// Profiles returns every known profile in match order.
+func Profiles() []Profile {
+	profilesMux.RLock()
+	defer profilesMux.RUnlock()
+	return append([]Profile(nil), profiles...)
+}
+
+// LookupProfile resolves a profile by name; an empty name selects the generic profile.
+func LookupProfile(name string) (Profile, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		key = DefaultProfile
+	}
+	for _, p := range Profiles() {
+		if p.Name == key {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("unknown printer profile %q", name)
+}
+
+// MatchProfile returns the first profile with a match pattern found in any of
+// the given printer or driver names.
+func MatchProfile(names ...string) (Profile, bool) {
+	for _, p := range Profiles() {
+		for _, pattern := range p.Match {
+			pattern = strings.ToLower(pattern)
+			for _, n := range names {
+				if pattern != "" && strings.Contains(strings.ToLower(n), pattern) {
+					return p, true
+				}
+			}
+		}
+	}
+	return Profile{}, false
+}
+
+// ProfileFor names the profile used for a printer: the one configured for it,
+// otherwise the one matching its name or driver, otherwise the generic profile.
+func ProfileFor(info PrinterInfo, configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if p, ok := MatchProfile(info.Name, info.Driver); ok {
+		return p.Name
+	}
+	return DefaultProfile
+}
+
+// SupportsBarcode reports whether the printer can print the symbology natively.
+func (p Profile) SupportsBarcode(symbology string) bool {
+	return containsFold(p.Barcodes, symbology)
+}
+
+// HasFont reports whether the printer has the built-in font ("A" or "B").
+func (p Profile) HasFont(font string) bool {
+	return len(p.Fonts) == 0 || containsFold(p.Fonts, font)
+}
+
+// SupportsCodePage reports whether the printer is known to carry the code page.
+func (p Profile) SupportsCodePage(name string) bool {
+	return len(p.CodePages) == 0 || containsFold(p.CodePages, name)
+}
+
+func profileNames(list []Profile) []string {
+	names := make([]string, len(list))
+	for i, p := range list {
+		names[i] = p.Name
+	}
+	return names
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
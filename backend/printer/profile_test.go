@@ -0,0 +1,27 @@
+package printer
+
+import "testing"
+
+func TestMatchProfile(t *testing.T) {
+	for _, tc := range []struct {
+		names []string
+		want  string // "" for no match
+	}{
+		{[]string{"EPSON TM-T82"}, "epson"},
+		{[]string{"Counter", "EPSON TM-T20II Receipt"}, "epson"},
+		{[]string{"Star TSP100 Cutter (TSP143)"}, "star"},
+		{[]string{"Bill", "Star Micronics TSP650II"}, "star"},
+		{[]string{"TSP143IIIU"}, "star"},
+		{[]string{"XP-58IIH"}, "xprinter-58"},
+		{[]string{"POS-80C"}, "pos-80"},
+		// Names that merely contain "star" are not Star printers
+		{[]string{"Kitchen-Starters"}, ""},
+		{[]string{"starbar"}, ""},
+		{[]string{"Mustard Station", "Generic / Text Only"}, ""},
+	} {
+		p, ok := MatchProfile(tc.names...)
+		if ok != (tc.want != "") || p.Name != tc.want {
+			t.Errorf("MatchProfile(%q) = %q, %v; want %q", tc.names, p.Name, ok, tc.want)
+		}
+	}
+}
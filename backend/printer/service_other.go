@@ -3,100 +3,112 @@
 package printer
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
 	"strings"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"ts-escpos/backend/printer/ipp"
 )
 
+// cups talks IPP to the local CUPS server, or the one in $CUPS_SERVER.
+var cups = ipp.NewClient("")
+
 func GetPrinters() ([]PrinterInfo, error) {
-	cmd := exec.Command("lpstat", "-e")
-	output, err := cmd.Output()
+	queues, err := cups.Printers(context.Background())
 	if err != nil {
-		// If command fails, we might not have cups installed or just no printers
+		// CUPS might not be installed or running
+		fmt.Printf("Warning: could not list CUPS printers: %v\n", err)
 		return []PrinterInfo{}, nil
 	}
 
-	printerNames := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var printers []PrinterInfo
-
-	for _, name := range printerNames {
-		if name == "" {
-			continue
-		}
-
-		// Get status for each printer
-		// lpstat -p <name>
-		statusCmd := exec.Command("lpstat", "-p", name)
-		statusOut, _ := statusCmd.Output()
-		statusStr := string(statusOut)
-
-		status := "Unknown"
-		if strings.Contains(statusStr, "is idle") {
-			status = "Ready"
-		} else if strings.Contains(statusStr, "printing") {
-			status = "Printing"
-		} else if strings.Contains(statusStr, "disabled") {
-			status = "Paused"
-		}
-
+	printers := make([]PrinterInfo, 0, len(queues))
+	for _, q := range queues {
 		printers = append(printers, PrinterInfo{
-			Name:      name,
-			UniqueID:  name, // CUPS printer name is unique enough for local reference
-			WindowsID: name, // Using name as ID
-			Status:    status,
+			Name:      q.Name,
+			UniqueID:  q.Name, // CUPS printer name is unique enough for local reference
+			WindowsID: q.Name, // Using name as ID
+			Status:    cupsStatus(q),
+			Driver:    q.MakeAndModel,
 		})
 	}
-
 	return printers, nil
 }
 
-func PrintRaw(ctx context.Context, printerName string, data []byte) error {
-	msg := fmt.Sprintf("[Printer] Printing %d bytes to '%s' via lp", len(data), printerName)
+// cupsStatus maps a queue's state and state reasons, which are keywords
+// rather than localized text, to the status shown for a printer.
+func cupsStatus(q ipp.Printer) string {
+	for _, reason := range q.StateReasons {
+		if strings.HasPrefix(reason, "offline") {
+			return "Offline"
+		}
+	}
+	switch q.State {
+	case ipp.PrinterIdle:
+		if !q.Accepting {
+			return "Paused"
+		}
+		return "Ready"
+	case ipp.PrinterProcessing:
+		return "Printing"
+	case ipp.PrinterStopped:
+		return "Paused"
+	}
+	return "Unknown"
+}
+
+// PrintRaw submits data to a CUPS queue unchanged and returns the CUPS job ID.
+func PrintRaw(ctx context.Context, printerName string, data []byte) (int, error) {
+	msg := fmt.Sprintf("[Printer] Printing %d bytes to '%s' via CUPS", len(data), printerName)
 	logToFrontend(ctx, msg)
 
-	// lp -d <printer> -o raw
-	cmd := exec.Command("lp", "-d", printerName, "-o", "raw")
-	cmd.Stdin = bytes.NewReader(data)
-	output, err := cmd.CombinedOutput()
+	jobID, err := cups.PrintRaw(ctx, printerName, "RAW Print Job", data)
 	if err != nil {
-		errMsg := fmt.Sprintf("[Printer] Error printing to '%s': %v. Output: %s", printerName, err, string(output))
+		errMsg := fmt.Sprintf("[Printer] Error printing to '%s': %v", printerName, err)
 		logToFrontend(ctx, errMsg)
-		return fmt.Errorf("failed to print: %v, output: %s", err, string(output))
+		return 0, fmt.Errorf("failed to print: %v", err)
 	}
-	successMsg := fmt.Sprintf("[Printer] Successfully sent job to '%s'. Output: %s", printerName, string(output))
+	successMsg := fmt.Sprintf("[Printer] Successfully sent job to '%s' as job %d", printerName, jobID)
 	logToFrontend(ctx, successMsg)
-	return nil
+	return jobID, nil
 }
 
 func ClearPrinterQueue(ctx context.Context, printerName string) error {
 	msg := fmt.Sprintf("[Printer] Clearing queue for '%s'", printerName)
 	logToFrontend(ctx, msg)
 
-	// cancel -a <printer>
-	cmd := exec.Command("cancel", "-a", printerName)
-	output, err := cmd.CombinedOutput()
+	err := cups.PurgeJobs(ctx, printerName)
+	var serr *ipp.StatusError
+	if errors.As(err, &serr) && serr.NotAuthorized() {
+		// Purging is for administrators; cancel our jobs one by one instead
+		err = cancelJobs(ctx, printerName)
+	}
 	if err != nil {
-		// Try lprm if cancel fails
-		// lprm -P <printer> -
-		cmd2 := exec.Command("lprm", "-P", printerName, "-")
-		output2, err2 := cmd2.CombinedOutput()
-		if err2 != nil {
-			errMsg := fmt.Sprintf("[Printer] Failed to clear queue for '%s': %v / %v. Output: %s / %s", printerName, err, err2, string(output), string(output2))
-			logToFrontend(ctx, errMsg)
-			return fmt.Errorf("failed to clear queue: %v", err)
-		}
-		output = output2
+		errMsg := fmt.Sprintf("[Printer] Failed to clear queue for '%s': %v", printerName, err)
+		logToFrontend(ctx, errMsg)
+		return fmt.Errorf("failed to clear queue: %v", err)
 	}
 
-	successMsg := fmt.Sprintf("[Printer] Queue cleared for '%s'. Output: %s", printerName, string(output))
+	successMsg := fmt.Sprintf("[Printer] Queue cleared for '%s'", printerName)
 	logToFrontend(ctx, successMsg)
 	return nil
 }
 
+func cancelJobs(ctx context.Context, printerName string) error {
+	list, err := cups.Jobs(ctx, printerName, false)
+	if err != nil {
+		return err
+	}
+	for _, job := range list {
+		if err := cups.CancelJob(ctx, printerName, job.ID); err != nil {
+			return fmt.Errorf("job %d: %w", job.ID, err)
+		}
+	}
+	return nil
+}
+
 func logToFrontend(ctx context.Context, msg string) {
 	fmt.Println(msg)
 	if ctx != nil {
@@ -126,6 +126,7 @@ func GetPrinters() ([]PrinterInfo, error) {
 			UniqueID:  name, // Windows printer names are unique enough locally
 			WindowsID: port,
 			Status:    getStatusString(p.Status),
+			Driver:    ptrToString(p.pDriverName),
 		})
 	}
 
@@ -235,12 +236,13 @@ func ptrToString(ptr uintptr) string {
 	return windows.UTF16PtrToString((*uint16)(unsafe.Pointer(ptr)))
 }
 
-func PrintRaw(ctx context.Context, printerName string, data []byte) error {
+// PrintRaw writes data to a print queue unchanged and returns the spooler job ID.
+func PrintRaw(ctx context.Context, printerName string, data []byte) (int, error) {
 	logToFrontend(ctx, fmt.Sprintf("[PrintRaw] Starting job for '%s' (%d bytes)", printerName, len(data)))
 	name, err := syscall.UTF16PtrFromString(printerName)
 	if err != nil {
 		logToFrontend(ctx, fmt.Sprintf("[Printer] UTF16 conversion failed: %v", err))
-		return err
+		return 0, err
 	}
 
 	var hPrinter syscall.Handle
@@ -251,7 +253,7 @@ func PrintRaw(ctx context.Context, printerName string, data []byte) error {
 	)
 	if r1 == 0 {
 		logToFrontend(ctx, fmt.Sprintf("[Printer] OpenPrinter failed: %v", err))
-		return fmt.Errorf("OpenPrinter failed: %v", err)
+		return 0, fmt.Errorf("OpenPrinter failed: %v", err)
 	}
 	defer procClosePrinter.Call(uintptr(hPrinter))
 	logToFrontend(ctx, "[Printer] OpenPrinter success. Handle obtained.")
@@ -272,14 +274,15 @@ func PrintRaw(ctx context.Context, printerName string, data []byte) error {
 	)
 	if r1 == 0 {
 		logToFrontend(ctx, fmt.Sprintf("[Printer] StartDocPrinter failed: %v", err))
-		return fmt.Errorf("StartDocPrinter failed: %v", err)
+		return 0, fmt.Errorf("StartDocPrinter failed: %v", err)
 	}
+	jobID := int(r1)
 	defer procEndDocPrinter.Call(uintptr(hPrinter))
 
 	r1, _, err = procStartPagePrinter.Call(uintptr(hPrinter))
 	if r1 == 0 {
 		logToFrontend(ctx, fmt.Sprintf("[Printer] StartPagePrinter failed: %v", err))
-		return fmt.Errorf("StartPagePrinter failed: %v", err)
+		return 0, fmt.Errorf("StartPagePrinter failed: %v", err)
 	}
 	defer procEndPagePrinter.Call(uintptr(hPrinter))
 
@@ -292,16 +295,16 @@ func PrintRaw(ctx context.Context, printerName string, data []byte) error {
 	)
 	if r1 == 0 {
 		logToFrontend(ctx, fmt.Sprintf("[Printer] WritePrinter failed: %v", err))
-		return fmt.Errorf("WritePrinter failed: %v", err)
+		return 0, fmt.Errorf("WritePrinter failed: %v", err)
 	}
 
 	if bytesWritten != uint32(len(data)) {
 		logToFrontend(ctx, fmt.Sprintf("[Printer] Incomplete write: %d/%d bytes", bytesWritten, len(data)))
-		return fmt.Errorf("incomplete write: %d/%d", bytesWritten, len(data))
+		return 0, fmt.Errorf("incomplete write: %d/%d", bytesWritten, len(data))
 	}
 
-	logToFrontend(ctx, fmt.Sprintf("[Printer] WritePrinter success: %d bytes written to '%s'", bytesWritten, printerName))
-	return nil
+	logToFrontend(ctx, fmt.Sprintf("[Printer] WritePrinter success: %d bytes written to '%s' as job %d", bytesWritten, printerName, jobID))
+	return jobID, nil
 }
 
 func ClearPrinterQueue(ctx context.Context, printerName string) error {
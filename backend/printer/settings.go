@@ -0,0 +1,58 @@
+package printer
+
+import "ts-escpos/backend/receipt"
+
+// Settings holds per-printer output preferences. They are stored in config.json
+// under "printers", keyed by printer name.
+type Settings struct {
+	// Profile names the printer model profile (e.g. "epson"). Defaults to "generic".
+	Profile string `json:"profile,omitempty"`
+	// CodePage selects the character table (e.g. "CP858", "CP1252"). Defaults to CP437.
+	CodePage string `json:"codePage,omitempty"`
+	// Fallback replaces characters the code page cannot encode. Defaults to "?".
+	Fallback string `json:"fallback,omitempty"`
+	// Image sets how logos and pictures are dithered on this printer.
+	// A high-density head may prefer ordered dithering or a lighter gamma.
+	Image receipt.ImageOptions `json:"image"`
+	// Graphics overrides the profile's image command ("raster", "buffer" or "column").
+	Graphics string `json:"graphics,omitempty"`
+	// BandHeight overrides the number of image rows sent per command (24-256).
+	// Lower it for printers that stall or cut images short.
+	BandHeight int `json:"bandHeight,omitempty"`
+	// Paper is the roll width (e.g. "58mm", "80mm", "112mm"). When empty the
+	// size sent with the print request is used.
+	Paper string `json:"paper,omitempty"`
+	// Address reaches the printer directly instead of through an OS queue:
+	// "tcp://10.0.0.50:9100" for a network printer's raw port, and on Linux
+	// "usb:///dev/usb/lp0" for a USB printer or "serial:///dev/ttyUSB0" for
+	// a serial one. Such printers are listed under their name in config and
+	// need no driver.
+	Address string `json:"address,omitempty"`
+	// ConnectTimeout and WriteTimeout limit, in seconds, how long connecting
+	// to and sending a job to a network printer may take (3 and 30 by default).
+	ConnectTimeout int `json:"connectTimeout,omitempty"`
+	WriteTimeout   int `json:"writeTimeout,omitempty"`
+	// Serial sets the line of a printer on a serial port.
+	Serial *SerialSettings `json:"serial,omitempty"`
+	// StatusAddress is a bidirectional channel used to read the printer's own
+	// status (paper, cover, errors): "tcp://host:9100" or a device file such
+	// as "/dev/usb/lp0".
+	StatusAddress string `json:"statusAddress,omitempty"`
+	// StatusASB keeps the channel open with Automatic Status Back instead of polling.
+	StatusASB bool `json:"statusASB,omitempty"`
+	// HoldSeconds holds jobs this long while the printer reports a problem
+	// (e.g. paper out) before failing them. 0 fails them straight away.
+	HoldSeconds int `json:"holdSeconds,omitempty"`
+	// Layout overrides the dot width, columns, DPI and margins of the profile and paper.
+	Layout
+}
+
+// SerialSettings configures a serial port. They must match the printer's
+// DIP switches or its self-test page.
+type SerialSettings struct {
+	BaudRate    int    `json:"baudRate,omitempty"`    // Default 9600
+	DataBits    int    `json:"dataBits,omitempty"`    // 7 or 8 (default)
+	Parity      string `json:"parity,omitempty"`      // "none" (default), "even" or "odd"
+	StopBits    int    `json:"stopBits,omitempty"`    // 1 (default) or 2
+	FlowControl string `json:"flowControl,omitempty"` // "none" (default), "rtscts" or "xonxoff"
+}
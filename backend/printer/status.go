@@ -0,0 +1,209 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// DeviceStatus is the live state reported by the printer itself (DLE EOT or
+// Automatic Status Back), as opposed to the spooler status string.
+type DeviceStatus struct {
+	Online     bool      `json:"online"`
+	PaperLow   bool      `json:"paperLow"`
+	PaperOut   bool      `json:"paperOut"`
+	CoverOpen  bool      `json:"coverOpen"`
+	DrawerOpen bool      `json:"drawerOpen"`
+	Errors     []string  `json:"errors,omitempty"`
+	CheckedAt  time.Time `json:"checkedAt"`
+	// Stale marks a status that could not be read; it is shown but does not hold jobs
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Ready reports whether a job sent now would print.
+func (s DeviceStatus) Ready() bool {
+	return s.Online && !s.PaperOut && !s.CoverOpen && len(s.Errors) == 0
+}
+
+// Problem describes why the printer is not ready.
+func (s DeviceStatus) Problem() string {
+	var problems []string
+	if s.PaperOut {
+		problems = append(problems, "paper out")
+	}
+	if s.CoverOpen {
+		problems = append(problems, "cover open")
+	}
+	problems = append(problems, s.Errors...)
+	if len(problems) == 0 && !s.Online {
+		problems = append(problems, "offline")
+	}
+	return strings.Join(problems, ", ")
+}
+
+// Summary is the status a printer is listed with: "Ready", "Offline",
+// "Unknown" when it did not answer, or what is wrong, e.g. "Paper out".
+func (s DeviceStatus) Summary() string {
+	switch {
+	case s.Stale:
+		return "Unknown"
+	case s.Ready():
+		return "Ready"
+	}
+	problem := s.Problem()
+	return strings.ToUpper(problem[:1]) + problem[1:]
+}
+
+const defaultStatusTimeout = 2 * time.Second
+
+// statusConn is a bidirectional channel to the printer.
+type statusConn interface {
+	io.ReadWriteCloser
+	SetDeadline(t time.Time) error
+}
+
+// openStatusConn connects to a status address: "tcp://host:port" for network
+// printers or a device file such as "/dev/usb/lp0".
+func openStatusConn(addr string, timeout time.Duration) (statusConn, error) {
+	if host, ok := strings.CutPrefix(addr, "tcp://"); ok {
+		if !strings.Contains(host, ":") {
+			host += ":9100"
+		}
+		return net.DialTimeout("tcp", host, timeout)
+	}
+	return os.OpenFile(strings.TrimPrefix(addr, "file://"), os.O_RDWR, 0)
+}
+
+// QueryStatus asks the printer at addr for its state with DLE EOT 1-4.
+func QueryStatus(addr string) (DeviceStatus, error) {
+	conn, err := openStatusConn(addr, defaultStatusTimeout)
+	if err != nil {
+		return DeviceStatus{}, err
+	}
+	defer conn.Close()
+	return queryStatus(conn, defaultStatusTimeout)
+}
+
+func queryStatus(conn statusConn, timeout time.Duration) (DeviceStatus, error) {
+	var replies [4]byte
+	for n := byte(1); n <= 4; n++ {
+		// Device files may not support deadlines; the read then blocks until the printer answers
+		_ = conn.SetDeadline(time.Now().Add(timeout))
+
+		// DLE EOT n
+		if _, err := conn.Write([]byte{0x10, 0x04, n}); err != nil {
+			return DeviceStatus{}, fmt.Errorf("failed to send status request: %w", err)
+		}
+		b, err := readStatusByte(conn)
+		if err != nil {
+			return DeviceStatus{}, fmt.Errorf("no reply to DLE EOT %d: %w", n, err)
+		}
+		replies[n-1] = b
+	}
+	return decodeStatus(replies), nil
+}
+
+// readStatusByte reads one real-time status byte, skipping anything (such as
+// an ASB packet) that is not one.
+func readStatusByte(r io.Reader) (byte, error) {
+	buf := make([]byte, 1)
+	for i := 0; i < 16; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, err
+		}
+		// Status bytes always have bit 1 and 4 set, bit 0 and 7 clear
+		if buf[0]&0x93 == 0x12 {
+			return buf[0], nil
+		}
+	}
+	return 0, errors.New("unexpected data from printer")
+}
+
+// decodeStatus interprets the replies to DLE EOT 1 (printer), 2 (offline
+// cause), 3 (error cause) and 4 (paper roll sensor).
+func decodeStatus(r [4]byte) DeviceStatus {
+	return DeviceStatus{
+		Online: r[0]&0x08 == 0,
+		// Pin 3 of the drawer connector is high while the drawer is open
+		DrawerOpen: r[0]&0x04 != 0,
+		CoverOpen:  r[1]&0x04 != 0,
+		PaperOut:   r[1]&0x20 != 0 || r[3]&0x60 != 0,
+		PaperLow:   r[3]&0x0C != 0,
+		CheckedAt:  time.Now(),
+		Errors:     errorCauses(r[2]),
+	}
+}
+
+// errorCauses decodes the error bits shared by DLE EOT 3 and the second ASB byte.
+func errorCauses(b byte) []string {
+	var errs []string
+	if b&0x04 != 0 {
+		errs = append(errs, "recoverable error")
+	}
+	if b&0x08 != 0 {
+		errs = append(errs, "autocutter error")
+	}
+	if b&0x20 != 0 {
+		errs = append(errs, "unrecoverable error")
+	}
+	if b&0x40 != 0 {
+		errs = append(errs, "head temperature error")
+	}
+	return errs
+}
+
+// decodeASB interprets a 4-byte Automatic Status Back packet.
+func decodeASB(p [4]byte) DeviceStatus {
+	return DeviceStatus{
+		Online:     p[0]&0x08 == 0,
+		DrawerOpen: p[0]&0x04 != 0,
+		CoverOpen:  p[0]&0x20 != 0,
+		PaperLow:   p[2]&0x03 != 0,
+		PaperOut:   p[2]&0x0C != 0,
+		CheckedAt:  time.Now(),
+		Errors:     errorCauses(p[1]),
+	}
+}
+
+// WatchStatus keeps a connection to the printer open with Automatic Status
+// Back (GS a) enabled and calls update with every status the printer sends,
+// until stop is closed (if not nil) or the connection fails.
+func WatchStatus(addr string, stop <-chan struct{}, update func(DeviceStatus)) error {
+	conn, err := openStatusConn(addr, defaultStatusTimeout)
+	if err != nil {
+		return err
+	}
+	if stop != nil {
+		go func() {
+			<-stop
+			conn.Close()
+		}()
+	}
+	defer conn.Close()
+
+	// GS a n: report drawer, online, error and paper sensor changes
+	if _, err := conn.Write([]byte{0x1D, 0x61, 0x0F}); err != nil {
+		return err
+	}
+	defer conn.Write([]byte{0x1D, 0x61, 0x00})
+
+	var packet [4]byte
+	for {
+		_ = conn.SetDeadline(time.Time{})
+		if _, err := io.ReadFull(conn, packet[:1]); err != nil {
+			return err
+		}
+		// The first byte of an ASB packet has bit 4 set and bits 0, 1 and 7 clear
+		if packet[0]&0x93 != 0x10 {
+			continue
+		}
+		if _, err := io.ReadFull(conn, packet[1:]); err != nil {
+			return err
+		}
+		update(decodeASB(packet))
+	}
+}
@@ -0,0 +1,127 @@
+package printer
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// Real-time status bytes always have bits 1 and 4 set.
+const statusBase = 0x12
+
+func TestDecodeStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		replies [4]byte // DLE EOT 1, 2, 3 and 4
+		want    DeviceStatus
+	}{
+		{"ready", [4]byte{statusBase, statusBase, statusBase, statusBase},
+			DeviceStatus{Online: true}},
+		{"offline", [4]byte{statusBase | 0x08, statusBase, statusBase, statusBase},
+			DeviceStatus{}},
+		{"drawer open", [4]byte{statusBase | 0x04, statusBase, statusBase, statusBase},
+			DeviceStatus{Online: true, DrawerOpen: true}},
+		{"cover open", [4]byte{statusBase | 0x08, statusBase | 0x04, statusBase, statusBase},
+			DeviceStatus{CoverOpen: true}},
+		{"paper out stopped printing", [4]byte{statusBase | 0x08, statusBase | 0x20, statusBase, statusBase},
+			DeviceStatus{PaperOut: true}},
+		{"paper near end", [4]byte{statusBase, statusBase, statusBase, statusBase | 0x0C},
+			DeviceStatus{Online: true, PaperLow: true}},
+		{"roll end", [4]byte{statusBase, statusBase, statusBase, statusBase | 0x60},
+			DeviceStatus{Online: true, PaperOut: true}},
+		{"autocutter and recoverable errors", [4]byte{statusBase | 0x08, statusBase, statusBase | 0x08 | 0x04, statusBase},
+			DeviceStatus{Errors: []string{"recoverable error", "autocutter error"}}},
+		{"unrecoverable and head temperature errors", [4]byte{statusBase | 0x08, statusBase, statusBase | 0x20 | 0x40, statusBase},
+			DeviceStatus{Errors: []string{"unrecoverable error", "head temperature error"}}},
+	} {
+		got := decodeStatus(tc.replies)
+		got.CheckedAt = tc.want.CheckedAt
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: decodeStatus(% x) = %+v, want %+v", tc.name, tc.replies, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeASB(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		packet [4]byte
+		want   DeviceStatus
+	}{
+		{"ready", [4]byte{0x10, 0x00, 0x00, 0x00}, DeviceStatus{Online: true}},
+		{"offline", [4]byte{0x18, 0x00, 0x00, 0x00}, DeviceStatus{}},
+		{"drawer open", [4]byte{0x14, 0x00, 0x00, 0x00}, DeviceStatus{Online: true, DrawerOpen: true}},
+		{"cover open", [4]byte{0x38, 0x00, 0x00, 0x00}, DeviceStatus{CoverOpen: true}},
+		{"paper near end", [4]byte{0x10, 0x00, 0x03, 0x00}, DeviceStatus{Online: true, PaperLow: true}},
+		{"paper out", [4]byte{0x18, 0x00, 0x0C, 0x00}, DeviceStatus{PaperOut: true}},
+		{"autocutter error", [4]byte{0x18, 0x08, 0x00, 0x00}, DeviceStatus{Errors: []string{"autocutter error"}}},
+		{"unrecoverable error", [4]byte{0x18, 0x20, 0x00, 0x00}, DeviceStatus{Errors: []string{"unrecoverable error"}}},
+	} {
+		got := decodeASB(tc.packet)
+		got.CheckedAt = tc.want.CheckedAt
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: decodeASB(% x) = %+v, want %+v", tc.name, tc.packet, got, tc.want)
+		}
+	}
+}
+
+func TestStatusReady(t *testing.T) {
+	for _, tc := range []struct {
+		status  DeviceStatus
+		ready   bool
+		problem string
+	}{
+		{DeviceStatus{Online: true}, true, ""},
+		{DeviceStatus{Online: true, PaperLow: true, DrawerOpen: true}, true, ""},
+		{DeviceStatus{}, false, "offline"},
+		{DeviceStatus{PaperOut: true, CoverOpen: true}, false, "paper out, cover open"},
+		{DeviceStatus{Online: true, Errors: []string{"autocutter error"}}, false, "autocutter error"},
+	} {
+		if got := tc.status.Ready(); got != tc.ready {
+			t.Errorf("%+v: Ready() = %v, want %v", tc.status, got, tc.ready)
+		}
+		if got := tc.status.Problem(); got != tc.problem {
+			t.Errorf("%+v: Problem() = %q, want %q", tc.status, got, tc.problem)
+		}
+	}
+}
+
+func TestReadStatusByte(t *testing.T) {
+	// An ASB packet arriving before the reply is skipped
+	b, err := readStatusByte(bytes.NewReader([]byte{0x10, 0x00, 0x00, 0x00, statusBase | 0x08}))
+	if err != nil || b != statusBase|0x08 {
+		t.Errorf("readStatusByte = %#x, %v; want %#x", b, err, statusBase|0x08)
+	}
+	if _, err := readStatusByte(bytes.NewReader(bytes.Repeat([]byte{0xFF}, 20))); err == nil {
+		t.Error("readStatusByte accepted bytes that are not status")
+	}
+}
+
+func TestPrinterInfoStatus(t *testing.T) {
+	for _, tc := range []struct {
+		status DeviceStatus
+		want   string
+	}{
+		{DeviceStatus{Online: true, PaperLow: true}, "Ready"},
+		{DeviceStatus{}, "Offline"},
+		{DeviceStatus{PaperOut: true}, "Paper out"},
+		{DeviceStatus{Online: true, Errors: []string{"autocutter error"}}, "Autocutter error"},
+		{DeviceStatus{Errors: []string{"no status reply"}, Stale: true}, "Unknown"},
+	} {
+		direct := ConfiguredPrinters(map[string]Settings{"Kitchen": {Address: "tcp://10.0.0.50:9100"}})[0]
+		if direct.Status != "Unknown" {
+			t.Fatalf("printer listed as %q before its status was read", direct.Status)
+		}
+		direct.SetDeviceStatus(tc.status)
+		if direct.Status != tc.want {
+			t.Errorf("%+v: Status = %q, want %q", tc.status, direct.Status, tc.want)
+		}
+
+		// An OS queue reports its own status
+		queue := PrinterInfo{Name: "EPSON", Status: "Paused"}
+		queue.SetDeviceStatus(tc.status)
+		if queue.Status != "Paused" || queue.DeviceStatus == nil {
+			t.Errorf("%+v: OS queue listed as %q", tc.status, queue.Status)
+		}
+	}
+}
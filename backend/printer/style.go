@@ -0,0 +1,97 @@
+package printer
+
+// Underline thickness accepted by SetUnderline.
+const (
+	UnderlineNone  = 0
+	UnderlineThin  = 1
+	UnderlineThick = 2
+)
+
+// SetUnderline underlines text that follows (ESC -). mode is 0 (off),
+// 1 (one dot) or 2 (two dots); larger values are treated as 2.
+func (e *EscposAdapter) SetUnderline(mode uint8) {
+	e.underline = min(mode, UnderlineThick)
+	e.buf.Write([]byte{0x1B, 0x2D, e.underline})
+}
+
+// SetReverse prints white text on a black background (GS B), e.g. for a
+// "PAID" banner. It does not affect images.
+func (e *EscposAdapter) SetReverse(enabled bool) {
+	e.reverse = enabled
+	e.buf.Write([]byte{0x1D, 0x42, boolByte(enabled)})
+}
+
+// SetUpsideDown turns text 180° (ESC {). The printer only switches at the
+// start of a line.
+func (e *EscposAdapter) SetUpsideDown(enabled bool) {
+	e.upsideDown = enabled
+	e.buf.Write([]byte{0x1B, 0x7B, boolByte(enabled)})
+}
+
+// SetRotate90 turns each character 90° clockwise (ESC V), for label-style
+// tickets read along the paper.
+func (e *EscposAdapter) SetRotate90(enabled bool) {
+	e.rotate = enabled
+	e.buf.Write([]byte{0x1B, 0x56, boolByte(enabled)})
+}
+
+// SetLineSpacing sets the distance between lines in dots (ESC 3). 0 restores
+// the printer's default spacing (ESC 2).
+func (e *EscposAdapter) SetLineSpacing(dots int) {
+	e.lineSpacing = clamp(dots, 0, 255)
+	e.writeLineSpacing()
+}
+
+func (e *EscposAdapter) writeLineSpacing() {
+	if e.lineSpacing > 0 {
+		e.buf.Write([]byte{0x1B, 0x33, byte(e.lineSpacing)})
+	} else {
+		e.buf.Write([]byte{0x1B, 0x32})
+	}
+}
+
+// SetCharSpacing adds dots of space to the right of every character (ESC SP).
+// Columns shrinks to match.
+func (e *EscposAdapter) SetCharSpacing(dots int) {
+	e.charSpacing = clamp(dots, 0, 255)
+	e.buf.Write([]byte{0x1B, 0x20, byte(e.charSpacing)})
+}
+
+// SetPrintArea sets the left margin and the printable width in dots
+// (GS L / GS W). Zero for both restores the configured layout. Columns and
+// the width images are scaled to follow the new area.
+func (e *EscposAdapter) SetPrintArea(left, width int) {
+	e.flushLine()
+	if left <= 0 && width <= 0 {
+		e.areaWidth = 0
+		left, width = e.layout.MarginLeft, e.layout.PrintableWidth()
+	} else {
+		left = clamp(left, 0, e.layout.DotWidth-dotsPerBlock)
+		if width <= 0 || left+width > e.layout.DotWidth {
+			width = e.layout.DotWidth - left
+		}
+		e.areaWidth = width
+	}
+	e.writePrintArea(left, width)
+}
+
+func (e *EscposAdapter) writePrintArea(left, width int) {
+	// GS L nL nH: left margin, GS W nL nH: print area width (in dots)
+	e.buf.Write([]byte{0x1D, 0x4C, byte(left % 256), byte(left / 256)})
+	e.buf.Write([]byte{0x1D, 0x57, byte(width % 256), byte(width / 256)})
+}
+
+// printableWidth is the width of the current print area in dots.
+func (e *EscposAdapter) printableWidth() int {
+	if e.areaWidth > 0 {
+		return e.areaWidth
+	}
+	return e.layout.PrintableWidth()
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
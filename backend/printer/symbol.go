@@ -0,0 +1,158 @@
+package printer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"ts-escpos/backend/receipt"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/datamatrix"
+	"github.com/boombuler/barcode/pdf417"
+)
+
+const (
+	defaultPDF417ModuleWidth = 2
+	defaultPDF417RowHeight   = 3 // multiples of the module width
+	defaultPDF417ECC         = 2
+	defaultDataMatrixModule  = 4
+	symbolQuietZone          = 2 // modules of white space around a raster 2D symbol
+)
+
+// PrintPDF417 prints a PDF417 symbol. Printers whose profile allows it render
+// the symbol themselves (GS ( k); others receive a raster image.
+func (e *EscposAdapter) PrintPDF417(data string, opts receipt.PDF417Options) {
+	if data == "" {
+		return
+	}
+	e.flushLine()
+
+	moduleWidth := opts.ModuleWidth
+	if moduleWidth < 1 || moduleWidth > 8 {
+		moduleWidth = defaultPDF417ModuleWidth
+	}
+	rowHeight := opts.RowHeight
+	if rowHeight < 2 || rowHeight > 8 {
+		rowHeight = defaultPDF417RowHeight
+	}
+	ecc := opts.ECC
+	if ecc <= 0 || ecc > 8 {
+		ecc = defaultPDF417ECC
+	}
+
+	if e.profile.NativePDF417 {
+		e.printNativePDF417(data, max(2, moduleWidth), rowHeight, opts.Columns, ecc)
+		return
+	}
+
+	img, err := PDF417Image(data, ecc, moduleWidth, rowHeight, e.printableWidth())
+	if err != nil {
+		fmt.Printf("Error creating PDF417: %v\n", err)
+		return
+	}
+	e.printGraphics(img)
+}
+
+// PDF417Image draws a PDF417 symbol with rows rowHeight times the module width,
+// narrowing the modules if the symbol would be wider than maxWidth dots.
+func PDF417Image(data string, ecc, moduleWidth, rowHeight, maxWidth int) (image.Image, error) {
+	bc, err := pdf417.Encode(data, byte(ecc))
+	if err != nil {
+		return nil, err
+	}
+
+	// The encoder draws every row two pixels high
+	rows := bc.Bounds().Dy() / 2
+	cols := bc.Bounds().Dx()
+	if n := cols + 2*symbolQuietZone; maxWidth > 0 && n*moduleWidth > maxWidth {
+		moduleWidth = max(1, maxWidth/n)
+	}
+	return symbolImage(bc, cols, rows, moduleWidth, moduleWidth*rowHeight), nil
+}
+
+// printNativePDF417 stores the data in the printer's symbol buffer and prints it
+// using the GS ( k functions for PDF417 (cn = 48).
+func (e *EscposAdapter) printNativePDF417(data string, moduleWidth, rowHeight, columns, ecc int) {
+	if columns < 0 || columns > 30 {
+		columns = 0
+	}
+	// Function 065: number of data columns (0 = automatic)
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x30, 0x41, byte(columns)})
+	// Function 067: module width in dots
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x30, 0x43, byte(moduleWidth)})
+	// Function 068: row height as a multiple of the module width
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x30, 0x44, byte(rowHeight)})
+	// Function 069: error correction by level (m = 48, n = 48 + level)
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x04, 0x00, 0x30, 0x45, 0x30, byte(0x30 + ecc)})
+
+	// Function 080: store data; pL pH count the 3 bytes cn fn m as well
+	n := len(data) + 3
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, byte(n % 256), byte(n / 256), 0x30, 0x50, 0x30})
+	e.buf.WriteString(data)
+
+	// Function 081: print the stored symbol
+	e.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x30, 0x51, 0x30})
+}
+
+// PrintDataMatrix prints a DataMatrix symbol. ESC/POS printers rarely support
+// DataMatrix natively, so it is always drawn as a raster image.
+func (e *EscposAdapter) PrintDataMatrix(data string, opts receipt.DataMatrixOptions) {
+	if data == "" {
+		return
+	}
+	e.flushLine()
+
+	moduleSize := opts.ModuleSize
+	if moduleSize <= 0 || moduleSize > 16 {
+		moduleSize = defaultDataMatrixModule
+	}
+
+	img, err := DataMatrixImage(data, moduleSize, e.printableWidth())
+	if err != nil {
+		fmt.Printf("Error creating DataMatrix: %v\n", err)
+		return
+	}
+	e.printGraphics(img)
+}
+
+// DataMatrixImage draws a DataMatrix symbol, shrinking the modules if the
+// symbol would be wider than maxWidth dots.
+func DataMatrixImage(data string, moduleSize, maxWidth int) (image.Image, error) {
+	bc, err := datamatrix.Encode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cols, rows := bc.Bounds().Dx(), bc.Bounds().Dy()
+	if n := cols + 2*symbolQuietZone; maxWidth > 0 && n*moduleSize > maxWidth {
+		moduleSize = max(1, maxWidth/n)
+	}
+	return symbolImage(bc, cols, rows, moduleSize, moduleSize), nil
+}
+
+// symbolImage redraws a cols x rows module matrix with each module
+// moduleWidth x moduleHeight dots, surrounded by a quiet zone.
+func symbolImage(bc barcode.Barcode, cols, rows, moduleWidth, moduleHeight int) image.Image {
+	b := bc.Bounds()
+	quietX, quietY := symbolQuietZone*moduleWidth, symbolQuietZone*moduleHeight
+	img := image.NewGray(image.Rect(0, 0, cols*moduleWidth+2*quietX, rows*moduleHeight+2*quietY))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	for r := 0; r < rows; r++ {
+		srcY := b.Min.Y + r*b.Dy()/rows
+		for c := 0; c < cols; c++ {
+			if g := color.GrayModel.Convert(bc.At(b.Min.X+c, srcY)).(color.Gray); g.Y >= 128 {
+				continue
+			}
+			x0, y0 := quietX+c*moduleWidth, quietY+r*moduleHeight
+			for y := y0; y < y0+moduleHeight; y++ {
+				for x := x0; x < x0+moduleWidth; x++ {
+					img.SetGray(x, y, color.Gray{Y: 0})
+				}
+			}
+		}
+	}
+	return img
+}
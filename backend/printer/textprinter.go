@@ -0,0 +1,92 @@
+package printer
+
+import (
+	"strings"
+
+	"ts-escpos/backend/receipt"
+)
+
+// TextPrinter renders a receipt as plain UTF-8 text, e.g. for e-mail or a
+// log. Alignment is kept by padding lines to the paper's columns; pictures
+// are replaced by their text (the QR code's URL) or left out.
+type TextPrinter struct {
+	*document
+	columns int
+}
+
+func NewTextPrinter(columns int) *TextPrinter {
+	if columns <= 0 {
+		columns = documentLayout(Settings{}).ColumnsA
+	}
+	layout := Layout{ColumnsA: columns, ColumnsB: columns}.Resolve()
+	return &TextPrinter{document: newDocument(layout), columns: columns}
+}
+
+func (t *TextPrinter) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (t *TextPrinter) GetBytes() []byte {
+	var sb strings.Builder
+	blank := false
+	for _, b := range t.finish() {
+		var line string
+		switch {
+		case b.cut:
+			line = strings.Repeat("-", t.columns)
+		case b.image != nil:
+			if b.alt == "" {
+				continue
+			}
+			line = b.alt
+		default:
+			line = textLine(b.runs)
+		}
+
+		// Feeds only separate sections, so runs of them collapse to one line
+		line = strings.TrimRight(line, " ")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		sb.WriteString(t.pad(line, b.align))
+		sb.WriteByte('\n')
+	}
+	return []byte(strings.TrimRight(sb.String(), "\n") + "\n")
+}
+
+// textLine joins the runs of a line, spacing out double-width text so it
+// takes as many columns as it would on paper.
+func textLine(runs []docRun) string {
+	var sb strings.Builder
+	for _, r := range runs {
+		if r.style.width == 0 {
+			sb.WriteString(r.text)
+			continue
+		}
+		gap := strings.Repeat(" ", int(r.style.width))
+		for _, c := range r.text {
+			sb.WriteRune(c)
+			sb.WriteString(gap)
+		}
+	}
+	return sb.String()
+}
+
+func (t *TextPrinter) pad(line, align string) string {
+	room := t.columns - receipt.DisplayWidth(line)
+	if line == "" || room <= 0 {
+		return line
+	}
+	switch align {
+	case "center":
+		return strings.Repeat(" ", room/2) + line
+	case "right":
+		return strings.Repeat(" ", room) + line
+	}
+	return line
+}
@@ -0,0 +1,429 @@
+package printer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/go-text/typesetting/bidi"
+	"github.com/go-text/typesetting/di"
+	"github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/language"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Scripts that thermal printers have no built-in glyphs for are shaped with
+// these fonts and printed as raster images instead of text. The Tamil font
+// is generated by gen_tamil_font.go.
+//
+//go:generate go run gen_tamil_font.go
+//go:embed fonts/*.ttf
+var embeddedFonts embed.FS
+
+// Pixel sizes chosen so Go Mono advances exactly one printer cell
+// (Font A is 12x24 dots, Font B is 9x17), keeping padded columns aligned.
+const (
+	rasterFontSizeA = 20
+	rasterFontSizeB = 15
+)
+
+// Well-known locations of fonts covering scripts we do not embed (e.g.
+// Bengali, Telugu, Kannada, Malayalam, Gujarati).
+var systemFontCandidates = map[string][]string{
+	"windows": {
+		`C:\Windows\Fonts\Nirmala.ttc`,
+		`C:\Windows\Fonts\Nirmala.ttf`,
+	},
+	"darwin": {
+		"/System/Library/Fonts/Supplemental/Bangla Sangam MN.ttc",
+		"/System/Library/Fonts/Supplemental/Telugu Sangam MN.ttc",
+		"/System/Library/Fonts/Supplemental/Kannada Sangam MN.ttc",
+		"/System/Library/Fonts/Supplemental/Malayalam Sangam MN.ttc",
+		"/System/Library/Fonts/Supplemental/Gujarati Sangam MN.ttc",
+	},
+	"linux": {
+		"/usr/share/fonts/truetype/noto/NotoSansBengali-Regular.ttf",
+		"/usr/share/fonts/truetype/noto/NotoSansTelugu-Regular.ttf",
+		"/usr/share/fonts/truetype/noto/NotoSansKannada-Regular.ttf",
+		"/usr/share/fonts/truetype/noto/NotoSansMalayalam-Regular.ttf",
+		"/usr/share/fonts/truetype/noto/NotoSansGujarati-Regular.ttf",
+	},
+}
+
+var (
+	fontsOnce   sync.Once
+	fontsMux    sync.RWMutex
+	rasterFonts []*font.Font // Go Mono first, so Latin and digits keep the printer's pitch
+)
+
+// RegisterFontFiles adds TrueType/OpenType files (e.g. from config.json) used
+// when rendering text the printer cannot print natively.
+func RegisterFontFiles(paths []string) {
+	fontsOnce.Do(loadRasterFonts)
+	for _, p := range paths {
+		addFontFile(p)
+	}
+}
+
+func loadRasterFonts() {
+	addFontData("gomono", gomono.TTF)
+
+	entries, _ := embeddedFonts.ReadDir("fonts")
+	for _, entry := range entries {
+		data, err := embeddedFonts.ReadFile("fonts/" + entry.Name())
+		if err != nil {
+			continue
+		}
+		addFontData(entry.Name(), data)
+	}
+
+	for _, p := range systemFontCandidates[runtime.GOOS] {
+		if _, err := os.Stat(p); err == nil {
+			addFontFile(p)
+		}
+	}
+}
+
+func addFontFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Warning: failed to read font %s: %v\n", path, err)
+		return
+	}
+	addFontData(filepath.Base(path), data)
+}
+
+func addFontData(name string, data []byte) {
+	var faces []*font.Face
+	var err error
+	if strings.HasSuffix(strings.ToLower(name), ".ttc") {
+		faces, err = font.ParseTTC(bytes.NewReader(data))
+	} else {
+		var face *font.Face
+		face, err = font.ParseTTF(bytes.NewReader(data))
+		faces = []*font.Face{face}
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to parse font %s: %v\n", name, err)
+		return
+	}
+
+	fontsMux.Lock()
+	defer fontsMux.Unlock()
+	for _, f := range faces {
+		rasterFonts = append(rasterFonts, f.Font)
+	}
+}
+
+func loadedFonts() []*font.Font {
+	fontsOnce.Do(loadRasterFonts)
+	fontsMux.RLock()
+	defer fontsMux.RUnlock()
+	return rasterFonts
+}
+
+// hasRasterGlyph reports whether any raster font can draw r.
+func hasRasterGlyph(r rune) bool {
+	for _, f := range loadedFonts() {
+		if _, ok := f.Cmap.Lookup(r); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// rasterFontmap resolves each rune to the first font that has a glyph for it.
+// Faces are created per render because font.Face is not safe for concurrent use.
+type rasterFontmap []*font.Face
+
+func newRasterFontmap() rasterFontmap {
+	fonts := loadedFonts()
+	faces := make(rasterFontmap, len(fonts))
+	for i, f := range fonts {
+		faces[i] = font.NewFace(f)
+	}
+	return faces
+}
+
+func (m rasterFontmap) ResolveFace(r rune) *font.Face {
+	for _, f := range m {
+		if _, ok := f.NominalGlyph(r); ok {
+			return f
+		}
+	}
+	return m[0]
+}
+
+// textRun is a piece of a line written with one set of text styles.
+type textRun struct {
+	text      string
+	bold      bool
+	font      string
+	width     uint8
+	height    uint8
+	underline uint8 // thickness in dots
+	reverse   bool
+}
+
+type shapedRun struct {
+	shaping.Output
+	run   textRun
+	level bidi.Level
+}
+
+// isRTL reports whether the paragraph direction of text is right-to-left,
+// using the first strong (letter) character as the Unicode bidi algorithm does.
+func isRTL(text string) bool {
+	for _, r := range text {
+		if unicode.In(r, unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko) {
+			return true
+		}
+		if unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return false
+}
+
+// rasterizeText shapes a single line made of styled runs and draws it
+// black-on-white. The returned image is as wide as the text; alignment is
+// left to the printer (ESC a). rtl reports the paragraph direction.
+func rasterizeText(runs []textRun) (img *image.Gray, rtl bool) {
+	// Padding at the logical end would otherwise offset centred/right text
+	trimmed := make([]textRun, len(runs))
+	copy(trimmed, runs)
+	for i := len(trimmed) - 1; i >= 0; i-- {
+		trimmed[i].text = strings.TrimRightFunc(trimmed[i].text, unicode.IsSpace)
+		if trimmed[i].text != "" {
+			break
+		}
+	}
+
+	// Resolve bidi levels over the whole line so numbers and Latin words
+	// inside Arabic are placed as a reader expects
+	var text []rune
+	var style []int
+	for i, r := range trimmed {
+		for _, c := range r.text {
+			text = append(text, c)
+			style = append(style, i)
+		}
+	}
+	if len(text) == 0 {
+		return image.NewGray(image.Rect(0, 0, 1, 1)), false
+	}
+
+	rtl = isRTL(string(text))
+	paraDir := bidi.LeftToRight
+	if rtl {
+		paraDir = bidi.RightToLeft
+	}
+	var para bidi.Paragraph
+	levels := para.Segment(text, paraDir)
+
+	fontmap := newRasterFontmap()
+	var seg shaping.Segmenter
+	shaper := &shaping.HarfbuzzShaper{}
+
+	var shaped []shapedRun
+	for i := 0; i < levels.NumRuns(); i++ {
+		lr := levels.Run(i)
+		dir := di.DirectionLTR
+		if !lr.IsLeftToRight() {
+			dir = di.DirectionRTL
+		}
+
+		// Split the bidi run further wherever the text style changes
+		for start := lr.Start; start < lr.End; {
+			end := start
+			for end < lr.End && style[end] == style[start] {
+				end++
+			}
+			r := trimmed[style[start]]
+			px := rasterFontSizeA
+			if r.font == "B" {
+				px = rasterFontSizeB
+			}
+			input := shaping.Input{
+				Text:      text,
+				RunStart:  start,
+				RunEnd:    end,
+				Direction: dir,
+				Size:      fixed.I(px),
+				Language:  language.DefaultLanguage(),
+			}
+			for _, in := range seg.Split(input, fontmap) {
+				shaped = append(shaped, shapedRun{Output: shaper.Shape(in), run: r, level: lr.Level})
+			}
+			start = end
+		}
+	}
+
+	shaped = visualOrder(shaped)
+
+	// Measure the line
+	var width, ascent, descent float32
+	for _, s := range shaped {
+		xs, ys := scaleFactors(s.run)
+		width += fixedToFloat(s.Advance) * xs
+		if a := fixedToFloat(s.LineBounds.Ascent) * ys; a > ascent {
+			ascent = a
+		}
+		if d := -fixedToFloat(s.LineBounds.Descent) * ys; d > descent {
+			descent = d
+		}
+	}
+
+	w := int(width + 2) // room for the synthetic bold offset
+	h := int(ascent + descent + 1)
+	underlineY := int(ascent) + 2
+	for _, s := range shaped {
+		h = max(h, underlineY+int(s.run.underline))
+	}
+
+	z := vector.NewRasterizer(w, h)
+	pen := float32(0)
+	spans := make([][2]int, len(shaped))
+	for i, s := range shaped {
+		spans[i][0] = int(pen)
+		xs, ys := scaleFactors(s.run)
+		scale := fixedToFloat(s.Size) / float32(s.Face.Upem())
+		for _, g := range s.Glyphs {
+			outline, ok := s.Face.GlyphDataOutline(g.GlyphID)
+			if ok {
+				x := pen + fixedToFloat(g.XOffset)*xs
+				y := ascent - fixedToFloat(g.YOffset)*ys
+				drawOutline(z, outline, x, y, scale*xs, scale*ys)
+				if s.run.bold {
+					drawOutline(z, outline, x+1, y, scale*xs, scale*ys)
+				}
+			}
+			pen += fixedToFloat(g.Advance) * xs
+		}
+		spans[i][1] = int(pen + 0.5)
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+
+	img = image.NewGray(mask.Bounds())
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.DrawMask(img, img.Bounds(), image.Black, image.Point{}, mask, image.Point{}, draw.Over)
+
+	// Underline and reverse are drawn here, as the printer does not apply them to images
+	for i, s := range shaped {
+		x0, x1 := spans[i][0], min(spans[i][1], w)
+		if s.run.underline > 0 {
+			ul := image.Rect(x0, underlineY, x1, underlineY+int(s.run.underline))
+			draw.Draw(img, ul, image.Black, image.Point{}, draw.Src)
+		}
+		if s.run.reverse {
+			for y := 0; y < h; y++ {
+				for x := x0; x < x1; x++ {
+					o := img.PixOffset(x, y)
+					img.Pix[o] = 255 - img.Pix[o]
+				}
+			}
+		}
+	}
+	return img, rtl
+}
+
+// rotate180 turns an image upside down, as ESC { does with text.
+func rotate180(src *image.Gray) *image.Gray {
+	b := src.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			dst.SetGray(b.Dx()-1-x, b.Dy()-1-y, src.GrayAt(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// visualOrder arranges shaped runs left to right following rule L2 of the
+// Unicode bidi algorithm: from the highest level down to the lowest odd
+// level, every sequence of runs at that level or above is reversed.
+// Glyphs within a run are already in visual order.
+func visualOrder(runs []shapedRun) []shapedRun {
+	out := make([]shapedRun, len(runs))
+	copy(out, runs)
+
+	var highest, lowestOdd bidi.Level = 0, 127
+	for _, r := range out {
+		if r.level > highest {
+			highest = r.level
+		}
+		if r.level%2 == 1 && r.level < lowestOdd {
+			lowestOdd = r.level
+		}
+	}
+
+	for lvl := highest; lvl >= lowestOdd && lvl > 0; lvl-- {
+		for i := 0; i < len(out); {
+			if out[i].level < lvl {
+				i++
+				continue
+			}
+			j := i
+			for j < len(out) && out[j].level >= lvl {
+				j++
+			}
+			reverseRuns(out[i:j])
+			i = j
+		}
+	}
+	return out
+}
+
+func reverseRuns(runs []shapedRun) {
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+}
+
+// scaleFactors maps GS ! character size multipliers onto the raster.
+func scaleFactors(r textRun) (float32, float32) {
+	return float32(r.width) + 1, float32(r.height) + 1
+}
+
+func drawOutline(z *vector.Rasterizer, o font.GlyphOutline, x, y, sx, sy float32) {
+	pt := func(p ot.SegmentPoint) (float32, float32) {
+		return x + p.X*sx, y - p.Y*sy // font units grow upwards
+	}
+	for _, s := range o.Segments {
+		switch s.Op {
+		case ot.SegmentOpMoveTo:
+			z.ClosePath()
+			z.MoveTo(pt(s.Args[0]))
+		case ot.SegmentOpLineTo:
+			z.LineTo(pt(s.Args[0]))
+		case ot.SegmentOpQuadTo:
+			bx, by := pt(s.Args[0])
+			cx, cy := pt(s.Args[1])
+			z.QuadTo(bx, by, cx, cy)
+		case ot.SegmentOpCubeTo:
+			bx, by := pt(s.Args[0])
+			cx, cy := pt(s.Args[1])
+			dx, dy := pt(s.Args[2])
+			z.CubeTo(bx, by, cx, cy, dx, dy)
+		}
+	}
+	z.ClosePath()
+}
+
+func fixedToFloat(v fixed.Int26_6) float32 {
+	return float32(v) / 64
+}
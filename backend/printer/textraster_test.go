@@ -0,0 +1,47 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-text/typesetting/di"
+	"github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/language"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestTamilFont(t *testing.T) {
+	data, err := embeddedFonts.ReadFile("fonts/NotoSansTamil-Regular.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	face, err := font.ParseTTF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for text, want := range map[string]string{
+		"கு":   "ka_uMatra-tamil",
+		"டீ":   "tta_iiMatra-tamil",
+		"க்ஷ":  "k_ssa-tamil",
+		"ஸ்ரீ": "sh_ra_iiMatra-tamil",
+	} {
+		runes := []rune(text)
+		out := (&shaping.HarfbuzzShaper{}).Shape(shaping.Input{
+			Text: runes, RunEnd: len(runes), Direction: di.DirectionLTR,
+			Face: face, Size: fixed.I(rasterFontSizeA), Script: language.Tamil,
+		})
+		if len(out.Glyphs) != 1 || face.GlyphName(out.Glyphs[0].GlyphID) != want {
+			var names []string
+			for _, g := range out.Glyphs {
+				names = append(names, face.GlyphName(g.GlyphID))
+			}
+			t.Errorf("%s shaped as %v, want %s", text, names, want)
+		}
+	}
+
+	// The AAT table of the upstream font crashed the shaper on this line
+	if img, _ := rasterizeText([]textRun{{text: "கொத்து பரோட்டா ₹120"}}); img.Bounds().Dx() <= 1 {
+		t.Error("Tamil line drew nothing")
+	}
+}
@@ -0,0 +1,268 @@
+package printer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoStatus is returned by transports that cannot read the printer's status.
+var ErrNoStatus = errors.New("printer has no status channel")
+
+// Transport delivers ESC/POS bytes to a printer.
+type Transport interface {
+	// Send writes one job to the printer and returns the ID the print
+	// queue gave it, 0 when there is no queue.
+	Send(ctx context.Context, data []byte) (int, error)
+	// Status reads the printer's live state over the same channel, or
+	// returns ErrNoStatus.
+	Status() (DeviceStatus, error)
+	Close() error
+}
+
+// NewTransport returns the transport for a printer: the OS print queue of
+// that name, or the connection given by its configured address.
+func NewTransport(name string, s Settings) (Transport, error) {
+	switch {
+	case s.Address == "":
+		return SpoolerTransport{Printer: name}, nil
+	case strings.HasPrefix(s.Address, "tcp://"):
+		return NewTCPTransport(s.Address, s), nil
+	case strings.HasPrefix(s.Address, "usb://"), strings.HasPrefix(s.Address, "serial://"):
+		return NewDeviceTransport(s.Address, s)
+	}
+	return nil, fmt.Errorf("unsupported printer address %q", s.Address)
+}
+
+// SpoolerTransport prints through the OS print queue (lp or winspool).
+type SpoolerTransport struct {
+	Printer string
+}
+
+func (t SpoolerTransport) Send(ctx context.Context, data []byte) (int, error) {
+	return PrintRaw(ctx, t.Printer, data)
+}
+
+func (t SpoolerTransport) Status() (DeviceStatus, error) {
+	return DeviceStatus{}, ErrNoStatus
+}
+
+func (t SpoolerTransport) Close() error {
+	return nil
+}
+
+const (
+	defaultConnectTimeout = 3 * time.Second
+	defaultWriteTimeout   = 30 * time.Second
+	tcpKeepAlive          = 30 * time.Second
+	// How long a connection or device is kept open after a job for the next one
+	idleTimeout = 5 * time.Second
+)
+
+// TCPTransport sends jobs straight to a network printer's raw port (9100),
+// without an OS queue. The connection is kept open with TCP keep-alive for
+// the jobs that follow in quick succession, then closed so other tills and
+// spoolers can reach printers that take one connection at a time. Status is
+// read back over it with DLE EOT.
+type TCPTransport struct {
+	addr           string
+	connectTimeout time.Duration
+	writeTimeout   time.Duration
+
+	mu        sync.Mutex
+	conn      net.Conn
+	idleTimer *time.Timer
+}
+
+// NewTCPTransport creates a transport for "tcp://host[:port]", port 9100 by
+// default. Timeouts come from the printer's settings.
+func NewTCPTransport(addr string, s Settings) *TCPTransport {
+	host := strings.TrimPrefix(addr, "tcp://")
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "9100")
+	}
+	t := &TCPTransport{
+		addr:           host,
+		connectTimeout: defaultConnectTimeout,
+		writeTimeout:   defaultWriteTimeout,
+	}
+	if s.ConnectTimeout > 0 {
+		t.connectTimeout = time.Duration(s.ConnectTimeout) * time.Second
+	}
+	if s.WriteTimeout > 0 {
+		t.writeTimeout = time.Duration(s.WriteTimeout) * time.Second
+	}
+	return t
+}
+
+func (t *TCPTransport) Send(ctx context.Context, data []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	logToFrontend(ctx, fmt.Sprintf("[Printer] Printing %d bytes to %s", len(data), t.addr))
+	dialCtx := ctx
+	if dialCtx == nil {
+		dialCtx = context.Background()
+	}
+	for attempt := 0; ; attempt++ {
+		conn, err := t.connect(dialCtx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to connect to %s: %w", t.addr, err)
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
+		n, err := conn.Write(data)
+		_ = conn.SetWriteDeadline(time.Time{})
+		if err == nil {
+			logToFrontend(ctx, fmt.Sprintf("[Printer] Successfully sent job to %s", t.addr))
+			t.closeWhenIdle(conn)
+			return 0, nil
+		}
+		t.closeConn()
+		// A kept connection the printer has dropped fails before anything is
+		// written; try once more on a new one
+		if n > 0 || attempt > 0 || dialCtx.Err() != nil {
+			logToFrontend(ctx, fmt.Sprintf("[Printer] Error printing to %s: %v", t.addr, err))
+			return 0, fmt.Errorf("failed to print to %s: %w", t.addr, err)
+		}
+	}
+}
+
+func (t *TCPTransport) Status() (DeviceStatus, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	conn, err := t.connect(context.Background())
+	if err != nil {
+		return DeviceStatus{}, err
+	}
+	// Many printers take one connection at a time; leave none open
+	defer t.closeConn()
+	return queryStatus(conn, defaultStatusTimeout)
+}
+
+func (t *TCPTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeConn()
+	return nil
+}
+
+func (t *TCPTransport) connect(ctx context.Context) (net.Conn, error) {
+	if t.conn != nil {
+		if alive(t.conn) {
+			return t.conn, nil
+		}
+		t.closeConn()
+	}
+	d := net.Dialer{Timeout: t.connectTimeout, KeepAlive: tcpKeepAlive}
+	conn, err := d.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+	t.conn = conn
+	return conn, nil
+}
+
+// alive reports whether the printer still has a kept connection open. A
+// write after the printer closed it would succeed and be lost, so look for
+// the close first; anything else it sent is stale and dropped.
+func alive(conn net.Conn) bool {
+	buf := make([]byte, 64)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+		_, err := conn.Read(buf)
+		if err != nil {
+			_ = conn.SetReadDeadline(time.Time{})
+			var ne net.Error
+			return errors.As(err, &ne) && ne.Timeout()
+		}
+	}
+}
+
+// closeWhenIdle closes conn unless another job uses it within idleTimeout.
+// Called with t.mu held.
+func (t *TCPTransport) closeWhenIdle(conn net.Conn) {
+	if t.idleTimer != nil {
+		t.idleTimer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(idleTimeout, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		// A timer replaced by a later job's fired while that job held the lock
+		if t.idleTimer == timer && t.conn == conn {
+			t.closeConn()
+		}
+	})
+	t.idleTimer = timer
+}
+
+func (t *TCPTransport) closeConn() {
+	if t.idleTimer != nil {
+		t.idleTimer.Stop()
+		t.idleTimer = nil
+	}
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
+
+// ConfiguredPrinters lists the printers reached by a configured address
+// rather than an OS queue, by name. Their status is "Unknown" until one is
+// read from the printer.
+func ConfiguredPrinters(printers map[string]Settings) []PrinterInfo {
+	var list []PrinterInfo
+	for name, s := range printers {
+		if s.Address == "" {
+			continue
+		}
+		list = append(list, PrinterInfo{
+			Name:     name,
+			UniqueID: s.Address,
+			Status:   "Unknown",
+			Address:  s.Address,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// ListPrinters returns the OS printers, the configured ones reached by
+// address and the USB printers not yet named in config. A
+// configured address replaces an OS queue of the same name.
+func ListPrinters(configured map[string]Settings) ([]PrinterInfo, error) {
+	list, err := GetPrinters()
+	direct := ConfiguredPrinters(configured)
+	devices := ListDevices()
+	out := make([]PrinterInfo, 0, len(list)+len(direct)+len(devices))
+	for _, p := range list {
+		if configured[p.Name].Address == "" {
+			out = append(out, p)
+		}
+	}
+
+	// A device named in config is listed under that name, with the model
+	// it reports
+	named := make(map[string]int, len(direct))
+	for i, p := range direct {
+		named[p.Address] = i
+	}
+	taken := make(map[string]bool, len(out))
+	for _, p := range out {
+		taken[p.Name] = true
+	}
+	for _, d := range devices {
+		if i, ok := named[d.Address]; ok {
+			direct[i].Driver = d.Driver
+		} else if !taken[d.Name] {
+			direct = append(direct, d)
+		}
+	}
+	return append(out, direct...), err
+}
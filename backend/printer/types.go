@@ -2,8 +2,24 @@ package printer
 
 // PrinterInfo holds core information relative to a printer
 type PrinterInfo struct {
-	Name      string `json:"name"`
-	UniqueID  string `json:"uniqueId"`
-	WindowsID string `json:"windowsId"`
-	Status    string `json:"status"` // "Ready", "Offline", etc.
+	Name      string   `json:"name"`
+	UniqueID  string   `json:"uniqueId"`
+	WindowsID string   `json:"windowsId"`
+	Status    string   `json:"status"` // "Ready", "Offline", etc.
+	Driver    string   `json:"driver,omitempty"`
+	Profile   string   `json:"profile,omitempty"` // Capability profile used for the printer
+	Address   string   `json:"address,omitempty"` // Where a printer without an OS queue is reached, e.g. "tcp://10.0.0.50:9100"
+	Roles     []string `json:"roles,omitempty"`   // Configured roles that print to this printer now
+	// Live status read from the printer, for printers with a status channel
+	*DeviceStatus
+}
+
+// SetDeviceStatus attaches the printer's live status. A printer reached by
+// address has no OS queue to report on it, so it is listed with the live
+// status instead.
+func (p *PrinterInfo) SetDeviceStatus(st DeviceStatus) {
+	p.DeviceStatus = &st
+	if p.Address != "" {
+		p.Status = st.Summary()
+	}
 }
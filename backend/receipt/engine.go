@@ -0,0 +1,619 @@
+package receipt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Render lays out order data with a template.
+func Render(p Printer, t *Template, data OrderData) {
+	r := &renderer{p: p, data: data, root: templateData(data)}
+	p.Init()
+	r.cur = textStyle{align: "left", font: "A"}
+	if t.DoubleStrike {
+		p.SetDoubleStrike(true)
+	}
+	r.render(t.Body, r.cur, nil)
+}
+
+// templateData turns order data into the generic values placeholders look up,
+// keyed by their JSON names, and adds the values templates cannot compute:
+//
+//	lineTotal              quantity × price of every item and child
+//	hasHeaderSymbols       the order has symbols for the header
+//	hasFooterSymbols       ... or for the footer
+//	paidInCash             part of the order was paid in cash
+//	shouldOpenCashDrawer   the bill kicks the cash drawer
+//	itemGroups             items by course and category: course, category,
+//	                       newCourse (first group of its course) and items
+//	prepTimeMinutes        the longest preparation time of the items
+//	readyBy                order time plus prepTimeMinutes, e.g. "01:50 PM"
+func templateData(data OrderData) map[string]interface{} {
+	raw, _ := json.Marshal(data)
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var root map[string]interface{}
+	if err := dec.Decode(&root); err != nil {
+		fmt.Printf("Warning: order data for template: %v\n", err)
+		root = map[string]interface{}{}
+	}
+
+	var addLineTotals func(list interface{}, items []OrderItem)
+	addLineTotals = func(list interface{}, items []OrderItem) {
+		entries, _ := list.([]interface{})
+		for i, e := range entries {
+			m, ok := e.(map[string]interface{})
+			if !ok || i >= len(items) {
+				continue
+			}
+			m["lineTotal"] = float64(items[i].Quantity) * items[i].Price
+			addLineTotals(m["children"], items[i].Children)
+		}
+	}
+	addLineTotals(root["items"], data.Items)
+
+	root["hasHeaderSymbols"] = hasSymbols(data.Symbols, "header")
+	root["hasFooterSymbols"] = hasSymbols(data.Symbols, "footer")
+	root["paidInCash"] = data.PaidInCash()
+	root["shouldOpenCashDrawer"] = data.ShouldOpenCashDrawer()
+
+	items, _ := root["items"].([]interface{})
+	itemGroups := data.ItemGroups()
+	var groups []interface{}
+	for i, g := range itemGroups {
+		entries := make([]interface{}, 0, len(g.Items))
+		for _, n := range g.Items {
+			if n < len(items) {
+				entries = append(entries, items[n])
+			}
+		}
+		groups = append(groups, map[string]interface{}{
+			"course":    g.Course,
+			"category":  g.Category,
+			"newCourse": i == 0 || !strings.EqualFold(g.Course, itemGroups[i-1].Course),
+			"items":     entries,
+		})
+	}
+	root["itemGroups"] = groups
+	root["prepTimeMinutes"] = float64(data.PrepTimeMinutes())
+	root["readyBy"] = ""
+	if t, ok := data.ReadyBy(); ok {
+		root["readyBy"] = t.Format("03:04 PM")
+	}
+	return root
+}
+
+// scope holds the loop variables of the elements being rendered.
+type scope struct {
+	name   string
+	value  interface{}
+	parent *scope
+}
+
+type renderer struct {
+	p    Printer
+	data OrderData
+	root map[string]interface{}
+	cur  textStyle // style last sent to the printer
+}
+
+// textStyle is the style an element prints with.
+type textStyle struct {
+	align     string
+	font      string
+	bold      bool
+	underline bool
+	reverse   bool
+	width     uint8
+	height    uint8
+}
+
+// with returns s changed by the style fields an element sets.
+func (s textStyle) with(el Element) textStyle {
+	if el.Align != "" {
+		s.align = el.Align
+	}
+	if el.Font != "" {
+		s.font = strings.ToUpper(el.Font)
+	}
+	if el.Bold != nil {
+		s.bold = *el.Bold
+	}
+	if el.Underline != nil {
+		s.underline = *el.Underline
+	}
+	if el.Reverse != nil {
+		s.reverse = *el.Reverse
+	}
+	if el.Size != "" {
+		s.width, s.height, _ = parseSize(el.Size)
+	}
+	return s
+}
+
+// apply sends the parts of a style that differ from the printer's.
+func (r *renderer) apply(s textStyle) {
+	if s.align != r.cur.align {
+		r.p.SetAlign(s.align)
+	}
+	if s.font != r.cur.font {
+		r.p.SetFont(s.font)
+	}
+	if s.bold != r.cur.bold {
+		r.p.SetBold(s.bold)
+	}
+	if s.underline != r.cur.underline {
+		if s.underline {
+			r.p.SetUnderline(1)
+		} else {
+			r.p.SetUnderline(0)
+		}
+	}
+	if s.reverse != r.cur.reverse {
+		r.p.SetReverse(s.reverse)
+	}
+	if s.width != r.cur.width || s.height != r.cur.height {
+		r.p.SetSize(s.width, s.height)
+	}
+	r.cur = s
+}
+
+func (r *renderer) render(elements []Element, style textStyle, sc *scope) {
+	for _, el := range elements {
+		if el.If != "" && !r.test(el.If, sc) {
+			r.render(el.Else, style, sc)
+			continue
+		}
+		s := style.with(el)
+
+		switch el.Type {
+		case ElementText:
+			if len(el.Spans) == 0 {
+				r.apply(s)
+				r.p.Write(r.expand(el.Text, sc) + "\n")
+				continue
+			}
+			for _, span := range el.Spans {
+				if span.If != "" && !r.test(span.If, sc) {
+					continue
+				}
+				r.apply(s.with(span))
+				r.p.Write(r.expand(span.Text, sc))
+			}
+			r.p.Write("\n")
+		case ElementDivider:
+			char := el.Char
+			if char == "" {
+				char = "-"
+			}
+			r.apply(s)
+			r.p.Write(strings.Repeat(char, r.p.Columns()/max(1, DisplayWidth(char))) + "\n")
+		case ElementRow:
+			r.apply(s)
+			for _, line := range r.row(el, sc) {
+				r.p.Write(line + "\n")
+			}
+		case ElementSection:
+			r.render(el.Body, s, sc)
+		case ElementEach:
+			list, _ := r.lookup(el.Each, sc).([]interface{})
+			name := el.As
+			if name == "" {
+				name = "item"
+			}
+			for _, entry := range list {
+				r.render(el.Body, s, &scope{name: name, value: entry, parent: sc})
+			}
+		case ElementFeed:
+			lines := r.number(el.Lines, sc)
+			if lines <= 0 {
+				lines = 1
+			}
+			r.p.Feed(uint8(min(lines, 255)))
+		case ElementCut:
+			r.p.Cut()
+		case ElementImage:
+			url := r.expand(el.URL, sc)
+			if url == "" {
+				continue
+			}
+			var opts ImageOptions
+			if el.Options != "" {
+				// Round-trip through JSON to read the options out of the order data
+				raw, _ := json.Marshal(r.lookup(el.Options, sc))
+				json.Unmarshal(raw, &opts)
+			}
+			r.apply(s)
+			r.p.PrintImage(url, opts)
+		case ElementQRCode:
+			r.apply(s)
+			r.p.PrintQRCode(r.expand(el.Data, sc), QRCodeOptions{
+				Size: r.number(el.ModuleSize, sc),
+				ECC:  r.expand(string(el.ECC), sc),
+			})
+		case ElementBarcode:
+			symbology := el.Symbology
+			if symbology == "" {
+				symbology = BarcodeCODE128
+			}
+			r.apply(s)
+			r.p.PrintBarcode(symbology, r.expand(el.Data, sc), BarcodeOptions{
+				Height: r.number(el.Height, sc),
+				HRI:    el.HRI,
+			})
+		case ElementSymbols:
+			if !hasSymbols(r.data.Symbols, el.Position) {
+				continue
+			}
+			// printSymbols centres them itself
+			s.align = "center"
+			r.apply(s)
+			printSymbols(r.p, r.data.Symbols, el.Position)
+		case ElementDrawer:
+			r.p.OpenCashDrawer(r.number(el.Pin, sc), 0, 0)
+		}
+	}
+}
+
+// row lays out the columns of a row element, wrapping long cells onto
+// continuation lines.
+func (r *renderer) row(el Element, sc *scope) []string {
+	t := Table{Width: r.p.Columns(), Gap: 1}
+	if el.Gap != nil {
+		t.Gap = max(0, *el.Gap)
+	}
+	cells := make([]string, len(el.Columns))
+	for i, c := range el.Columns {
+		width := c.Width
+		if t.Width >= 48 && c.WideWidth > 0 {
+			width = c.WideWidth
+		}
+		t.Columns = append(t.Columns, TableColumn{Width: width, Align: c.Align, Truncate: c.Overflow == OverflowTruncate})
+		cells[i] = r.expand(c.Text, sc)
+	}
+	return t.Row(cells...)
+}
+
+// number expands an Expr and reads it as an integer, 0 when it is not one.
+func (r *renderer) number(e Expr, sc *scope) int {
+	f, err := strconv.ParseFloat(strings.TrimSpace(r.expand(string(e), sc)), 64)
+	if err != nil {
+		return 0
+	}
+	return int(f)
+}
+
+// lookup resolves a dotted path such as "item.children" or "items.0.name"
+// against the loop variables, then the order data.
+func (r *renderer) lookup(path string, sc *scope) interface{} {
+	parts := strings.Split(strings.TrimSpace(path), ".")
+	var v interface{} = r.root
+	for s := sc; s != nil; s = s.parent {
+		if s.name == parts[0] {
+			v = s.value
+			parts = parts[1:]
+			break
+		}
+	}
+	for _, part := range parts {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// expand replaces the placeholders in a text.
+func (r *renderer) expand(text string, sc *scope) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	parts, err := parseText(text)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return text
+	}
+	var sb strings.Builder
+	for _, part := range parts {
+		if part.path == "" {
+			sb.WriteString(part.literal)
+			continue
+		}
+		value := toString(r.lookup(part.path, sc))
+		for _, f := range part.formats {
+			value = applyFormat(value, f)
+		}
+		sb.WriteString(value)
+	}
+	return sb.String()
+}
+
+// textPart is literal text or a placeholder with its formatters.
+type textPart struct {
+	literal string
+	path    string
+	formats []format
+}
+
+type format struct {
+	name string
+	arg  string
+}
+
+func parseText(text string) ([]textPart, error) {
+	var parts []textPart
+	for text != "" {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			parts = append(parts, textPart{literal: text})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, textPart{literal: text[:start]})
+		}
+		end := strings.Index(text[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in %q", text)
+		}
+		fields := strings.Split(text[start+2:start+end], "|")
+		part := textPart{path: strings.TrimSpace(fields[0])}
+		if part.path == "" {
+			return nil, fmt.Errorf("empty placeholder in %q", text)
+		}
+		for _, f := range fields[1:] {
+			name, arg, _ := strings.Cut(strings.TrimSpace(f), ":")
+			name = strings.TrimSpace(name)
+			if _, ok := formatters[name]; !ok {
+				return nil, fmt.Errorf("unknown formatter %q in %q", name, text)
+			}
+			part.formats = append(part.formats, format{name: name, arg: unquote(strings.TrimSpace(arg))})
+		}
+		parts = append(parts, part)
+		text = text[start+end+2:]
+	}
+	return parts, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// formatters change a placeholder's value, e.g. {{total | money}} or
+// {{item.quantity | left:4}}.
+var formatters = map[string]func(value, arg string) string{
+	"upper": func(v, _ string) string { return strings.ToUpper(v) },
+	"lower": func(v, _ string) string { return strings.ToLower(v) },
+	"trim":  func(v, _ string) string { return strings.TrimSpace(v) },
+	// money prints two decimals
+	"money": func(v, _ string) string { return fixed(v, 2) },
+	// fixed:N prints N decimals
+	"fixed": func(v, arg string) string {
+		n, _ := strconv.Atoi(arg)
+		return fixed(v, n)
+	},
+	"int": func(v, _ string) string { return fixed(v, 0) },
+	// left:N, right:N and center:N pad the value to N columns
+	"left":   func(v, arg string) string { return Pad(v, atoi(arg), "left") },
+	"right":  func(v, arg string) string { return Pad(v, atoi(arg), "right") },
+	"center": func(v, arg string) string { return Pad(v, atoi(arg), "center") },
+	// truncate:N cuts the value to N columns
+	"truncate": func(v, arg string) string { return Truncate(v, atoi(arg)) },
+	// default:"text" replaces an empty value
+	"default": func(v, arg string) string {
+		if v == "" {
+			return arg
+		}
+		return v
+	},
+	// prefix and suffix add text around a value that is not empty
+	"prefix": func(v, arg string) string {
+		if v == "" {
+			return v
+		}
+		return arg + v
+	},
+	"suffix": func(v, arg string) string {
+		if v == "" {
+			return v
+		}
+		return v + arg
+	},
+}
+
+func applyFormat(value string, f format) string {
+	return formatters[f.name](value, f.arg)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return max(0, n)
+}
+
+func fixed(v string, decimals int) string {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return v
+	}
+	return strconv.FormatFloat(f, 'f', max(0, decimals), 64)
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case json.Number:
+		f, _ := v.Float64()
+		return f != 0
+	case float64:
+		return v != 0
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// condition is a parsed If: terms joined by ||, each a list joined by &&.
+type condition [][]comparison
+
+type comparison struct {
+	not      bool
+	left, op string
+	right    string
+}
+
+var comparisonOps = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+func parseCondition(text string) (condition, error) {
+	var c condition
+	for _, or := range strings.Split(text, "||") {
+		var terms []comparison
+		for _, and := range strings.Split(or, "&&") {
+			term := strings.TrimSpace(and)
+			var cmp comparison
+			for _, op := range comparisonOps {
+				if i := strings.Index(term, op); i >= 0 {
+					cmp.left, cmp.op, cmp.right = strings.TrimSpace(term[:i]), op, strings.TrimSpace(term[i+len(op):])
+					break
+				}
+			}
+			if cmp.op == "" {
+				cmp.left = term
+				for strings.HasPrefix(cmp.left, "!") {
+					cmp.not = !cmp.not
+					cmp.left = strings.TrimSpace(cmp.left[1:])
+				}
+			}
+			if cmp.left == "" || (cmp.op != "" && cmp.right == "") {
+				return nil, fmt.Errorf("invalid condition %q", text)
+			}
+			terms = append(terms, cmp)
+		}
+		c = append(c, terms)
+	}
+	return c, nil
+}
+
+func (r *renderer) test(text string, sc *scope) bool {
+	c, err := parseCondition(text)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return false
+	}
+	for _, terms := range c {
+		ok := true
+		for _, cmp := range terms {
+			if !r.compare(cmp, sc) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *renderer) compare(c comparison, sc *scope) bool {
+	if c.op == "" {
+		return truthy(r.operand(c.left, sc)) != c.not
+	}
+	left, right := toString(r.operand(c.left, sc)), toString(r.operand(c.right, sc))
+	lf, lerr := strconv.ParseFloat(left, 64)
+	rf, rerr := strconv.ParseFloat(right, 64)
+	if lerr == nil && rerr == nil {
+		switch c.op {
+		case "==":
+			return lf == rf
+		case "!=":
+			return lf != rf
+		case ">":
+			return lf > rf
+		case "<":
+			return lf < rf
+		case ">=":
+			return lf >= rf
+		case "<=":
+			return lf <= rf
+		}
+	}
+	switch c.op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	}
+	return false
+}
+
+// operand reads a literal (number, quoted text, true or false) or a path.
+func (r *renderer) operand(s string, sc *scope) interface{} {
+	if s == "true" || s == "false" {
+		return s == "true"
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return json.Number(s)
+	}
+	if q := unquote(s); q != s {
+		return q
+	}
+	return r.lookup(s, sc)
+}
+
+// parseSize reads an element size into GS ! multipliers (0 for normal).
+func parseSize(size string) (width, height uint8, err error) {
+	switch strings.ToLower(size) {
+	case "", "normal":
+		return 0, 0, nil
+	case "wide":
+		return 1, 0, nil
+	case "tall":
+		return 0, 1, nil
+	case "double":
+		return 1, 1, nil
+	}
+	w, h, ok := strings.Cut(strings.ToLower(size), "x")
+	wn, werr := strconv.Atoi(w)
+	hn, herr := strconv.Atoi(h)
+	if !ok || werr != nil || herr != nil || wn < 1 || wn > 8 || hn < 1 || hn > 8 {
+		return 0, 0, fmt.Errorf("invalid size %q", size)
+	}
+	return uint8(wn - 1), uint8(hn - 1), nil
+}
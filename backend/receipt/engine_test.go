@@ -0,0 +1,197 @@
+package receipt
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testRenderer returns a renderer over order data given as JSON, decoded the
+// way templateData decodes it.
+func testRenderer(t *testing.T, data string) *renderer {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var root map[string]interface{}
+	if err := dec.Decode(&root); err != nil {
+		t.Fatal(err)
+	}
+	return &renderer{root: root}
+}
+
+const engineData = `{
+	"total": 1234.5,
+	"zero": 0,
+	"empty": "",
+	"name": "  Masala Dosa ",
+	"orderType": "Dine-In",
+	"paid": true,
+	"none": [],
+	"items": [
+		{"name": "Tea", "quantity": 2, "children": [{"name": "Sugar"}]},
+		{"name": "Coffee", "quantity": 1}
+	],
+	"store": {"city": "Pune", "info": {"gst": "27ABC"}}
+}`
+
+func TestLookup(t *testing.T) {
+	r := testRenderer(t, engineData)
+	item := r.lookup("items.1", nil)
+	sc := &scope{name: "item", value: item, parent: &scope{name: "store", value: "shadowed"}}
+
+	for _, tc := range []struct {
+		path string
+		sc   *scope
+		want string
+	}{
+		{"orderType", nil, "Dine-In"},
+		{"store.city", nil, "Pune"},
+		{"store.info.gst", nil, "27ABC"},
+		{" store.city ", nil, "Pune"},
+		{"items.0.name", nil, "Tea"},
+		{"items.0.children.0.name", nil, "Sugar"},
+		{"items.2.name", nil, ""},
+		{"items.-1.name", nil, ""},
+		{"items.first.name", nil, ""},
+		{"missing", nil, ""},
+		{"store.city.name", nil, ""},
+		{"item.name", sc, "Coffee"},
+		{"item.quantity", sc, "1"},
+		{"store", sc, "shadowed"},
+		{"orderType", sc, "Dine-In"},
+		{"item.name", nil, ""},
+	} {
+		if got := toString(r.lookup(tc.path, tc.sc)); got != tc.want {
+			t.Errorf("lookup(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestFormatters(t *testing.T) {
+	r := testRenderer(t, engineData)
+	for _, tc := range []struct {
+		text string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"Type: {{orderType}}", "Type: Dine-In"},
+		{"{{orderType | upper}}", "DINE-IN"},
+		{"{{orderType | lower}}", "dine-in"},
+		{"[{{name | trim}}]", "[Masala Dosa]"},
+		{"{{total | money}}", "1234.50"},
+		{"{{total | fixed:3}}", "1234.500"},
+		{"{{total | fixed:-1}}", "1234"},
+		{"{{total | int}}", "1234"},
+		{"{{orderType | money}}", "Dine-In"},
+		{"[{{orderType | left:10}}]", "[Dine-In   ]"},
+		{"[{{orderType | right:10}}]", "[   Dine-In]"},
+		{"[{{orderType | center:9}}]", "[ Dine-In ]"},
+		{"{{orderType | truncate:4}}", "Dine"},
+		{"{{empty | default:\"n/a\"}}", "n/a"},
+		{"{{orderType | default:'n/a'}}", "Dine-In"},
+		{"{{store.city | prefix:\"City: \"}}", "City: Pune"},
+		{"{{empty | prefix:\"City: \"}}", ""},
+		{"{{items.0.quantity | suffix:\" x\"}}", "2 x"},
+		{"{{empty | suffix:\" x\"}}", ""},
+		{"[{{total | money | right:10}}]", "[   1234.50]"},
+		{"{{name | trim | upper | truncate:6}}", "MASALA"},
+		// Placeholders that cannot be parsed are printed as written
+		{"{{orderType", "{{orderType"},
+		{"{{ }}", "{{ }}"},
+		{"{{orderType | shout}}", "{{orderType | shout}}"},
+	} {
+		if got := r.expand(tc.text, nil); got != tc.want {
+			t.Errorf("expand(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestConditions(t *testing.T) {
+	r := testRenderer(t, engineData)
+	sc := &scope{name: "item", value: r.lookup("items.0", nil)}
+	for _, tc := range []struct {
+		cond string
+		want bool
+	}{
+		// Truthiness
+		{"paid", true},
+		{"orderType", true},
+		{"items", true},
+		{"store", true},
+		{"empty", false},
+		{"zero", false},
+		{"none", false},
+		{"missing", false},
+		{"true", true},
+		{"false", false},
+		{"0", false},
+		{"'text'", true},
+		// Negation
+		{"!paid", false},
+		{"!missing", true},
+		{"!!paid", true},
+		{"! empty", true},
+		// Numeric comparisons
+		{"total > 1000", true},
+		{"total < 1000", false},
+		{"total >= 1234.5", true},
+		{"total <= 1234.49", false},
+		{"total == 1234.50", true},
+		{"total != 1234.5", false},
+		{"items.0.quantity > items.1.quantity", true},
+		// Text comparisons
+		{"orderType == 'Dine-In'", true},
+		{"orderType == \"Takeaway\"", false},
+		{"orderType != 'Takeaway'", true},
+		{"empty == ''", true},
+		{"missing == ''", true},
+		{"orderType > 'A'", false},
+		{"paid == true", true},
+		// Loop variables
+		{"item.quantity == 2", true},
+		{"item.children", true},
+		{"item.name == 'Coffee'", false},
+		// && binds tighter than ||
+		{"paid && total > 1000", true},
+		{"paid && empty", false},
+		{"empty || zero", false},
+		{"empty || paid", true},
+		{"empty && zero || paid", true},
+		{"paid && empty || zero", false},
+		// Invalid conditions are false
+		{"", false},
+		{"paid &&", false},
+		{"total >", false},
+		{"== 5", false},
+		{"!", false},
+	} {
+		if got := r.test(tc.cond, sc); got != tc.want {
+			t.Errorf("test(%q) = %v, want %v", tc.cond, got, tc.want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		size          string
+		width, height uint8
+		ok            bool
+	}{
+		{"", 0, 0, true},
+		{"normal", 0, 0, true},
+		{"wide", 1, 0, true},
+		{"Tall", 0, 1, true},
+		{"double", 1, 1, true},
+		{"3x2", 2, 1, true},
+		{"8X8", 7, 7, true},
+		{"0x1", 0, 0, false},
+		{"9x1", 0, 0, false},
+		{"2", 0, 0, false},
+		{"huge", 0, 0, false},
+	} {
+		w, h, err := parseSize(tc.size)
+		if (err == nil) != tc.ok || w != tc.width || h != tc.height {
+			t.Errorf("parseSize(%q) = %d, %d, %v", tc.size, w, h, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package receipt
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// TableColumn is a column of a Table.
+type TableColumn struct {
+	Width    int    // Display columns; 0 shares the room left on the line
+	Align    string // "left", "center" or "right"
+	Truncate bool   // Cut long text instead of wrapping it
+}
+
+// Table lays out rows of cells in columns of a fixed-width line. Text is
+// measured by display width, so wide (CJK) characters take two columns and
+// combining marks none, and cells too long for their column wrap onto
+// continuation lines with the other columns left blank.
+type Table struct {
+	Width   int // Columns of the line
+	Gap     int // Spaces between columns
+	Columns []TableColumn
+}
+
+// Widths returns the width of every column. Columns without a width share
+// the room left after the fixed ones and the gaps, at least one each.
+func (t Table) Widths() []int {
+	widths := make([]int, len(t.Columns))
+	used, fill := t.Gap*max(0, len(t.Columns)-1), 0
+	for i, c := range t.Columns {
+		widths[i] = max(0, c.Width)
+		if widths[i] == 0 {
+			fill++
+		}
+		used += widths[i]
+	}
+	if fill == 0 {
+		return widths
+	}
+	room := max(fill, t.Width-used)
+	for i := range widths {
+		if widths[i] == 0 {
+			widths[i] = room / fill
+			room -= widths[i]
+			fill--
+		}
+	}
+	return widths
+}
+
+// Row returns the lines of one row of cells, one per column. Lines end at
+// their last character rather than padded to the width.
+func (t Table) Row(cells ...string) []string {
+	widths := t.Widths()
+	columns := make([][]string, len(t.Columns))
+	lines := 1
+	for i, c := range t.Columns {
+		var text string
+		if i < len(cells) {
+			text = cells[i]
+		}
+		if c.Truncate {
+			columns[i] = []string{Truncate(text, widths[i])}
+		} else {
+			columns[i] = Wrap(text, widths[i])
+		}
+		lines = max(lines, len(columns[i]))
+	}
+
+	out := make([]string, lines)
+	gap := strings.Repeat(" ", t.Gap)
+	for n := range out {
+		parts := make([]string, len(t.Columns))
+		for i, c := range t.Columns {
+			var text string
+			if n < len(columns[i]) {
+				text = columns[i][n]
+			}
+			parts[i] = Pad(text, widths[i], c.Align)
+		}
+		out[n] = strings.TrimRight(strings.Join(parts, gap), " ")
+	}
+	return out
+}
+
+// DisplayWidth returns the number of columns text takes on a fixed-width line.
+func DisplayWidth(text string) int {
+	n := 0
+	for _, r := range text {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case unicode.IsControl(r), unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// Pad aligns text in width columns. Longer text is left as it is.
+func Pad(text string, columns int, align string) string {
+	room := columns - DisplayWidth(text)
+	if room <= 0 {
+		return text
+	}
+	switch align {
+	case "right":
+		return strings.Repeat(" ", room) + text
+	case "center":
+		return strings.Repeat(" ", room/2) + text + strings.Repeat(" ", room-room/2)
+	}
+	return text + strings.Repeat(" ", room)
+}
+
+// Truncate cuts text to at most the given columns, keeping combining marks
+// with their letter.
+func Truncate(text string, columns int) string {
+	n := 0
+	for i, r := range text {
+		n += runeWidth(r)
+		if n > columns {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// Wrap breaks text into lines of at most the given columns, between words
+// where it can and inside words longer than a line. Leading spaces are kept
+// on the first line.
+func Wrap(text string, columns int) []string {
+	if columns <= 0 || DisplayWidth(text) <= columns {
+		return []string{text}
+	}
+
+	var lines []string
+	line := text[:len(text)-len(strings.TrimLeft(text, " "))]
+	empty := true
+	for _, word := range strings.Fields(text) {
+		switch {
+		case empty:
+			line += word
+			empty = false
+		case DisplayWidth(line)+1+DisplayWidth(word) <= columns:
+			line += " " + word
+			continue
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+		for DisplayWidth(line) > columns {
+			head := Truncate(line, columns)
+			if head == "" {
+				// A character wider than the column takes a line of its own
+				_, size := utf8.DecodeRuneInString(line)
+				if size == len(line) {
+					break
+				}
+				head = line[:size]
+			}
+			lines = append(lines, head)
+			line = line[len(head):]
+		}
+	}
+	if !empty || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,160 @@
+package receipt
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+const (
+	cafe     = "Café"       // "Café" with a combining acute accent
+	hindi    = "पनीर टिक्का" // Devanagari with vowel signs and a virama
+	noodles  = "牛肉面"         // Three wide characters
+	fullWide = "ＡＢ"          // Fullwidth Latin
+)
+
+func TestDisplayWidth(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"Paneer", 6},
+		{"Café", 4},
+		{cafe, 4},
+		{"₹ 120", 5},
+		{noodles, 6},
+		{fullWide, 4},
+		{"Tea 茶", 6},
+		{"a‍b", 2}, // zero-width joiner
+		{"\t\n", 0},
+	} {
+		if got := DisplayWidth(tc.text); got != tc.want {
+			t.Errorf("DisplayWidth(%q) = %d, want %d", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, tc := range []struct {
+		text    string
+		columns int
+		want    string
+	}{
+		{"Paneer Tikka", 6, "Paneer"},
+		{"Tea", 10, "Tea"},
+		{"Tea", 0, ""},
+		{"Café au lait", 4, "Café"},
+		// The combining accent stays with its letter
+		{cafe + " au lait", 4, cafe},
+		{cafe, 3, "Caf"},
+		// A wide character that would straddle the edge is left out
+		{noodles, 5, "牛肉"},
+		{noodles, 1, ""},
+		{"A牛", 2, "A"},
+		{"₹₹₹", 2, "₹₹"},
+	} {
+		got := Truncate(tc.text, tc.columns)
+		if got != tc.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tc.text, tc.columns, got, tc.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("Truncate(%q, %d) split a character: %q", tc.text, tc.columns, got)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	for _, tc := range []struct {
+		text    string
+		columns int
+		want    []string
+	}{
+		{"Butter Naan", 20, []string{"Butter Naan"}},
+		{"Butter Garlic Naan", 10, []string{"Butter", "Garlic", "Naan"}},
+		{"Veg Thali with extra rice", 12, []string{"Veg Thali", "with extra", "rice"}},
+		{"  Extra Gravy on the side", 12, []string{"  Extra", "Gravy on the", "side"}},
+		{"Supercalifragilistic", 8, []string{"Supercal", "ifragili", "stic"}},
+		{"Tea Supercalifragilistic", 8, []string{"Tea", "Supercal", "ifragili", "stic"}},
+		{cafe + " " + cafe + " " + cafe, 9, []string{cafe + " " + cafe, cafe}},
+		{hindi, 6, []string{"पनीर", "टिक्का"}},
+		{"牛肉面 套餐", 6, []string{noodles, "套餐"}},
+		{"牛肉面套餐", 4, []string{"牛肉", "面套", "餐"}},
+		// A character wider than its column gets a line of its own
+		{noodles, 1, []string{"牛", "肉", "面"}},
+		{"A牛B", 1, []string{"A", "牛", "B"}},
+		{"牛 A 肉", 1, []string{"牛", "A", "肉"}},
+		{"anything", 0, []string{"anything"}},
+		{"", 5, []string{""}},
+	} {
+		got := Wrap(tc.text, tc.columns)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Wrap(%q, %d) = %q, want %q", tc.text, tc.columns, got, tc.want)
+		}
+		for _, line := range got {
+			if !utf8.ValidString(line) {
+				t.Errorf("Wrap(%q, %d) split a character: %q", tc.text, tc.columns, line)
+			}
+		}
+	}
+}
+
+func TestPad(t *testing.T) {
+	for _, tc := range []struct {
+		text    string
+		columns int
+		align   string
+		want    string
+	}{
+		{"Tea", 6, "left", "Tea   "},
+		{"Tea", 6, "right", "   Tea"},
+		{"Tea", 6, "center", " Tea  "},
+		{noodles, 8, "right", "  " + noodles},
+		{cafe, 6, "left", cafe + "  "},
+		{"Too long", 3, "right", "Too long"},
+	} {
+		if got := Pad(tc.text, tc.columns, tc.align); got != tc.want {
+			t.Errorf("Pad(%q, %d, %s) = %q, want %q", tc.text, tc.columns, tc.align, got, tc.want)
+		}
+	}
+}
+
+func TestTableRow(t *testing.T) {
+	items := Table{Width: 32, Gap: 1, Columns: []TableColumn{
+		{},
+		{Width: 3, Align: "right"},
+		{Width: 8, Align: "right"},
+	}}
+	if got, want := items.Widths(), []int{19, 3, 8}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Widths() = %v, want %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		table Table
+		cells []string
+		want  []string
+	}{
+		{items, []string{"Butter Naan", "2", "80.00"},
+			[]string{"Butter Naan           2    80.00"}},
+		{items, []string{"Paneer Tikka Masala Full", "1", "280.00"},
+			[]string{"Paneer Tikka Masala   1   280.00", "Full"}},
+		{items, []string{noodles + " " + noodles + " " + noodles, "1", "9.50"},
+			[]string{noodles + " " + noodles + "         1     9.50", noodles}},
+		{items, []string{cafe + " Latte", "2", "90.00"},
+			[]string{cafe + " Latte            2    90.00"}},
+		// Missing cells are blank
+		{items, []string{"Service charge"},
+			[]string{"Service charge"}},
+		{Table{Width: 12, Gap: 1, Columns: []TableColumn{{Truncate: true}, {Width: 4, Align: "right"}}},
+			[]string{"牛肉面套餐 Combo", "1"},
+			[]string{"牛肉面     1"}},
+		{Table{Width: 10, Columns: []TableColumn{{Width: 5, Align: "center"}, {Width: 5, Align: "center"}}},
+			[]string{"Qty", "牛"},
+			[]string{" Qty  牛"}},
+	} {
+		got := tc.table.Row(tc.cells...)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Row(%q) = %q, want %q", tc.cells, got, tc.want)
+		}
+	}
+}
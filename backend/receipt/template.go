@@ -0,0 +1,289 @@
+package receipt
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template is a receipt layout read from JSON or YAML. Its body is a list of
+// elements rendered top to bottom against the order data; see Element for
+// the placeholders, conditions and loops they can use.
+type Template struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// DoubleStrike darkens all text, as thermal prints of the built-ins do
+	DoubleStrike bool      `json:"doubleStrike,omitempty"`
+	Body         []Element `json:"body"`
+}
+
+// Element types.
+const (
+	ElementText    = "text"    // One line of text, or of styled spans
+	ElementDivider = "divider" // A full-width rule of char ("-" by default)
+	ElementRow     = "row"     // Text in fixed-width columns
+	ElementSection = "section" // Groups elements under one condition and style
+	ElementEach    = "each"    // Repeats its body for every entry of a list
+	ElementFeed    = "feed"
+	ElementCut     = "cut"
+	ElementImage   = "image"
+	ElementQRCode  = "qrcode"
+	ElementBarcode = "barcode"
+	ElementSymbols = "symbols" // The order's extra 2D codes at a position
+	ElementDrawer  = "drawer"  // Kicks the cash drawer
+)
+
+// Element is one entry of a template body.
+//
+// Text fields hold {{path}} placeholders, e.g. "{{storeInfo.brandName}}" or
+// "{{item.price | money}}", naming order data by its JSON keys; see
+// formatters in engine.go. If is a condition such as
+// "displayOptions.showTaxBreakdown", "!tableNo" or "child.price > 0", joined
+// with && and ||; when it fails the Else elements are rendered instead.
+//
+// Style fields left empty inherit from the enclosing section or loop.
+type Element struct {
+	Type string    `json:"type"`
+	If   string    `json:"if,omitempty"`
+	Else []Element `json:"else,omitempty"`
+
+	Align     string `json:"align,omitempty"` // "left", "center" or "right"
+	Font      string `json:"font,omitempty"`  // "A" or "B"
+	Bold      *bool  `json:"bold,omitempty"`
+	Underline *bool  `json:"underline,omitempty"`
+	Reverse   *bool  `json:"reverse,omitempty"`
+	Size      string `json:"size,omitempty"` // "normal", "wide", "tall", "double" or "WxH" multipliers such as "3x2"
+
+	Text    string    `json:"text,omitempty"`
+	Spans   []Element `json:"spans,omitempty"`   // text written on one line, each with its own style and condition
+	Char    string    `json:"char,omitempty"`    // divider
+	Columns []Column  `json:"columns,omitempty"` // row
+	Gap     *int      `json:"gap,omitempty"`     // row: spaces between columns, default 1
+
+	Each string    `json:"each,omitempty"` // each: path of the list
+	As   string    `json:"as,omitempty"`   // each: name of the entry in the body, default "item"
+	Body []Element `json:"body,omitempty"` // section and each
+
+	Lines Expr `json:"lines,omitempty"` // feed
+
+	URL     string `json:"url,omitempty"`     // image
+	Options string `json:"options,omitempty"` // image: path of ImageOptions in the order data
+
+	Data       string `json:"data,omitempty"`       // qrcode and barcode
+	Symbology  string `json:"symbology,omitempty"`  // barcode, default CODE128
+	ModuleSize Expr   `json:"moduleSize,omitempty"` // qrcode
+	ECC        Expr   `json:"ecc,omitempty"`        // qrcode
+	Height     Expr   `json:"height,omitempty"`     // barcode
+	HRI        string `json:"hri,omitempty"`        // barcode
+
+	Position string `json:"position,omitempty"` // symbols: "header" or "footer"
+	Pin      Expr   `json:"pin,omitempty"`      // drawer
+}
+
+// Column is a cell of a row. Columns are padded to their width; those
+// without one share the room left on the line. Text longer than its column
+// wraps onto continuation lines, or is cut with overflow "truncate".
+type Column struct {
+	Text  string `json:"text"`
+	Width int    `json:"width,omitempty"`
+	// WideWidth replaces Width on lines of 48 columns or more (80mm paper)
+	WideWidth int    `json:"wideWidth,omitempty"`
+	Align     string `json:"align,omitempty"`
+	Overflow  string `json:"overflow,omitempty"` // "wrap" (default) or "truncate"
+}
+
+// Column overflow modes.
+const (
+	OverflowWrap     = "wrap"
+	OverflowTruncate = "truncate"
+)
+
+// Expr is a number or a text with placeholders, so that a setting can come
+// from the order data: "moduleSize": 6 or "moduleSize": "{{displayOptions.qrCodeSize}}".
+type Expr string
+
+func (e *Expr) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*e = Expr(s)
+		return nil
+	}
+	*e = Expr(bytes.TrimSpace(data))
+	return nil
+}
+
+// UnmarshalJSON accepts a template object or its source as a JSON or YAML string.
+func (t *Template) UnmarshalJSON(data []byte) error {
+	var src string
+	if err := json.Unmarshal(data, &src); err == nil {
+		parsed, err := ParseTemplate([]byte(src))
+		if err != nil {
+			return err
+		}
+		*t = *parsed
+		return nil
+	}
+	type plain Template
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode((*plain)(t)); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	return t.Validate()
+}
+
+// ParseTemplate reads a template from JSON or YAML. Unknown keys are
+// rejected so that typos do not silently drop part of a layout.
+func ParseTemplate(src []byte) (*Template, error) {
+	// YAML is a superset of JSON; decode it generically and check the result
+	// against the JSON field names
+	var doc interface{}
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("invalid template: expected an object")
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	var t Template
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Validate checks the element types and the syntax of placeholders and conditions.
+func (t *Template) Validate() error {
+	if len(t.Body) == 0 {
+		return fmt.Errorf("template %q has no body", t.ID)
+	}
+	return validateElements(t.Body, "body")
+}
+
+func validateElements(elements []Element, where string) error {
+	for i, el := range elements {
+		at := fmt.Sprintf("%s[%d]", where, i)
+		switch el.Type {
+		case ElementText, ElementDivider, ElementRow, ElementSection, ElementEach, ElementFeed,
+			ElementCut, ElementImage, ElementQRCode, ElementBarcode, ElementSymbols, ElementDrawer:
+		case "":
+			return fmt.Errorf("%s: missing type", at)
+		default:
+			return fmt.Errorf("%s: unknown type %q", at, el.Type)
+		}
+		if el.If != "" {
+			if _, err := parseCondition(el.If); err != nil {
+				return fmt.Errorf("%s: %w", at, err)
+			}
+		}
+		if _, _, err := parseSize(el.Size); err != nil {
+			return fmt.Errorf("%s: %w", at, err)
+		}
+		texts := []string{el.Text, el.URL, el.Data, string(el.Lines), string(el.ModuleSize), string(el.ECC), string(el.Height), string(el.Pin)}
+		for _, c := range el.Columns {
+			texts = append(texts, c.Text)
+		}
+		for _, text := range texts {
+			if _, err := parseText(text); err != nil {
+				return fmt.Errorf("%s: %w", at, err)
+			}
+		}
+		switch el.Type {
+		case ElementEach:
+			if el.Each == "" {
+				return fmt.Errorf("%s: each needs the path of a list", at)
+			}
+		case ElementRow:
+			if len(el.Columns) == 0 {
+				return fmt.Errorf("%s: row has no columns", at)
+			}
+			for j, c := range el.Columns {
+				if c.Overflow != "" && c.Overflow != OverflowWrap && c.Overflow != OverflowTruncate {
+					return fmt.Errorf("%s.columns[%d]: overflow must be wrap or truncate", at, j)
+				}
+			}
+		case ElementSymbols:
+			if el.Position != "header" && el.Position != "footer" {
+				return fmt.Errorf("%s: symbols position must be header or footer", at)
+			}
+		}
+		if err := validateSpans(el.Spans, at+".spans"); err != nil {
+			return err
+		}
+		if err := validateElements(el.Body, at+".body"); err != nil {
+			return err
+		}
+		if err := validateElements(el.Else, at+".else"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSpans checks the spans of a text element, which are text without a type.
+func validateSpans(spans []Element, where string) error {
+	for i, span := range spans {
+		if span.Type != "" && span.Type != ElementText {
+			return fmt.Errorf("%s[%d]: spans are text, not %q", where, i, span.Type)
+		}
+		if len(span.Spans) > 0 || len(span.Else) > 0 {
+			return fmt.Errorf("%s[%d]: spans cannot have spans or else", where, i)
+		}
+		span.Type = ElementText
+		if err := validateElements([]Element{span}, fmt.Sprintf("%s[%d]", where, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//go:embed templates/*.yaml
+var builtinTemplateFiles embed.FS
+
+// Built-in template IDs.
+const (
+	TemplateBill = "bill"
+	TemplateKOT  = "kot"
+)
+
+var builtinTemplates = map[string]*Template{}
+
+func init() {
+	files, _ := builtinTemplateFiles.ReadDir("templates")
+	for _, f := range files {
+		src, _ := builtinTemplateFiles.ReadFile(path.Join("templates", f.Name()))
+		t, err := ParseTemplate(src)
+		if err != nil {
+			panic(fmt.Sprintf("invalid built-in template %s: %v", f.Name(), err))
+		}
+		builtinTemplates[t.ID] = t
+	}
+}
+
+// LookupTemplate returns a built-in template by ID.
+func LookupTemplate(id string) (*Template, error) {
+	if t, ok := builtinTemplates[strings.ToLower(id)]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("unknown template %q", id)
+}
+
+// BuiltinTemplates returns the templates shipped with the app, by ID.
+func BuiltinTemplates() []*Template {
+	var list []*Template
+	for _, t := range builtinTemplates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
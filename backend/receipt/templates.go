@@ -3,7 +3,10 @@ package receipt
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type OrderItemChildren []OrderItem
@@ -32,6 +35,12 @@ type OrderItem struct {
 	Children       OrderItemChildren `json:"children"`
 	TaxAmount      float64           `json:"taxAmount"`
 	DiscountAmount float64           `json:"discountAmount"`
+
+	// KOT fields
+	Category        string `json:"category"`        // Menu category, e.g. "Tandoor"
+	Course          string `json:"course"`          // e.g. "Starters" or "Mains"
+	PrepTimeMinutes int    `json:"prepTimeMinutes"` // Minutes the kitchen needs for the item
+	Station         string `json:"station"`         // Kitchen station, overriding the routing rules
 }
 
 type TaxItem struct {
@@ -64,17 +73,91 @@ type StoreInfo struct {
 	FooterText     string `json:"footerText"`
 	ShowLogo       bool   `json:"showLogo"`
 	LogoURL        string `json:"logoURL"`
-	GST            string `json:"gst"`
-	Address        string `json:"address"`
-	City           string `json:"city"`
-	ContactNumber  string `json:"contactNumber"`
-	Email          string `json:"email"`
-	Policy         string `json:"policy"`
-	FSSAIState     string `json:"fssaiState"`
-	FSSAICentral   string `json:"fssaiCentral"`
-	CIN            string `json:"cin"`
-	LLPIN          string `json:"llpin"`
-	Website        string `json:"website"`
+	// LogoOptions overrides the printer's image settings for the logo
+	LogoOptions   ImageOptions `json:"logoOptions"`
+	GST           string       `json:"gst"`
+	Address       string       `json:"address"`
+	City          string       `json:"city"`
+	ContactNumber string       `json:"contactNumber"`
+	Email         string       `json:"email"`
+	Policy        string       `json:"policy"`
+	FSSAIState    string       `json:"fssaiState"`
+	FSSAICentral  string       `json:"fssaiCentral"`
+	CIN           string       `json:"cin"`
+	LLPIN         string       `json:"llpin"`
+	Website       string       `json:"website"`
+}
+
+// Dithering methods accepted in ImageOptions.Dither.
+const (
+	DitherThreshold      = "threshold"
+	DitherFloydSteinberg = "floyd-steinberg"
+	DitherAtkinson       = "atkinson"
+	DitherOrdered        = "ordered" // 8x8 Bayer matrix
+)
+
+// ImageOptions controls how a picture is converted to black and white dots.
+// Zero values leave the printer's own setting (or the default) in place.
+type ImageOptions struct {
+	Dither     string  `json:"dither,omitempty"`     // "threshold", "floyd-steinberg" (default), "atkinson" or "ordered"
+	Threshold  int     `json:"threshold,omitempty"`  // Grey level (1-255) below which a dot prints, default 128
+	Gamma      float64 `json:"gamma,omitempty"`      // Values above 1 lighten mid-tones, default 1
+	Brightness int     `json:"brightness,omitempty"` // -100 to 100
+	Contrast   int     `json:"contrast,omitempty"`   // -100 to 100
+}
+
+// QRCodeOptions controls how a QR code is printed.
+type QRCodeOptions struct {
+	Size int    `json:"size"` // Module size in dots (1-16), 0 for the default
+	ECC  string `json:"ecc"`  // Error correction level: "L", "M" (default), "Q" or "H"
+}
+
+// Barcode symbologies accepted by Printer.PrintBarcode.
+const (
+	BarcodeCODE128 = "CODE128"
+	BarcodeEAN13   = "EAN13"
+	BarcodeCODE39  = "CODE39"
+	BarcodeITF     = "ITF"
+)
+
+// BarcodeOptions controls how a 1D barcode is printed.
+type BarcodeOptions struct {
+	Height int    `json:"height"` // Bar height in dots (1-255), 0 for the default
+	Width  int    `json:"width"`  // Module width in dots (2-6), 0 for the default
+	HRI    string `json:"hri"`    // Human readable text: "none", "above", "below" (default) or "both"
+}
+
+// PDF417Options controls how a PDF417 symbol is printed.
+type PDF417Options struct {
+	ModuleWidth int `json:"moduleWidth"` // Module width in dots (1-8), 0 for the default
+	RowHeight   int `json:"rowHeight"`   // Row height as a multiple of the module width (2-8), 0 for the default
+	Columns     int `json:"columns"`     // Data columns (1-30) on printers that render PDF417 natively, 0 for automatic
+	ECC         int `json:"ecc"`         // Error correction level (1-8), 0 for the default
+}
+
+// DataMatrixOptions controls how a DataMatrix symbol is printed.
+type DataMatrixOptions struct {
+	ModuleSize int `json:"moduleSize"` // Module size in dots (1-16), 0 for the default
+}
+
+// 2D symbol types accepted in OrderData.Symbols.
+const (
+	SymbolQR         = "qr"
+	SymbolPDF417     = "pdf417"
+	SymbolDataMatrix = "datamatrix"
+)
+
+// Symbol is an extra 2D code placed on a receipt, such as a food-safety PDF417
+// or a DataMatrix label.
+type Symbol struct {
+	Type     string `json:"type"` // "qr", "pdf417" or "datamatrix"
+	Data     string `json:"data"`
+	Label    string `json:"label"`    // Optional caption printed above the symbol
+	Position string `json:"position"` // "header" (after the store details) or "footer" (default)
+
+	Size    int    `json:"size"`    // Module size (QR, DataMatrix) or module width (PDF417) in dots
+	ECC     string `json:"ecc"`     // QR: "L", "M", "Q", "H"; PDF417: "1"-"8"
+	Columns int    `json:"columns"` // PDF417 only
 }
 
 type DisplayOptions struct {
@@ -85,6 +168,13 @@ type DisplayOptions struct {
 	ShowBarcode           bool   `json:"showBarcode"`
 	ShowQRCode            bool   `json:"showQRCode"`
 	QrCodeData            string `json:"qrCodeData"`
+	QrCodeSize            int    `json:"qrCodeSize"`
+	QrCodeECC             string `json:"qrCodeEcc"`
+
+	// GST e-invoice QR (signed IRN payload), printed after the totals
+	EInvoiceQrData string `json:"eInvoiceQrData"`
+	EInvoiceQrSize int    `json:"eInvoiceQrSize"`
+	EInvoiceQrECC  string `json:"eInvoiceQrEcc"`
 
 	// KOT fields
 	ShowTableInfo       bool `json:"showTableInfo"`
@@ -101,6 +191,7 @@ type OrderData struct {
 	CustomerContact string         `json:"customerContact"`
 	TableNo         string         `json:"tableNo"`
 	OrderType       string         `json:"orderType"` // For KOT
+	Station         string         `json:"station"`   // Kitchen station a routed KOT is for
 	OrderSource     string         `json:"orderSource"`
 	CashierName     string         `json:"cashierName"`
 	Items           []OrderItem    `json:"items"`
@@ -115,20 +206,50 @@ type OrderData struct {
 	DiscountBreakdown []DiscountItem `json:"discountBreakdown"`
 	Charges           []ChargeItem   `json:"charges"`
 	Payments          []PaymentItem  `json:"payments"`
+
+	Symbols []Symbol `json:"symbols"`
+
+	// OpenCashDrawer kicks the cash drawer after a bill paid (partly) in cash
+	OpenCashDrawer bool `json:"openCashDrawer"`
+	CashDrawerPin  int  `json:"cashDrawerPin"` // 2 (default) or 5
 }
 
 type Printer interface {
 	Init()
+	// Columns returns the number of characters per line in the current font
+	Columns() int
 	SetAlign(align string)
 	SetFont(font string)
 	SetBold(bold bool)
 	SetDoubleStrike(enabled bool)
 	SetSize(width, height uint8)
+	// SetUnderline sets the underline thickness: 0 (off), 1 or 2 dots
+	SetUnderline(mode uint8)
+	// SetReverse prints white text on black
+	SetReverse(enabled bool)
+	// SetUpsideDown turns text 180°, taking effect at the start of a line
+	SetUpsideDown(enabled bool)
+	// SetRotate90 turns each character 90° clockwise
+	SetRotate90(enabled bool)
+	// SetLineSpacing sets the line feed in dots; 0 restores the default
+	SetLineSpacing(dots int)
+	// SetCharSpacing adds dots to the right of every character
+	SetCharSpacing(dots int)
+	// SetPrintArea sets the left margin and printable width in dots; zero for
+	// both restores the printer's configured layout
+	SetPrintArea(left, width int)
 	Write(data string)
+	WriteRaster(data string)
 	Feed(n uint8)
 	Cut()
-	PrintQRCode(data string)
-	PrintImage(filePath string)
+	PrintQRCode(data string, opts QRCodeOptions)
+	PrintBarcode(symbology, data string, opts BarcodeOptions)
+	PrintPDF417(data string, opts PDF417Options)
+	PrintDataMatrix(data string, opts DataMatrixOptions)
+	PrintImage(filePath string, opts ImageOptions)
+	// OpenCashDrawer kicks the drawer on connector pin 2 or 5; zero values use defaults
+	OpenCashDrawer(pin, onMs, offMs int)
+	Beep(times, durationMs int)
 }
 
 func getInvoiceNoStr(v interface{}) string {
@@ -142,325 +263,194 @@ func (d OrderData) GetInvoiceNo() string {
 	return getInvoiceNoStr(d.InvoiceNo)
 }
 
-func RenderKOT(p Printer, data OrderData, size string) {
-	width := 32 // Default 58mm
-	if size == "80mm" {
-		width = 48
-	}
-
-	p.Init()
-	p.SetDoubleStrike(true)
-
-	// HEADER
-	p.SetAlign("center")
-	p.SetBold(true)
-	p.SetSize(1, 1) // Double height/width
-	p.Write("KOT\n")
-	p.SetSize(0, 0) // Normal
-	p.SetBold(false)
-
-	if data.StoreInfo.BrandName != "" {
-		p.SetBold(true)
-		p.Write(data.StoreInfo.BrandName + "\n")
-		p.SetBold(false)
+// PaidInCash reports whether any part of the order was paid in cash.
+func (d OrderData) PaidInCash() bool {
+	if strings.EqualFold(strings.TrimSpace(d.PaymentMode), "cash") {
+		return true
 	}
-
-	p.Write(strings.Repeat("-", width) + "\n")
-
-	// KOT INFO
-	p.SetAlign("left")
-	if data.DisplayOptions.ShowOrderNumber {
-		p.Write(fmt.Sprintf("Order #: %s\n", getInvoiceNoStr(data.InvoiceNo)))
-	}
-	if data.DisplayOptions.ShowTableInfo && data.TableNo != "" {
-		p.SetBold(true)
-		p.Write(fmt.Sprintf("Table: %s", data.TableNo))
-		p.SetBold(false)
-		if data.OrderType != "" {
-			p.Write(fmt.Sprintf(" (%s)", data.OrderType))
+	for _, pay := range d.Payments {
+		if strings.EqualFold(strings.TrimSpace(pay.Mode), "cash") {
+			return true
 		}
-		p.Write("\n")
-	} else if data.OrderType != "" {
-		p.Write(fmt.Sprintf("Type: %s\n", data.OrderType))
 	}
+	return false
+}
 
-	if data.DisplayOptions.ShowCustomerName && data.CustomerName != "" {
-		p.Write(fmt.Sprintf("Customer: %s\n", data.CustomerName))
-	}
-	p.Write(fmt.Sprintf("Date: %s\n", data.Date))
-
-	p.Write(strings.Repeat("-", width) + "\n")
-
-	// ITEMS HEADER
-	// Qty Item
-	p.SetBold(true)
-	p.Write(fmt.Sprintf("%-4s %s\n", "Qty", "Item"))
-	p.SetBold(false)
-	p.Write(strings.Repeat("-", width) + "\n")
+// ShouldOpenCashDrawer reports whether printing the bill kicks the cash drawer.
+func (d OrderData) ShouldOpenCashDrawer() bool {
+	return d.OpenCashDrawer && d.PaidInCash()
+}
 
-	// ITEMS
-	for _, item := range data.Items {
-		p.SetBold(true)
-		p.Write(fmt.Sprintf("%-4d %s\n", item.Quantity, item.Name))
-		p.SetBold(false)
+// ItemGroup is a course and category of a KOT with the items under it.
+type ItemGroup struct {
+	Course   string
+	Category string
+	Items    []int // Indexes into OrderData.Items
+}
 
-		if item.Variant != "" {
-			p.Write(fmt.Sprintf("     Var: %s\n", item.Variant))
+// ItemGroups groups the items by course, then category, each in the order
+// it first appears except that starters go first and desserts last. Items
+// without a course follow the courses, under an "Other" course when there
+// are courses, and items with neither come last, under an "Other" category
+// when there are other groups.
+func (d OrderData) ItemGroups() []ItemGroup {
+	var groups []ItemGroup
+	var other []int
+	find := func(course, category string) int {
+		last := -1
+		for i, g := range groups {
+			if strings.EqualFold(g.Course, course) {
+				if strings.EqualFold(g.Category, category) {
+					return i
+				}
+				last = i
+			}
 		}
-		if item.ItemNote != "" {
-			p.Write(fmt.Sprintf("     Note: %s\n", item.ItemNote))
+		// A new category goes after the others of its course
+		groups = append(groups, ItemGroup{Course: course, Category: category})
+		if last < 0 {
+			return len(groups) - 1
 		}
-
-		for _, child := range item.Children {
-			p.Write(fmt.Sprintf("     + %-2d %s\n", child.Quantity, child.Name))
+		at := last + 1
+		copy(groups[at+1:], groups[at:])
+		groups[at] = ItemGroup{Course: course, Category: category}
+		return at
+	}
+	for i, item := range d.Items {
+		course, category := strings.TrimSpace(item.Course), strings.TrimSpace(item.Category)
+		if course == "" && category == "" {
+			other = append(other, i)
+			continue
+		}
+		g := find(course, category)
+		groups[g].Items = append(groups[g].Items, i)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return courseRank(groups[i].Course) < courseRank(groups[j].Course)
+	})
+	if len(other) > 0 {
+		category := ""
+		if len(groups) > 0 {
+			category = "Other"
+		}
+		groups = append(groups, ItemGroup{Category: category, Items: other})
+	}
+	// Without a header of their own, course-less items would read as part
+	// of the course printed above them
+	if len(groups) > 0 && groups[0].Course != "" {
+		for i := range groups {
+			if groups[i].Course == "" {
+				groups[i].Course = "Other"
+			}
 		}
 	}
-
-	p.Write(strings.Repeat("-", width) + "\n")
-	p.Feed(3)
-	p.Cut()
-}
-
-func truncateString(str string, num int) string {
-	if len(str) > num {
-		return str[0:num]
-	}
-	return str
+	return groups
 }
 
-func RenderBill(p Printer, data OrderData, size string) {
-	width := 32 // Default 58mm
-	if size == "80mm" {
-		width = 48
-	}
-
-	p.Init()
-	p.SetDoubleStrike(true)
-	p.SetAlign("center")
-
-	// 1. HEADER
-	// Logo
-	if data.StoreInfo.ShowLogo && data.StoreInfo.LogoURL != "" {
-		p.PrintImage(data.StoreInfo.LogoURL)
-	}
-
-	// Brand / Store Name
-	if data.StoreInfo.BrandName != "" {
-		p.SetBold(true)
-		p.SetSize(1, 1) // Double Height/Width
-		p.Write(data.StoreInfo.BrandName + "\n")
-		p.SetSize(0, 0)
-		p.SetBold(false)
-	}
-	if data.StoreInfo.DisplayName != "" {
-		p.Write(data.StoreInfo.DisplayName + "\n")
-	} else if data.StoreInfo.Name != "" {
-		p.Write(data.StoreInfo.Name + "\n")
-	}
-
-	// Address & City
-	if data.StoreInfo.Address != "" {
-		p.Write(data.StoreInfo.Address + "\n")
-	}
-	if data.StoreInfo.City != "" {
-		p.Write(data.StoreInfo.City + "\n")
-	}
-	// Contact Info
-	if data.StoreInfo.ContactNumber != "" {
-		p.Write("Phone: " + data.StoreInfo.ContactNumber + "\n")
+// courseRank orders the courses kitchens fire first and last; others keep
+// their order between them, and items without a course come after all.
+func courseRank(course string) int {
+	if course == "" {
+		return 3
 	}
-	if data.StoreInfo.Email != "" {
-		p.Write("Email: " + data.StoreInfo.Email + "\n")
+	switch strings.TrimSuffix(strings.ToLower(course), "s") {
+	case "starter", "appetizer", "appetiser", "soup":
+		return 0
+	case "dessert":
+		return 2
 	}
+	return 1
+}
 
-	// Compliance IDs
-	if data.StoreInfo.GST != "" {
-		p.Write("GSTIN: " + data.StoreInfo.GST + "\n")
-	}
-	if data.StoreInfo.FSSAIState != "" {
-		p.Write("FSSAI (State): " + data.StoreInfo.FSSAIState + "\n")
-	}
-	if data.StoreInfo.FSSAICentral != "" {
-		p.Write("FSSAI (Central): " + data.StoreInfo.FSSAICentral + "\n")
+// PrepTimeMinutes returns the longest preparation time of the items, 0
+// when none has one.
+func (d OrderData) PrepTimeMinutes() int {
+	longest := 0
+	for _, item := range d.Items {
+		longest = max(longest, item.PrepTimeMinutes)
 	}
-	if data.StoreInfo.CIN != "" {
-		p.Write("CIN: " + data.StoreInfo.CIN + "\n")
-	}
-	if data.StoreInfo.LLPIN != "" {
-		p.Write("LLPIN: " + data.StoreInfo.LLPIN + "\n")
-	}
-
-	p.Write("\n")
-	p.SetBold(true)
-	p.Write("TAX INVOICE\n")
-	p.SetBold(false)
-	p.Write(strings.Repeat("-", width) + "\n")
-
-	// 2. TRANSACTION DETAILS
-	p.SetAlign("left")
-	p.Write(fmt.Sprintf("Invoice No: %s\n", getInvoiceNoStr(data.InvoiceNo)))
-	p.Write(fmt.Sprintf("Date: %s\n", data.Date))
+	return longest
+}
 
-	if data.OrderSource != "" {
-		p.Write(fmt.Sprintf("Source: %s\n", data.OrderSource))
-	}
-	if data.OrderType != "" {
-		p.Write(fmt.Sprintf("Type: %s\n", data.OrderType))
-	}
-	if data.TableNo != "" {
-		p.Write(fmt.Sprintf("Table: %s\n", data.TableNo))
-	}
+// orderTimeLayouts are the forms of Date the POS sends, day first.
+var orderTimeLayouts = []string{
+	"02/01/2006, 03:04 PM",
+	"02/01/2006, 03:04:05 PM",
+	"2/1/2006, 3:04 PM",
+	"2/1/2006, 3:04:05 PM",
+	"02/01/2006 15:04",
+	"02/01/2006 15:04:05",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
 
-	// Customer Info
-	if data.DisplayOptions.ShowCustomerInfo {
-		if data.CustomerName != "" {
-			p.Write(fmt.Sprintf("Customer: %s\n", data.CustomerName))
+// OrderTime reads Date, falling back to now when it has no known form.
+func (d OrderData) OrderTime() time.Time {
+	date := strings.ToUpper(strings.TrimSpace(d.Date))
+	for _, layout := range orderTimeLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t
 		}
-		if data.CustomerContact != "" {
-			p.Write(fmt.Sprintf("Phone: %s\n", data.CustomerContact))
-		}
-	}
-
-	p.Write(strings.Repeat("-", width) + "\n")
-
-	// 3. ITEM DETAILS
-	// Header
-	var itemLen int
-	var fmtStr string
-
-	if width == 48 {
-		// 80mm
-		// Format: Item(22) Qty(4) Rate(9) Amount(10) | Spaces=3 => 48
-		itemLen = 22
-		fmtStr = "%-22s %4s %9s %10s\n"
-	} else {
-		// 58mm (32 chars)
-		// Format: Item(10) Qt(4) Rt(8) Amt(8) | Spaces=2 => 32
-		itemLen = 10
-		fmtStr = "%-10s %4s %8s %8s\n"
 	}
+	return time.Now()
+}
 
-	p.SetBold(true)
-	p.Write(fmt.Sprintf(fmtStr, "Item", "Qty", "Rate", "Total"))
-	p.SetBold(false)
-	p.Write(strings.Repeat("-", width) + "\n")
-
-	// Define item formatter
-	printItemLine := func(name string, qty int, price, total float64) {
-		nameTrunc := truncateString(name, itemLen)
-		qtyStr := fmt.Sprintf("%d", qty)
-		priceStr := fmt.Sprintf("%.2f", price)
-		totalStr := fmt.Sprintf("%.2f", total)
-		p.Write(fmt.Sprintf(fmtStr, nameTrunc, qtyStr, priceStr, totalStr))
+// ReadyBy returns when the kitchen should have the order ready: the order
+// time plus the longest preparation time. ok is false when no item has one.
+func (d OrderData) ReadyBy() (t time.Time, ok bool) {
+	prep := d.PrepTimeMinutes()
+	if prep <= 0 {
+		return time.Time{}, false
 	}
+	return d.OrderTime().Add(time.Duration(prep) * time.Minute), true
+}
 
-	// Loop Items
-	for _, item := range data.Items {
-		lineTotal := float64(item.Quantity) * item.Price
-		printItemLine(item.Name, item.Quantity, item.Price, lineTotal)
-
-		// Variants
-		if item.Variant != "" {
-			p.Write(fmt.Sprintf("  Var: %s\n", item.Variant))
+// printSymbols prints the order's 2D symbols placed at position, centred.
+func printSymbols(p Printer, symbols []Symbol, position string) {
+	for _, sym := range symbols {
+		pos := sym.Position
+		if pos == "" {
+			pos = "footer"
 		}
-		// Note
-		if item.ItemNote != "" {
-			p.Write(fmt.Sprintf("  Note: %s\n", item.ItemNote))
+		if pos != position || sym.Data == "" {
+			continue
 		}
-		// Children
-		for _, child := range item.Children {
-			childTotal := float64(child.Quantity) * child.Price
-			if child.Price > 0 {
-				displayName := "  + " + child.Name
-				printItemLine(displayName, child.Quantity, child.Price, childTotal)
-			} else {
-				p.Write(fmt.Sprintf("  + %s\n", child.Name))
-			}
-		}
-	}
 
-	p.Write(strings.Repeat("-", width) + "\n")
-
-	// 4. TOTALS
-	p.SetAlign("right")
-	p.Write(fmt.Sprintf("Subtotal: %.2f\n", data.SubTotal))
-
-	if data.DisplayOptions.ShowTaxBreakdown && len(data.TaxBreakdown) > 0 {
-		for _, t := range data.TaxBreakdown {
-			p.Write(fmt.Sprintf("%s @ %.2f%% : %.2f\n", t.Name, t.Rate, t.Amount))
+		p.SetAlign("center")
+		if sym.Label != "" {
+			p.Write(sym.Label + "\n")
 		}
-	}
-
-	if data.DisplayOptions.ShowDiscountBreakdown && len(data.DiscountBreakdown) > 0 {
-		for _, d := range data.DiscountBreakdown {
-			p.Write(fmt.Sprintf("%s: -%.2f\n", d.Name, d.Amount))
+		switch strings.ToLower(sym.Type) {
+		case SymbolPDF417:
+			ecc, _ := strconv.Atoi(sym.ECC)
+			p.PrintPDF417(sym.Data, PDF417Options{ModuleWidth: sym.Size, Columns: sym.Columns, ECC: ecc})
+		case SymbolDataMatrix:
+			p.PrintDataMatrix(sym.Data, DataMatrixOptions{ModuleSize: sym.Size})
+		case SymbolQR:
+			p.PrintQRCode(sym.Data, QRCodeOptions{Size: sym.Size, ECC: sym.ECC})
+		default:
+			fmt.Printf("Warning: unknown symbol type %q\n", sym.Type)
 		}
 	}
+}
 
-	for _, c := range data.Charges {
-		p.Write(fmt.Sprintf("%s: %.2f\n", c.Name, c.Amount))
-	}
-
-	// Tax Sum
-	if data.Tax > 0 {
-		p.Write(fmt.Sprintf("Total Tax: %.2f\n", data.Tax))
-	}
-
-	p.Write(strings.Repeat("-", width) + "\n")
-
-	// Grand Total
-	p.SetBold(true)
-	p.SetSize(0, 1) // Double Height
-	p.Write(fmt.Sprintf("GRAND TOTAL: %.2f\n", data.Total))
-	p.SetSize(0, 0)
-	p.SetBold(false)
-
-	p.Write(strings.Repeat("-", width) + "\n")
-
-	// 5. TAX BREAKDOWN - Moved to TOTALS section
-	// if data.DisplayOptions.ShowTaxBreakdown && len(data.TaxBreakdown) > 0 { ... }
-
-	// 6. FOOTER INFO
-	p.SetAlign("center")
-
-	// Payment Info
-	if data.DisplayOptions.ShowPaymentDetails {
-		if len(data.Payments) > 0 {
-			p.Write("Payment Mode:\n")
-			for _, pay := range data.Payments {
-				p.Write(fmt.Sprintf("%s: %.2f\n", pay.Mode, pay.Amount))
-			}
-		} else if data.DisplayOptions.ShowPaymentDetails && data.PaymentMode != "" {
-			p.Write(fmt.Sprintf("Payment Mode: %s\n", data.PaymentMode))
+func hasSymbols(symbols []Symbol, position string) bool {
+	for _, sym := range symbols {
+		if sym.Position == position || (position == "footer" && sym.Position == "") {
+			return true
 		}
 	}
+	return false
+}
 
-	if data.CashierName != "" {
-		p.Write(fmt.Sprintf("Cashier: %s\n", data.CashierName))
-	}
-
-	// Policy / Closing
-	p.Write("\n")
-	if data.StoreInfo.Policy != "" {
-		p.Write(data.StoreInfo.Policy + "\n")
-	} else if data.StoreInfo.FooterText != "" {
-		p.Write(data.StoreInfo.FooterText + "\n")
-	} else {
-		p.Write("Thank you! Visit Again.\n")
-	}
-
-	if data.StoreInfo.Website != "" {
-		p.Write("Visit: " + data.StoreInfo.Website + "\n")
-	}
-
-	// QR Code
-	if data.DisplayOptions.ShowQRCode && data.DisplayOptions.QrCodeData != "" {
-		p.Write("\n")
-		p.PrintQRCode(data.DisplayOptions.QrCodeData)
-	}
+// RenderKOT prints a kitchen order ticket with the built-in KOT template.
+func RenderKOT(p Printer, data OrderData) {
+	Render(p, builtinTemplates[TemplateKOT], data)
+}
 
-	p.Feed(4)
-	p.Cut()
+// RenderBill prints a tax invoice with the built-in bill template.
+func RenderBill(p Printer, data OrderData) {
+	Render(p, builtinTemplates[TemplateBill], data)
 }
 
 func GetSampleOrderData() OrderData {
@@ -475,30 +465,39 @@ func GetSampleOrderData() OrderData {
 		CashierName:     "Rahul",
 		Items: []OrderItem{
 			{
-				Name:           "Paneer Tikka Masala",
-				Quantity:       1,
-				Price:          280.00,
-				Sku:            "SKU_101",
-				ItemNote:       "Spicy",
-				Variant:        "Full",
-				TaxAmount:      14.00,
-				DiscountAmount: 0.00,
+				Name:            "Paneer Tikka Masala",
+				Quantity:        1,
+				Price:           280.00,
+				Sku:             "SKU_101",
+				ItemNote:        "Spicy",
+				Variant:         "Full",
+				TaxAmount:       14.00,
+				DiscountAmount:  0.00,
+				Category:        "Curries",
+				Course:          "Mains",
+				PrepTimeMinutes: 20,
 				Children: []OrderItem{
 					{Name: "Extra Gravy", Quantity: 1, Price: 20.00},
 				},
 			},
 			{
-				Name:     "Butter Naan",
-				Quantity: 2,
-				Price:    40.00,
-				Variant:  "",
-				Children: nil,
+				Name:            "Butter Naan",
+				Quantity:        2,
+				Price:           40.00,
+				Variant:         "",
+				Children:        nil,
+				Category:        "Breads",
+				Course:          "Mains",
+				PrepTimeMinutes: 10,
 			},
 			{
-				Name:     "Veg Thali",
-				Quantity: 1,
-				Price:    350.00,
-				Variant:  "Deluxe",
+				Name:            "Veg Thali",
+				Quantity:        1,
+				Price:           350.00,
+				Variant:         "Deluxe",
+				Category:        "Thalis",
+				Course:          "Mains",
+				PrepTimeMinutes: 25,
 				Children: []OrderItem{
 					{Name: "Roti", Quantity: 2, Price: 0},
 					{Name: "Rice", Quantity: 1, Price: 0},
@@ -0,0 +1,210 @@
+package receipt
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// recorder is a Printer that writes down every line with the style it is
+// printed in, rather than the commands that set the style, so layouts that
+// reach the same output by different command orders compare equal.
+type recorder struct {
+	out   strings.Builder
+	style map[string]string
+}
+
+func newRecorder() *recorder {
+	r := &recorder{}
+	r.Init()
+	return r
+}
+
+func (r *recorder) Init() {
+	r.style = map[string]string{"align": "left", "font": "A", "size": "1x1"}
+}
+
+func (r *recorder) set(key string, value interface{}) {
+	r.style[key] = fmt.Sprint(value)
+}
+
+func (r *recorder) event(format string, args ...interface{}) {
+	fmt.Fprintf(&r.out, format+"\n", args...)
+}
+
+func (r *recorder) Columns() int {
+	if r.style["font"] == "B" {
+		return 64
+	}
+	return 48
+}
+
+func (r *recorder) SetAlign(align string)        { r.set("align", align) }
+func (r *recorder) SetFont(font string)          { r.set("font", font) }
+func (r *recorder) SetBold(bold bool)            { r.set("bold", bold) }
+func (r *recorder) SetDoubleStrike(enabled bool) { r.set("doubleStrike", enabled) }
+func (r *recorder) SetSize(width, height uint8) {
+	r.set("size", fmt.Sprintf("%dx%d", width+1, height+1))
+}
+func (r *recorder) SetUnderline(mode uint8)      { r.set("underline", mode) }
+func (r *recorder) SetReverse(enabled bool)      { r.set("reverse", enabled) }
+func (r *recorder) SetUpsideDown(enabled bool)   { r.set("upsideDown", enabled) }
+func (r *recorder) SetRotate90(enabled bool)     { r.set("rotate90", enabled) }
+func (r *recorder) SetLineSpacing(dots int)      { r.set("lineSpacing", dots) }
+func (r *recorder) SetCharSpacing(dots int)      { r.set("charSpacing", dots) }
+func (r *recorder) SetPrintArea(left, width int) { r.set("printArea", fmt.Sprint(left, width)) }
+
+// Write records the text with the style in effect, leaving out settings
+// that are off.
+func (r *recorder) Write(data string) {
+	var style []string
+	for _, key := range []string{"align", "font", "size", "bold", "doubleStrike", "underline", "reverse", "upsideDown", "rotate90", "lineSpacing", "charSpacing", "printArea"} {
+		switch v := r.style[key]; v {
+		case "", "false", "0", "0 0":
+		default:
+			style = append(style, key+"="+v)
+		}
+	}
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if line != "" {
+			r.event("[%s] %q", strings.Join(style, " "), line)
+		}
+	}
+}
+
+func (r *recorder) WriteRaster(data string) { r.event("raster %q", data) }
+func (r *recorder) Feed(n uint8)            { r.event("feed %d", n) }
+func (r *recorder) Cut()                    { r.event("cut") }
+func (r *recorder) PrintQRCode(data string, opts QRCodeOptions) {
+	r.event("qrcode %q %+v", data, opts)
+}
+func (r *recorder) PrintBarcode(symbology, data string, opts BarcodeOptions) {
+	r.event("barcode %s %q %+v", symbology, data, opts)
+}
+func (r *recorder) PrintPDF417(data string, opts PDF417Options) {
+	r.event("pdf417 %q %+v", data, opts)
+}
+func (r *recorder) PrintDataMatrix(data string, opts DataMatrixOptions) {
+	r.event("datamatrix %q %+v", data, opts)
+}
+func (r *recorder) PrintImage(filePath string, opts ImageOptions) {
+	r.event("image %q %+v", filePath, opts)
+}
+func (r *recorder) OpenCashDrawer(pin, onMs, offMs int) { r.event("drawer %d %d %d", pin, onMs, offMs) }
+func (r *recorder) Beep(times, durationMs int)          { r.event("beep %d %d", times, durationMs) }
+
+func loadOrder(t *testing.T, name string) OrderData {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data OrderData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestBuiltinTemplates checks the built-in bill and KOT templates against
+// the output of the hard-coded renderers they replaced, recorded in
+// testdata. Orders without courses, categories or preparation times print
+// as they did before grouping and ready-by times were added.
+func TestBuiltinTemplates(t *testing.T) {
+	for _, tc := range []struct {
+		golden string
+		order  string
+		render func(Printer, OrderData)
+	}{
+		{"bill.golden", "order.json", RenderBill},
+		{"kot.golden", "order.json", RenderKOT},
+		{"bill-cash.golden", "order-cash.json", RenderBill},
+		{"kot-cash.golden", "order-cash.json", RenderKOT},
+		{"kot-groups.golden", "order-groups.json", RenderKOT},
+	} {
+		t.Run(tc.golden, func(t *testing.T) {
+			r := newRecorder()
+			tc.render(r, loadOrder(t, tc.order))
+			got := r.out.String()
+
+			path := filepath.Join("testdata", tc.golden)
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s:\n%s", path, lineDiff(string(want), got))
+			}
+		})
+	}
+}
+
+func TestItemGroups(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		items []OrderItem
+		want  []ItemGroup
+	}{
+		{"no grouping", []OrderItem{{Name: "Tea"}, {Name: "Coffee"}},
+			[]ItemGroup{{Items: []int{0, 1}}}},
+		{"categories only", []OrderItem{
+			{Name: "Naan", Category: "Breads"},
+			{Name: "Tea"},
+			{Name: "Dal", Category: "Curries"},
+			{Name: "Roti", Category: "breads"},
+		}, []ItemGroup{
+			{Category: "Breads", Items: []int{0, 3}},
+			{Category: "Curries", Items: []int{2}},
+			{Category: "Other", Items: []int{1}},
+		}},
+		{"courses", []OrderItem{
+			{Name: "Kulfi", Course: "Dessert"},
+			{Name: "Papad", Category: "Tandoor"},
+			{Name: "Dal", Course: "Mains", Category: "Curries"},
+			{Name: "Soup", Course: "Starters"},
+			{Name: "Tea"},
+		}, []ItemGroup{
+			{Course: "Starters", Items: []int{3}},
+			{Course: "Mains", Category: "Curries", Items: []int{2}},
+			{Course: "Dessert", Items: []int{0}},
+			{Course: "Other", Category: "Tandoor", Items: []int{1}},
+			{Course: "Other", Category: "Other", Items: []int{4}},
+		}},
+	} {
+		got := OrderData{Items: tc.items}.ItemGroups()
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: ItemGroups() = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// lineDiff lists the lines that differ between two outputs.
+func lineDiff(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var sb strings.Builder
+	for i := 0; i < max(len(w), len(g)); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			fmt.Fprintf(&sb, "line %d:\n  want %s\n  got  %s\n", i+1, wl, gl)
+		}
+	}
+	return sb.String()
+}
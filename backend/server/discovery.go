@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"ts-escpos/backend/config"
+	"ts-escpos/backend/discovery"
+	"ts-escpos/backend/printer"
+)
+
+// AddPrinterRequest saves a discovered printer under a name.
+type AddPrinterRequest struct {
+	MachineID string `json:"machineId"`
+	Name      string `json:"name"`
+	Address   string `json:"address"`         // e.g. "tcp://10.0.0.50:9100"
+	Paper     string `json:"paper,omitempty"` // e.g. "80mm"
+}
+
+// ErrPrinterExists is returned when adding a printer under a name in use.
+var ErrPrinterExists = errors.New("a printer with this name already exists")
+
+// discoverMux lets one discovery run at a time; a scan opens hundreds of
+// connections.
+var discoverMux sync.Mutex
+
+func (s *Server) registerDiscoveryRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/discovery", s.handleDiscovery)
+	mux.HandleFunc("POST /api/discovery/printers", s.handleAddDiscoveredPrinter)
+}
+
+// handleDiscovery scans the local network for printers. ?subnet= (a CIDR,
+// repeatable) scans other networks than the machine's own.
+func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	storedMachineID, err := config.GetMachineID()
+	if err == nil && r.URL.Query().Get("machineId") != storedMachineID {
+		fmt.Printf("Discovery validation failed: Invalid Machine ID\n")
+		http.Error(w, "Invalid Machine ID", http.StatusUnauthorized)
+		return
+	}
+
+	var subnets []string
+	for _, v := range r.URL.Query()["subnet"] {
+		subnets = append(subnets, strings.Split(v, ",")...)
+	}
+	devices, err := s.Discover(r.Context(), discovery.Options{Subnets: subnets})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"devices": devices,
+	})
+}
+
+// Discover finds the printers on the network and marks those already
+// configured.
+func (s *Server) Discover(ctx context.Context, opts discovery.Options) ([]discovery.Device, error) {
+	discoverMux.Lock()
+	defer discoverMux.Unlock()
+
+	fmt.Printf("[Discovery] Scanning for network printers...\n")
+	devices, err := discovery.Discover(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, d := range devices {
+		for name, settings := range s.PrinterConfigs() {
+			if addressHost(settings.Address) == d.Host {
+				devices[i].ConfiguredAs = name
+			}
+		}
+	}
+	fmt.Printf("[Discovery] Found %d devices\n", len(devices))
+	return devices, nil
+}
+
+// addressHost returns the host of a "tcp://host[:port]" printer address,
+// empty for other addresses.
+func addressHost(addr string) string {
+	host, ok := strings.CutPrefix(addr, "tcp://")
+	if !ok {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+func (s *Server) handleAddDiscoveredPrinter(w http.ResponseWriter, r *http.Request) {
+	var req AddPrinterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	storedMachineID, err := config.GetMachineID()
+	if err == nil && req.MachineID != storedMachineID {
+		fmt.Printf("Add printer validation failed: Invalid Machine ID\n")
+		http.Error(w, "Invalid Machine ID", http.StatusUnauthorized)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	err = s.AddPrinter(req.Name, printer.Settings{Address: req.Address, Paper: req.Paper})
+	switch {
+	case errors.Is(err, ErrPrinterExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.printersMux.RLock()
+	p := s.printers[req.Name]
+	s.printersMux.RUnlock()
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+// AddPrinter saves a printer reached by address to config under a new name
+// and starts using it.
+func (s *Server) AddPrinter(name string, settings printer.Settings) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("name is required")
+	}
+	if !strings.HasPrefix(settings.Address, "tcp://") {
+		return fmt.Errorf("unsupported printer address %q", settings.Address)
+	}
+	s.printersMux.RLock()
+	_, listed := s.printers[name]
+	s.printersMux.RUnlock()
+
+	// Check and add under one lock so two adds of a name cannot both pass
+	s.configMux.Lock()
+	old := s.config.Printers
+	if _, ok := old[name]; ok || listed {
+		s.configMux.Unlock()
+		return fmt.Errorf("%w: %s", ErrPrinterExists, name)
+	}
+	// Replace the map rather than write to it while jobs read it
+	printers := maps.Clone(old)
+	if printers == nil {
+		printers = make(map[string]printer.Settings)
+	}
+	printers[name] = settings
+	s.config.Printers = printers
+	err := config.SaveConfig(s.config)
+	if err != nil {
+		s.config.Printers = old
+	}
+	s.configMux.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	fmt.Printf("[Discovery] Added printer %s at %s\n", name, settings.Address)
+
+	s.refreshPrinters()
+	return nil
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ts-escpos/backend/printer"
+	"ts-escpos/backend/receipt"
+)
+
+// isDocumentFormat reports whether a request asks for a file rather than a print.
+func isDocumentFormat(format string) bool {
+	format = strings.ToLower(format)
+	return format != "" && format != printer.FormatESCPOS
+}
+
+// writeDocument renders a request as text, HTML or PDF and sends it back.
+// The printer's paper and layout are used when it is known, so an e-bill
+// wraps like the printed slip.
+func (s *Server) writeDocument(w http.ResponseWriter, req PrintRequest, tmpl *receipt.Template) {
+	selectedPrinter, exists := s.lookupPrinter(req.target())
+
+	settings := printer.Settings{Paper: req.PrinterSize}
+	if exists {
+		settings = s.printerSettings(selectedPrinter, req.PrinterSize)
+	}
+
+	doc, err := printer.NewDocument(req.OutputFormat, settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	receipt.Render(doc, tmpl, req.OrderData)
+
+	data := doc.GetBytes()
+	fmt.Printf("Rendered %s %s for invoice %s (%d bytes)\n", req.ReceiptType, req.OutputFormat, req.OrderData.GetInvoiceNo(), len(data))
+	w.Header().Set("Content-Type", doc.ContentType())
+	w.Write(data)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -18,28 +19,37 @@ import (
 
 	"ts-escpos/backend/config"
 	"ts-escpos/backend/jobs"
+	"ts-escpos/backend/kitchen"
 	"ts-escpos/backend/printer"
 	"ts-escpos/backend/receipt"
+	"ts-escpos/backend/templates"
 )
 
 type Server struct {
-	store          *jobs.Store
-	config         *config.Config
-	ctx            context.Context
-	clients        map[*websocket.Conn]bool
-	clientsMux     sync.Mutex
-	upgrader       websocket.Upgrader
-	printers       map[string]printer.PrinterInfo
-	defaultPrinter string
-	printersMux    sync.RWMutex
+	store         *jobs.Store
+	templates     *templates.Store
+	config        *config.Config
+	ctx           context.Context
+	clients       map[*websocket.Conn]bool
+	clientsMux    sync.Mutex
+	upgrader      websocket.Upgrader
+	printers      map[string]printer.PrinterInfo
+	deviceStatus  map[string]printer.DeviceStatus // Live status of printers with a status channel
+	printersMux   sync.RWMutex
+	transports    map[string]printer.Transport // Open connections to network and device printers, by name
+	transportsMux sync.Mutex
+	configMux     sync.RWMutex // Guards config.Printers, which AddPrinter replaces
 }
 
 func NewServer(store *jobs.Store, cfg *config.Config) *Server {
 	return &Server{
-		store:    store,
-		config:   cfg,
-		clients:  make(map[*websocket.Conn]bool),
-		printers: make(map[string]printer.PrinterInfo),
+		store:        store,
+		templates:    templates.NewStore(filepath.Join(config.Dir(), "templates")),
+		config:       cfg,
+		clients:      make(map[*websocket.Conn]bool),
+		printers:     make(map[string]printer.PrinterInfo),
+		deviceStatus: make(map[string]printer.DeviceStatus),
+		transports:   make(map[string]printer.Transport),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				// Allow all CORS for now to support development from various origins
@@ -54,8 +64,18 @@ func (s *Server) SetContext(ctx context.Context) {
 	s.ctx = ctx
 }
 
+// PrinterConfigs returns the configured printers by name. The map is
+// replaced, never changed, when a printer is added, so it may be read
+// without holding a lock.
+func (s *Server) PrinterConfigs() map[string]printer.Settings {
+	s.configMux.RLock()
+	defer s.configMux.RUnlock()
+	return s.config.Printers
+}
+
 func (s *Server) refreshPrinters() {
-	list, err := printer.GetPrinters()
+	configs := s.PrinterConfigs()
+	list, err := printer.ListPrinters(configs)
 	if err != nil {
 		fmt.Printf("Failed to refresh printers: %v\n", err)
 		return
@@ -65,17 +85,19 @@ func (s *Server) refreshPrinters() {
 	defer s.printersMux.Unlock()
 
 	s.printers = make(map[string]printer.PrinterInfo)
-	if len(list) > 0 {
-		s.defaultPrinter = list[0].Name // Default to first printer
-	}
 	for _, p := range list {
+		p.Profile = printer.ProfileFor(p, configs[p.Name].Profile)
+		if st, ok := s.deviceStatus[p.Name]; ok {
+			p.SetDeviceStatus(st)
+		}
 		s.printers[p.Name] = p
 	}
-	fmt.Printf("Printers refreshed. Found %d printers. Default: %s\n", len(s.printers), s.defaultPrinter)
+	fmt.Printf("Printers refreshed. Found %d printers.\n", len(s.printers))
 }
 
 func (s *Server) Start() {
 	s.refreshPrinters()
+	s.startStatusMonitors()
 
 	mux := http.NewServeMux()
 
@@ -86,7 +108,11 @@ func (s *Server) Start() {
 
 	mux.HandleFunc("/api/print", s.handlePrint)
 	mux.HandleFunc("/api/printers", s.handleGetPrinters)
+	mux.HandleFunc("/api/drawer", s.handleDrawer)
+	mux.HandleFunc("/api/preview", s.handlePreview)
 	mux.HandleFunc("/api/validate", s.handleValidate)
+	s.registerTemplateRoutes(mux)
+	s.registerDiscoveryRoutes(mux)
 	mux.HandleFunc("/api/test-notification", s.handleTestNotification)
 	mux.HandleFunc("/ws", s.handleWebSocket)
 
@@ -114,7 +140,7 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 		fmt.Printf("Incoming request: %s %s\n", r.Method, r.URL.Path)
 
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
 		if r.Method == "OPTIONS" {
@@ -168,16 +194,35 @@ func (lrw *loggingResponseWriter) Flush() {
 type PrintRequest struct {
 	MachineID   string            `json:"machineId"`
 	PrinterName string            `json:"printerName"`
+	Role        string            `json:"role,omitempty"` // Configured role to print to, instead of PrinterName
 	OrderData   receipt.OrderData `json:"orderData"`
 	PrinterSize string            `json:"printerSize"`
 	ReceiptType string            `json:"receiptType"`
+	// TemplateID selects a template instead of the built-in one for ReceiptType
+	TemplateID string `json:"templateId,omitempty"`
+	// Template is an inline layout, as an object or as JSON/YAML source
+	Template *receipt.Template `json:"template,omitempty"`
+	// OutputFormat is "escpos" (print, the default), "text", "html" or "pdf".
+	// The other formats are returned in the response instead of printed.
+	OutputFormat string `json:"outputFormat,omitempty"`
+}
+
+// target returns the role or printer name a request prints to.
+func (r PrintRequest) target() string {
+	if r.Role != "" {
+		return r.Role
+	}
+	return r.PrinterName
 }
 
 type PrintResponse struct {
-	Success bool   `json:"success"`
-	JobID   string `json:"jobId"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success bool     `json:"success"`
+	JobID   string   `json:"jobId"`
+	JobIDs  []string `json:"jobIds,omitempty"` // Every job of a KOT split between kitchen stations
+	Message string   `json:"message,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	// Warnings lists what was asked for but skipped, e.g. a beep without a buzzer
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 func (s *Server) notifyError(title, message, icon string, sound bool) {
@@ -217,6 +262,9 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Send initial welcome message before status broadcasts can reach the client
+	ws.WriteJSON(map[string]string{"type": "connected", "message": "Connected to TS-ESCPOS Printer Service"})
+
 	// Register client
 	s.clientsMux.Lock()
 	s.clients[ws] = true
@@ -224,9 +272,6 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Println("New WebSocket client connected")
 
-	// Send initial welcome message
-	ws.WriteJSON(map[string]string{"type": "connected", "message": "Connected to TS-ESCPOS Printer Service"})
-
 	// Keep connection alive / Listen for messages
 	go func() {
 		defer func() {
@@ -267,105 +312,302 @@ func (s *Server) handlePrint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Resolve Printer Name from Cache
-	s.printersMux.RLock()
-	targetPrinterName := req.PrinterName
-	selectedPrinter, exists := s.printers[targetPrinterName]
-	s.printersMux.RUnlock()
+	tmpl, err := s.template(req)
+	if err != nil {
+		fmt.Printf("Print failed: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// If printer not found, refresh the cache and try again
-	if !exists {
-		fmt.Printf("Printer '%s' not found in cache. Refreshing printer list...\n", req.PrinterName)
-		s.refreshPrinters()
+	if isDocumentFormat(req.OutputFormat) {
+		s.writeDocument(w, req, tmpl)
+		return
+	}
 
-		s.printersMux.RLock()
-		targetPrinterName = req.PrinterName
-		selectedPrinter, exists = s.printers[targetPrinterName]
-		s.printersMux.RUnlock()
+	// A KOT is split between the kitchen stations when they are configured
+	tickets := []kitchen.Ticket{{Printer: req.target(), Order: req.OrderData}}
+	if req.ReceiptType == "kot" && len(s.config.Stations) > 0 && len(req.OrderData.Items) > 0 {
+		tickets = kitchen.Route(req.OrderData, s.config.Stations, req.target())
+		if s.config.ExpoPrinter != "" {
+			tickets = append(tickets, kitchen.Expo(req.OrderData, s.config.ExpoPrinter))
+		}
+	}
 
-		if !exists {
-			// Fallback to default
-			s.printersMux.RLock()
-			if s.defaultPrinter != "" {
-				fmt.Printf("Printer '%s' still not found. Falling back to default: '%s'\n", req.PrinterName, s.defaultPrinter)
-				targetPrinterName = s.defaultPrinter
-				selectedPrinter = s.printers[targetPrinterName]
-				exists = true
+	targets := make([]printer.PrinterInfo, len(tickets))
+	errs := make([]error, len(tickets))
+	var warnings []string
+	resolved := 0
+	for i, t := range tickets {
+		selectedPrinter, err := s.resolvePrinter(t.Printer)
+		// A station without a printer sends its items where the KOT was sent
+		if err != nil && t.Station != "" && t.Printer != req.target() {
+			if p, ferr := s.resolvePrinter(req.target()); ferr == nil {
+				msg := fmt.Sprintf("Station '%s': %v; printing its items on '%s'", t.Station, err, p.Name)
+				fmt.Printf("Warning: %s\n", msg)
+				warnings = append(warnings, msg)
+				selectedPrinter, err = p, nil
 			}
-			s.printersMux.RUnlock()
 		}
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		targets[i] = selectedPrinter
+		resolved++
 	}
-
-	if !exists {
-		msg := fmt.Sprintf("Printer '%s' not found and no default printer available.", req.PrinterName)
+	// Only a KOT with no printer for any ticket is rejected; otherwise the
+	// tickets that cannot print fail on their own
+	if resolved == 0 {
+		msg := errs[0].Error()
 		fmt.Printf("Print failed: %s\n", msg)
 		s.notifyError("Printer Not Found", msg, "", true)
 		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
 
-	// Initialize job for tracking
-	jobID := uuid.New().String()
-	job := jobs.PrintJob{
-		ID:          jobID,
-		InvoiceNo:   req.OrderData.GetInvoiceNo(),
-		PrinterName: targetPrinterName,
-		ReceiptType: req.ReceiptType,
-		Timestamp:   time.Now(),
-		Status:      jobs.StatusFailed,
+	// Initialize jobs for tracking, one per printer
+	printJobs := make([]jobs.PrintJob, len(tickets))
+	jobIDs := make([]string, len(tickets))
+	for i, t := range tickets {
+		jobIDs[i] = uuid.New().String()
+		printJobs[i] = jobs.PrintJob{
+			ID:          jobIDs[i],
+			InvoiceNo:   req.OrderData.GetInvoiceNo(),
+			PrinterName: targets[i].Name,
+			ReceiptType: req.ReceiptType,
+			Station:     t.Station,
+			Timestamp:   time.Now(),
+			Status:      jobs.StatusProcessing,
+		}
+		if err := errs[i]; err != nil {
+			msg := fmt.Sprintf("Station '%s': %v", t.Station, err)
+			fmt.Printf("Print failed: %s\n", msg)
+			s.notifyError("Printer Not Found", msg, "", true)
+			warnings = append(warnings, msg)
+			printJobs[i].PrinterName = t.Printer
+			printJobs[i].Status = jobs.StatusFailed
+			printJobs[i].Error = err.Error()
+		}
+		s.store.AddJob(printJobs[i])
 	}
 
 	// 4. Respond to client immediately (Async processing)
 	resp := PrintResponse{
-		Success: true,
-		JobID:   jobID,
-		Message: "Print job submitted successfully. Processing in background.",
+		Success:  true,
+		JobID:    jobIDs[0],
+		Message:  "Print job submitted successfully. Processing in background.",
+		Warnings: warnings,
+	}
+	if len(tickets) > 1 {
+		resp.JobIDs = jobIDs
+		resp.Message = fmt.Sprintf("%d print jobs submitted to kitchen stations. Processing in background.", len(tickets))
 	}
-	job.Status = jobs.StatusProcessing
-	s.store.AddJob(job)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(resp)
 
 	// 5. Background Printing Process
-	go func() {
-		fmt.Printf("[Job %s] Starting background print for %s\n", jobID, targetPrinterName)
-		defer func() {
-			s.store.AddJob(job) // Update final status
-		}()
-
-		// 3. Status Check (using cached status)
-		statusLower := strings.ToLower(selectedPrinter.Status)
-		blockingStatuses := []string{"offline", "not available"}
-		for _, bs := range blockingStatuses {
-			if strings.Contains(statusLower, bs) {
-				msg := fmt.Sprintf("Printer '%s' status is %s. Might fail.", targetPrinterName, selectedPrinter.Status)
-				fmt.Printf("Warning: %s\n", msg)
-			}
+	for i, t := range tickets {
+		if errs[i] != nil {
+			continue
 		}
+		go s.runPrintJob(printJobs[i], targets[i], req, tmpl, t.Order)
+	}
+}
 
-		adapter := printer.NewEscposAdapter()
-		if req.ReceiptType == "kot" {
-			receipt.RenderKOT(adapter, req.OrderData, req.PrinterSize)
-		} else {
-			receipt.RenderBill(adapter, req.OrderData, req.PrinterSize)
+// runPrintJob renders an order on a printer and records the result.
+func (s *Server) runPrintJob(job jobs.PrintJob, selectedPrinter printer.PrinterInfo, req PrintRequest, tmpl *receipt.Template, order receipt.OrderData) {
+	jobID, targetPrinterName := job.ID, selectedPrinter.Name
+	fmt.Printf("[Job %s] Starting background print for %s\n", jobID, targetPrinterName)
+	defer func() {
+		s.store.AddJob(job) // Update final status
+	}()
+
+	// 3. Status Check (using cached status)
+	statusLower := strings.ToLower(selectedPrinter.Status)
+	blockingStatuses := []string{"offline", "not available"}
+	for _, bs := range blockingStatuses {
+		if strings.Contains(statusLower, bs) {
+			msg := fmt.Sprintf("Printer '%s' status is %s. Might fail.", targetPrinterName, selectedPrinter.Status)
+			fmt.Printf("Warning: %s\n", msg)
 		}
+	}
 
-		bytesToPrint := adapter.GetBytes()
-		fmt.Printf("[Job %s] Generic ESC/POS bytes generated (%d bytes)\n", jobID, len(bytesToPrint))
+	settings := s.printerSettings(selectedPrinter, req.PrinterSize)
 
-		// Use s.ctx to allow logging to frontend
-		err := printer.PrintRaw(s.ctx, targetPrinterName, bytesToPrint)
-		if err != nil {
-			fmt.Printf("[Job %s] PRINT FAILED: %v\n", jobID, err)
-			job.Status = jobs.StatusFailed
-			job.Error = err.Error()
+	// Paper out or an open cover would swallow the job silently
+	if err := s.waitUntilReady(&job, settings); err != nil {
+		fmt.Printf("[Job %s] PRINT FAILED: %v\n", jobID, err)
+		job.Status = jobs.StatusFailed
+		job.Error = err.Error()
+		s.notifyError("Print Failed", fmt.Sprintf("Failed to print on %s: %v", targetPrinterName, err), "", true)
+		return
+	}
+
+	adapter := printer.NewEscposAdapterWithSettings(settings)
+	receipt.Render(adapter, tmpl, order)
+
+	bytesToPrint := adapter.GetBytes()
+	fmt.Printf("[Job %s] Generic ESC/POS bytes generated (%d bytes)\n", jobID, len(bytesToPrint))
+	s.store.SetOutput(jobID, jobs.Output{
+		Data:     bytesToPrint,
+		DotWidth: adapter.Layout().DotWidth,
+		CodePage: settings.CodePage,
+	})
+
+	queueJobID, err := s.Send(targetPrinterName, bytesToPrint)
+	job.QueueJobID = queueJobID
+	if err != nil {
+		fmt.Printf("[Job %s] PRINT FAILED: %v\n", jobID, err)
+		job.Status = jobs.StatusFailed
+		job.Error = err.Error()
+
+		s.notifyError("Print Failed", fmt.Sprintf("Failed to print on %s: %v", targetPrinterName, err), "", true)
+	} else {
+		fmt.Printf("[Job %s] PRINT SUCCESS\n", jobID)
+		job.Status = jobs.StatusSuccess
+	}
+
+	// The drawer kick travels with the job, so it shares the print result.
+	// Only a template that sent one counts, whatever the order asked for.
+	if adapter.DrawerKicks() > 0 {
+		s.store.AddJob(jobs.PrintJob{
+			ID:          uuid.New().String(),
+			InvoiceNo:   job.InvoiceNo,
+			PrinterName: targetPrinterName,
+			ReceiptType: jobs.TypeDrawer,
+			Timestamp:   time.Now(),
+			Status:      job.Status,
+			Error:       job.Error,
+			QueueJobID:  job.QueueJobID,
+		})
+	}
+}
+
+// printerSettings returns the configured settings of a printer. Paper
+// configured for the printer wins over the size sent by the client.
+func (s *Server) printerSettings(p printer.PrinterInfo, size string) printer.Settings {
+	settings := s.PrinterConfigs()[p.Name]
+	settings.Profile = p.Profile
+	if settings.Paper == "" {
+		settings.Paper = size
+	}
+	return settings
+}
+
+// Send writes raw bytes to a printer through its OS queue or, for printers
+// reached by address, straight over the network or to the device. It
+// returns the queue's job ID, 0 without a queue.
+func (s *Server) Send(name string, data []byte) (int, error) {
+	t, err := s.transport(name)
+	if err != nil {
+		return 0, err
+	}
+	// Use s.ctx to allow logging to frontend
+	return t.Send(s.ctx, data)
+}
 
-			s.notifyError("Print Failed", fmt.Sprintf("Failed to print on %s: %v", targetPrinterName, err), "", true)
+// transport returns the transport of a printer, keeping connections and devices
+// open between jobs.
+func (s *Server) transport(name string) (printer.Transport, error) {
+	s.transportsMux.Lock()
+	defer s.transportsMux.Unlock()
+	if t, ok := s.transports[name]; ok {
+		return t, nil
+	}
+	settings := s.PrinterConfigs()[name]
+	if settings.Address == "" {
+		// A device found on this machine but not named in config
+		s.printersMux.RLock()
+		settings.Address = s.printers[name].Address
+		s.printersMux.RUnlock()
+	}
+	t, err := printer.NewTransport(name, settings)
+	if err != nil {
+		return nil, err
+	}
+	s.transports[name] = t
+	return t, nil
+}
+
+type DrawerRequest struct {
+	MachineID   string `json:"machineId"`
+	PrinterName string `json:"printerName"`
+	Role        string `json:"role,omitempty"` // Configured role of the printer, instead of PrinterName
+	Pin         int    `json:"pin"`            // 2 (default) or 5
+	OnMs        int    `json:"onMs"`           // Pulse on time, 0 for the default
+	OffMs       int    `json:"offMs"`          // Pulse off time, 0 for the default
+	Beep        bool   `json:"beep"`           // Also sound the buzzer
+	Reason      string `json:"reason"`
+}
+
+// handleDrawer opens the cash drawer wired to a receipt printer.
+func (s *Server) handleDrawer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req DrawerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	storedMachineID, err := config.GetMachineID()
+	if err == nil && req.MachineID != storedMachineID {
+		fmt.Printf("Drawer request validation failed: Invalid Machine ID\n")
+		http.Error(w, "Invalid Machine ID", http.StatusUnauthorized)
+		return
+	}
+
+	target := req.PrinterName
+	if req.Role != "" {
+		target = req.Role
+	}
+	selectedPrinter, err := s.resolvePrinter(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Recorded for audit, with the reason in place of an invoice number
+	job := jobs.PrintJob{
+		ID:          uuid.New().String(),
+		InvoiceNo:   req.Reason,
+		PrinterName: selectedPrinter.Name,
+		ReceiptType: jobs.TypeDrawer,
+		Timestamp:   time.Now(),
+	}
+
+	settings := s.PrinterConfigs()[selectedPrinter.Name]
+	settings.Profile = selectedPrinter.Profile
+	adapter := printer.NewEscposAdapterWithSettings(settings)
+	adapter.OpenCashDrawer(req.Pin, req.OnMs, req.OffMs)
+	var warnings []string
+	if req.Beep {
+		if adapter.HasBuzzer() {
+			adapter.Beep(1, 0)
 		} else {
-			fmt.Printf("[Job %s] PRINT SUCCESS\n", jobID)
-			job.Status = jobs.StatusSuccess
+			msg := fmt.Sprintf("Beep not supported: profile %s has no buzzer", adapter.Profile().Name)
+			fmt.Printf("Warning: %s\n", msg)
+			warnings = append(warnings, msg)
 		}
-	}()
+	}
+
+	resp := PrintResponse{Success: true, JobID: job.ID, Message: "Cash drawer opened", Warnings: warnings}
+	queueJobID, err := s.Send(selectedPrinter.Name, adapter.GetBytes())
+	job.QueueJobID = queueJobID
+	if err != nil {
+		fmt.Printf("[Job %s] DRAWER FAILED: %v\n", job.ID, err)
+		job.Status = jobs.StatusFailed
+		job.Error = err.Error()
+		resp = PrintResponse{Success: false, JobID: job.ID, Error: err.Error(), Warnings: warnings}
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		job.Status = jobs.StatusSuccess
+	}
+	s.store.AddJob(job)
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (s *Server) handleGetPrinters(w http.ResponseWriter, r *http.Request) {
@@ -374,16 +616,20 @@ func (s *Server) handleGetPrinters(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.RefreshStatus()
+	roles := s.Roles()
+
 	s.printersMux.RLock()
 	defer s.printersMux.RUnlock()
 
 	var printerList []printer.PrinterInfo
 	for _, p := range s.printers {
-		printerList = append(printerList, p)
+		printerList = append(printerList, WithRoles(p, roles))
 	}
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"printers": printerList,
+		"roles":    roles,
 	})
 }
 
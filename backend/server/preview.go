@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"ts-escpos/backend/config"
+	"ts-escpos/backend/printer"
+	"ts-escpos/backend/printer/emulator"
+	"ts-escpos/backend/receipt"
+)
+
+// handlePreview renders a print request to a PNG of the paper instead of
+// printing it. The printer's settings are used when it is known; otherwise
+// the receipt is laid out for printerSize with the generic profile.
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PrintRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	storedMachineID, err := config.GetMachineID()
+	if err == nil && req.MachineID != storedMachineID {
+		fmt.Printf("Preview request validation failed: Invalid Machine ID\n")
+		http.Error(w, "Invalid Machine ID", http.StatusUnauthorized)
+		return
+	}
+
+	tmpl, err := s.template(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.writePreview(w, req, tmpl)
+}
+
+// writePreview renders a request with a template and sends it back: as a PNG
+// of the printed paper, or as the document its outputFormat asks for.
+func (s *Server) writePreview(w http.ResponseWriter, req PrintRequest, tmpl *receipt.Template) {
+	if isDocumentFormat(req.OutputFormat) {
+		s.writeDocument(w, req, tmpl)
+		return
+	}
+
+	selectedPrinter, exists := s.lookupPrinter(req.target())
+
+	settings := printer.Settings{Paper: req.PrinterSize}
+	if exists {
+		settings = s.printerSettings(selectedPrinter, req.PrinterSize)
+	}
+
+	adapter := printer.NewEscposAdapterWithSettings(settings)
+	receipt.Render(adapter, tmpl, req.OrderData)
+
+	var png bytes.Buffer
+	err := emulator.RenderPNG(&png, adapter.GetBytes(), emulator.Options{
+		DotWidth: adapter.Layout().DotWidth,
+		CodePage: settings.CodePage,
+	})
+	if err != nil {
+		fmt.Printf("Preview failed: %v\n", err)
+		http.Error(w, fmt.Sprintf("Failed to render preview: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png.Bytes())
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"ts-escpos/backend/printer"
+)
+
+// RoleInfo is a configured role and the printer it prints to now.
+type RoleInfo struct {
+	Name     string `json:"name"`
+	Printer  string `json:"printer"`
+	Fallback string `json:"fallback,omitempty"`
+	Target   string `json:"target,omitempty"`  // Printer or fallback, whichever is available; empty when neither is listed
+	Default  bool   `json:"default,omitempty"` // The default role
+}
+
+// Roles returns the configured roles with their resolved printers, by name.
+func (s *Server) Roles() []RoleInfo {
+	list := make([]RoleInfo, 0, len(s.config.Roles))
+	for name, role := range s.config.Roles {
+		info := RoleInfo{
+			Name:     name,
+			Printer:  role.Printer,
+			Fallback: role.Fallback,
+			Default:  name == s.config.DefaultRole,
+		}
+		if p, ok := s.lookupPrinter(name); ok {
+			info.Target = p.Name
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// WithRoles lists on a printer the roles that resolve to it.
+func WithRoles(p printer.PrinterInfo, roles []RoleInfo) printer.PrinterInfo {
+	p.Roles = nil
+	for _, r := range roles {
+		if r.Target == p.Name {
+			p.Roles = append(p.Roles, r.Name)
+		}
+	}
+	return p
+}
+
+// lookupPrinter finds a printer in the cache by role or by name. A role
+// resolves to its printer, or to its fallback while that is missing,
+// offline, paused or reporting a problem. When neither is available the
+// role keeps its printer, if listed.
+func (s *Server) lookupPrinter(name string) (printer.PrinterInfo, bool) {
+	s.printersMux.RLock()
+	defer s.printersMux.RUnlock()
+	if role, ok := s.config.Roles[name]; ok {
+		var listed []printer.PrinterInfo
+		for _, p := range []string{role.Printer, role.Fallback} {
+			if info, ok := s.printers[p]; ok && p != "" {
+				if available(info) {
+					return info, true
+				}
+				listed = append(listed, info)
+			}
+		}
+		if len(listed) > 0 {
+			return listed[0], true
+		}
+		return printer.PrinterInfo{}, false
+	}
+	p, ok := s.printers[name]
+	return p, ok
+}
+
+// available reports whether a printer can take a job now: its queue is not
+// offline or paused and the live status it last reported, if any, is ready.
+// A status that could not be read counts as not ready.
+func available(p printer.PrinterInfo) bool {
+	status := strings.ToLower(p.Status)
+	for _, s := range []string{"offline", "paused", "not available"} {
+		if strings.Contains(status, s) {
+			return false
+		}
+	}
+	return p.DeviceStatus == nil || p.DeviceStatus.Ready()
+}
+
+// resolvePrinter finds the printer for a role or printer name, refreshing
+// the cache once if needed. A name that is neither prints to the default
+// role when one is configured.
+func (s *Server) resolvePrinter(name string) (printer.PrinterInfo, error) {
+	// A role's printer that was not ready when last asked may be again
+	if role, ok := s.config.Roles[name]; ok {
+		var recheck []string
+		for _, p := range []string{role.Printer, role.Fallback} {
+			if st, ok := s.DeviceStatus(p); ok && !st.Ready() {
+				recheck = append(recheck, p)
+			}
+		}
+		s.refreshStatus(recheck...)
+	}
+
+	if p, ok := s.lookupPrinter(name); ok {
+		return p, nil
+	}
+
+	// If printer not found, refresh the cache and try again
+	fmt.Printf("Printer '%s' not found in cache. Refreshing printer list...\n", name)
+	s.refreshPrinters()
+	if p, ok := s.lookupPrinter(name); ok {
+		return p, nil
+	}
+
+	// A role has its own fallback; only unknown names go to the default role
+	if role, ok := s.config.Roles[name]; ok {
+		return printer.PrinterInfo{}, fmt.Errorf("role '%s': printer '%s' is not available", name, role.Printer)
+	}
+	def := s.config.DefaultRole
+	if def != "" {
+		if p, ok := s.lookupPrinter(def); ok {
+			fmt.Printf("Printer '%s' still not found. Using default role '%s': '%s'\n", name, def, p.Name)
+			return p, nil
+		}
+	}
+	reason := "no default role configured"
+	if def != "" {
+		reason = fmt.Sprintf("default role '%s' has no available printer", def)
+	}
+	if name == "" {
+		return printer.PrinterInfo{}, fmt.Errorf("no printer or role given and %s", reason)
+	}
+	return printer.PrinterInfo{}, fmt.Errorf("printer '%s' not found and %s", name, reason)
+}
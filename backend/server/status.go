@@ -0,0 +1,199 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"ts-escpos/backend/jobs"
+	"ts-escpos/backend/printer"
+)
+
+const (
+	statusMaxAge     = 2 * time.Second
+	statusRetryDelay = 10 * time.Second
+	holdPollInterval = 2 * time.Second
+)
+
+// startStatusMonitors watches the printers with Automatic Status Back
+// enabled. The others are asked for their status only when it is needed, so
+// no connection to them is left open.
+func (s *Server) startStatusMonitors() {
+	for name, settings := range s.PrinterConfigs() {
+		if settings.StatusAddress != "" && settings.StatusASB {
+			go s.watchStatus(name, settings.StatusAddress)
+		}
+	}
+}
+
+// statusQuery returns how to ask a printer for its status, nil when it has
+// no status channel.
+func (s *Server) statusQuery(name string, settings printer.Settings) func() (printer.DeviceStatus, error) {
+	if settings.StatusAddress != "" {
+		return func() (printer.DeviceStatus, error) {
+			return printer.QueryStatus(settings.StatusAddress)
+		}
+	}
+	if settings.Address != "" {
+		t, err := s.transport(name)
+		if err != nil {
+			fmt.Printf("[Status] %s: %v\n", name, err)
+			return nil
+		}
+		return t.Status
+	}
+	return nil
+}
+
+// RefreshStatus asks every polled printer for its status, skipping those
+// checked within the last statusMaxAge.
+func (s *Server) RefreshStatus() {
+	s.refreshStatus(slices.Collect(maps.Keys(s.PrinterConfigs()))...)
+}
+
+// refreshStatus asks the named printers for their status, skipping ASB
+// printers and those checked within the last statusMaxAge.
+func (s *Server) refreshStatus(names ...string) {
+	var wg sync.WaitGroup
+	for _, name := range names {
+		settings, ok := s.PrinterConfigs()[name]
+		if !ok || (settings.StatusAddress != "" && settings.StatusASB) {
+			continue
+		}
+		if st, ok := s.DeviceStatus(name); ok && time.Since(st.CheckedAt) < statusMaxAge {
+			continue
+		}
+		query := s.statusQuery(name, settings)
+		if query == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			st, err := query()
+			if err != nil {
+				fmt.Printf("[Status] %s: %v\n", name, err)
+				st = printer.DeviceStatus{CheckedAt: time.Now(), Errors: []string{"no status reply"}, Stale: true}
+			}
+			s.setDeviceStatus(name, st)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Server) watchStatus(name, addr string) {
+	for {
+		err := printer.WatchStatus(addr, nil, func(st printer.DeviceStatus) {
+			s.setDeviceStatus(name, st)
+		})
+		fmt.Printf("[Status] %s: status channel closed: %v. Reconnecting in %v\n", name, err, statusRetryDelay)
+		s.setDeviceStatus(name, printer.DeviceStatus{CheckedAt: time.Now(), Errors: []string{"status channel lost"}, Stale: true})
+		time.Sleep(statusRetryDelay)
+	}
+}
+
+// setDeviceStatus records a printer's status and pushes changes to clients.
+func (s *Server) setDeviceStatus(name string, st printer.DeviceStatus) {
+	s.printersMux.Lock()
+	old, known := s.deviceStatus[name]
+	s.deviceStatus[name] = st
+	if p, ok := s.printers[name]; ok {
+		p.SetDeviceStatus(st)
+		s.printers[name] = p
+	}
+	s.printersMux.Unlock()
+
+	if known && sameStatus(old, st) {
+		return
+	}
+	fmt.Printf("[Status] %s: online=%v paperLow=%v paperOut=%v coverOpen=%v drawerOpen=%v errors=%v\n",
+		name, st.Online, st.PaperLow, st.PaperOut, st.CoverOpen, st.DrawerOpen, st.Errors)
+
+	msg := map[string]interface{}{"type": "printer_status", "printer": name, "status": st}
+	s.broadcast(msg)
+	if s.ctx != nil {
+		runtime.EventsEmit(s.ctx, "printer_status", msg)
+	}
+}
+
+func sameStatus(a, b printer.DeviceStatus) bool {
+	return a.Online == b.Online && a.PaperLow == b.PaperLow && a.PaperOut == b.PaperOut &&
+		a.CoverOpen == b.CoverOpen && a.DrawerOpen == b.DrawerOpen && slices.Equal(a.Errors, b.Errors)
+}
+
+// DeviceStatus returns the last live status reported by a printer.
+func (s *Server) DeviceStatus(name string) (printer.DeviceStatus, bool) {
+	s.printersMux.RLock()
+	defer s.printersMux.RUnlock()
+	st, ok := s.deviceStatus[name]
+	return st, ok
+}
+
+// currentStatus returns the printer's live status. Polled printers are asked
+// again so the answer is fresh; ASB printers report changes on their own.
+// A status that could not be read is not known, so it does not count.
+func (s *Server) currentStatus(name string, settings printer.Settings) (printer.DeviceStatus, bool) {
+	if settings.StatusAddress != "" && settings.StatusASB {
+		st, ok := s.DeviceStatus(name)
+		return st, ok && !st.Stale
+	}
+	query := s.statusQuery(name, settings)
+	if query == nil {
+		return printer.DeviceStatus{}, false
+	}
+	st, err := query()
+	if err != nil {
+		fmt.Printf("[Status] %s: %v\n", name, err)
+		return printer.DeviceStatus{}, false
+	}
+	s.setDeviceStatus(name, st)
+	return st, true
+}
+
+// waitUntilReady checks the printer's live status before a job is sent. A
+// printer reporting a problem holds the job for the configured time, then
+// fails it. Printers without a status channel, or whose status cannot be
+// read, are assumed ready.
+func (s *Server) waitUntilReady(job *jobs.PrintJob, settings printer.Settings) error {
+	st, ok := s.currentStatus(job.PrinterName, settings)
+	if !ok || st.Ready() {
+		return nil
+	}
+
+	if settings.HoldSeconds <= 0 {
+		return fmt.Errorf("printer not ready: %s", st.Problem())
+	}
+
+	fmt.Printf("[Job %s] Holding: printer %s reports %s\n", job.ID, job.PrinterName, st.Problem())
+	job.Status = jobs.StatusHeld
+	job.Error = st.Problem()
+	s.store.AddJob(*job)
+	s.notifyError("Print Job Held", fmt.Sprintf("%s: %s", job.PrinterName, st.Problem()), "", true)
+
+	deadline := time.Now().Add(time.Duration(settings.HoldSeconds) * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(holdPollInterval)
+		if st, ok = s.currentStatus(job.PrinterName, settings); !ok || st.Ready() {
+			job.Status = jobs.StatusProcessing
+			job.Error = ""
+			s.store.AddJob(*job)
+			return nil
+		}
+	}
+	return fmt.Errorf("printer not ready after %ds: %s", settings.HoldSeconds, st.Problem())
+}
+
+// broadcast sends a message to every WebSocket client.
+func (s *Server) broadcast(msg interface{}) {
+	s.clientsMux.Lock()
+	defer s.clientsMux.Unlock()
+	for ws := range s.clients {
+		if err := ws.WriteJSON(msg); err != nil {
+			fmt.Printf("WebSocket write failed: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ts-escpos/backend/config"
+	"ts-escpos/backend/printer"
+	"ts-escpos/backend/receipt"
+	"ts-escpos/backend/templates"
+)
+
+// TemplateRequest saves or rolls back a template. Template is the layout as
+// an object or as JSON/YAML source in a string.
+type TemplateRequest struct {
+	MachineID string          `json:"machineId"`
+	Template  json.RawMessage `json:"template,omitempty"`
+	Version   int             `json:"version,omitempty"` // rollback: the version to restore
+}
+
+// TemplateResponse is a template with its saved version.
+type TemplateResponse struct {
+	ID       string            `json:"id"`
+	Builtin  bool              `json:"builtin"`
+	Version  int               `json:"version,omitempty"`
+	SavedAt  *time.Time        `json:"savedAt,omitempty"`
+	Source   string            `json:"source,omitempty"` // As it was saved, comments included
+	Template *receipt.Template `json:"template"`
+}
+
+func (s *Server) registerTemplateRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/templates", s.handleListTemplates)
+	mux.HandleFunc("GET /api/templates/{id}", s.handleGetTemplate)
+	mux.HandleFunc("PUT /api/templates/{id}", s.handleSaveTemplate)
+	mux.HandleFunc("DELETE /api/templates/{id}", s.handleDeleteTemplate)
+	mux.HandleFunc("GET /api/templates/{id}/versions", s.handleTemplateVersions)
+	mux.HandleFunc("POST /api/templates/{id}/rollback", s.handleRollbackTemplate)
+	mux.HandleFunc("GET /api/templates/{id}/preview", s.handlePreviewTemplate)
+	mux.HandleFunc("POST /api/templates/{id}/preview", s.handlePreviewTemplate)
+}
+
+// template returns the layout a request asks for: an inline template, a
+// template by ID (custom or built-in) or the one for its receipt type.
+func (s *Server) template(req PrintRequest) (*receipt.Template, error) {
+	if req.Template != nil {
+		return req.Template, nil
+	}
+	id := req.TemplateID
+	if id == "" {
+		id = receipt.TemplateBill
+		if req.ReceiptType == "kot" {
+			id = receipt.TemplateKOT
+		}
+	}
+	return s.templates.Lookup(id)
+}
+
+func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	list, err := s.templates.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"templates": list,
+	})
+}
+
+// handleGetTemplate returns the current version of a template, or the one
+// given by ?version=. Built-in templates are returned when none is saved.
+func (s *Server) handleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	version, _ := strconv.Atoi(r.URL.Query().Get("version"))
+
+	t, src, v, err := s.templates.Get(id, version)
+	if errors.Is(err, templates.ErrNotFound) && version == 0 {
+		builtin, lerr := receipt.LookupTemplate(id)
+		if lerr == nil {
+			json.NewEncoder(w).Encode(TemplateResponse{ID: builtin.ID, Builtin: true, Template: builtin})
+			return
+		}
+	}
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(TemplateResponse{
+		ID:       t.ID,
+		Version:  v.Version,
+		SavedAt:  &v.SavedAt,
+		Source:   string(src),
+		Template: t,
+	})
+}
+
+// handleSaveTemplate validates a template and saves it as a new version.
+func (s *Server) handleSaveTemplate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeTemplateRequest(w, r, true)
+	if !ok {
+		return
+	}
+	src, err := templates.Source(req.Template)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	v, err := s.templates.Save(id, src)
+	if err != nil {
+		fmt.Printf("Template %s not saved: %v\n", id, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fmt.Printf("Template %s saved as version %d\n", id, v.Version)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if _, ok := decodeTemplateRequest(w, r, false); !ok {
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.templates.Delete(id); err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+	fmt.Printf("Template %s deleted\n", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleTemplateVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := s.templates.Versions(r.PathValue("id"))
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"versions": versions,
+	})
+}
+
+// handleRollbackTemplate saves an earlier version again as the latest one.
+func (s *Server) handleRollbackTemplate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeTemplateRequest(w, r, false)
+	if !ok {
+		return
+	}
+	if req.Version <= 0 {
+		http.Error(w, "version is required", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	v, err := s.templates.Rollback(id, req.Version)
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+	fmt.Printf("Template %s rolled back to version %d as version %d\n", id, req.Version, v.Version)
+	json.NewEncoder(w).Encode(v)
+}
+
+// handlePreviewTemplate renders a template with the sample order. GET uses
+// the saved template (?version=, ?format=png|text|html|pdf, ?printerSize=,
+// ?printerName=); POST previews an unsaved draft sent like a save.
+func (s *Server) handlePreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	q := r.URL.Query()
+	version, _ := strconv.Atoi(q.Get("version"))
+
+	var tmpl *receipt.Template
+	if r.Method == http.MethodPost {
+		req, ok := decodeTemplateRequest(w, r, true)
+		if !ok {
+			return
+		}
+		src, err := templates.Source(req.Template)
+		if err == nil {
+			tmpl, err = receipt.ParseTemplate(src)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		var err error
+		if version != 0 {
+			tmpl, _, _, err = s.templates.Get(id, version)
+		} else {
+			tmpl, err = s.templates.Lookup(id)
+		}
+		if err != nil {
+			writeTemplateError(w, err)
+			return
+		}
+	}
+
+	size := q.Get("printerSize")
+	if size == "" {
+		size = "80mm"
+	}
+	format := q.Get("format")
+	if format == "png" {
+		format = printer.FormatESCPOS
+	}
+	s.writePreview(w, PrintRequest{
+		PrinterName:  q.Get("printerName"),
+		PrinterSize:  size,
+		OutputFormat: format,
+		OrderData:    receipt.GetSampleOrderData(),
+	}, tmpl)
+}
+
+// decodeTemplateRequest reads the body of a template change and checks the
+// machine ID; withTemplate requires the body to carry a template.
+func decodeTemplateRequest(w http.ResponseWriter, r *http.Request, withTemplate bool) (TemplateRequest, bool) {
+	var req TemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	storedMachineID, err := config.GetMachineID()
+	if err == nil && req.MachineID != storedMachineID {
+		fmt.Printf("Template request validation failed: Invalid Machine ID\n")
+		http.Error(w, "Invalid Machine ID", http.StatusUnauthorized)
+		return req, false
+	}
+	if withTemplate && len(req.Template) == 0 {
+		http.Error(w, "template is required", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+func writeTemplateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, templates.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
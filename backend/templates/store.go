@@ -0,0 +1,292 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ts-escpos/backend/receipt"
+)
+
+// MaxVersions is the number of saved versions kept per template.
+const MaxVersions = 20
+
+// ErrNotFound is returned for a template or version that does not exist.
+var ErrNotFound = errors.New("template not found")
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Version describes one saved revision of a template.
+type Version struct {
+	Version int       `json:"version"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+// Info is a template as listed by the API.
+type Info struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Builtin     bool       `json:"builtin"` // Shipped with the app
+	Custom      bool       `json:"custom"`  // Saved on this machine, replacing a built-in of the same ID
+	Version     int        `json:"version,omitempty"`
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty"`
+}
+
+// Store keeps custom templates in a directory, one folder per template with
+// every saved version as "<n>.yaml". The source is stored as it was sent, so
+// comments and key order survive; a rollback saves an old version again.
+type Store struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("Warning: failed to create template dir: %v\n", err)
+	}
+	return &Store{dir: dir}
+}
+
+// ValidID reports whether id can name a stored template.
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// Lookup returns the current version of a custom template, or the built-in
+// template with that ID.
+func (s *Store) Lookup(id string) (*receipt.Template, error) {
+	id = strings.ToLower(id)
+	if ValidID(id) {
+		t, _, _, err := s.Get(id, 0)
+		if err == nil {
+			return t, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return receipt.LookupTemplate(id)
+}
+
+// Get returns a saved version of a template with its source; version 0 is
+// the latest.
+func (s *Store) Get(id string, version int) (*receipt.Template, []byte, Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions, err := s.versions(id)
+	if err != nil {
+		return nil, nil, Version{}, err
+	}
+	if len(versions) == 0 {
+		return nil, nil, Version{}, ErrNotFound
+	}
+	v := versions[len(versions)-1]
+	if version != 0 {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].Version >= version })
+		if i == len(versions) || versions[i].Version != version {
+			return nil, nil, Version{}, ErrNotFound
+		}
+		v = versions[i]
+	}
+
+	src, err := os.ReadFile(s.file(id, v.Version))
+	if err != nil {
+		return nil, nil, Version{}, err
+	}
+	t, err := parse(id, src)
+	if err != nil {
+		return nil, nil, Version{}, fmt.Errorf("stored template %s version %d: %w", id, v.Version, err)
+	}
+	return t, src, v, nil
+}
+
+// Versions lists the saved versions of a template, oldest first.
+func (s *Store) Versions(id string) ([]Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, err := s.versions(id)
+	if err == nil && len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	return versions, err
+}
+
+// Save validates a template source and stores it as a new version.
+func (s *Store) Save(id string, src []byte) (Version, error) {
+	if !ValidID(id) {
+		return Version{}, fmt.Errorf("invalid template ID %q: use lowercase letters, digits, - and _", id)
+	}
+	if _, err := parse(id, src); err != nil {
+		return Version{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(id, src)
+}
+
+// Rollback saves an old version again as the latest one.
+func (s *Store) Rollback(id string, version int) (Version, error) {
+	_, src, _, err := s.Get(id, version)
+	if err != nil {
+		return Version{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(id, src)
+}
+
+// Delete removes every version of a template. A built-in template of the
+// same ID is used again afterwards.
+func (s *Store) Delete(id string) error {
+	if !ValidID(id) {
+		return ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(filepath.Join(s.dir, id)); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return os.RemoveAll(filepath.Join(s.dir, id))
+}
+
+// List returns the built-in and custom templates, by ID.
+func (s *Store) List() ([]Info, error) {
+	byID := make(map[string]Info)
+	for _, t := range receipt.BuiltinTemplates() {
+		byID[t.ID] = Info{ID: t.ID, Name: t.Name, Description: t.Description, Builtin: true}
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !ValidID(e.Name()) {
+			continue
+		}
+		t, _, v, err := s.Get(e.Name(), 0)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			continue
+		}
+		info := byID[t.ID]
+		info.ID, info.Name, info.Description = t.ID, t.Name, t.Description
+		info.Custom = true
+		info.Version = v.Version
+		savedAt := v.SavedAt
+		info.UpdatedAt = &savedAt
+		byID[t.ID] = info
+	}
+
+	list := make([]Info, 0, len(byID))
+	for _, info := range byID {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (s *Store) save(id string, src []byte) (Version, error) {
+	versions, err := s.versions(id)
+	if err != nil {
+		return Version{}, err
+	}
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1].Version + 1
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.dir, id), 0755); err != nil {
+		return Version{}, err
+	}
+	if err := os.WriteFile(s.file(id, next), src, 0644); err != nil {
+		return Version{}, err
+	}
+
+	// Drop the oldest versions beyond MaxVersions
+	for len(versions)+1 > MaxVersions {
+		os.Remove(s.file(id, versions[0].Version))
+		versions = versions[1:]
+	}
+	return Version{Version: next, SavedAt: time.Now()}, nil
+}
+
+// versions lists the saved versions of a template, oldest first.
+func (s *Store) versions(id string) ([]Version, error) {
+	if !ValidID(id) {
+		return nil, ErrNotFound
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	for _, e := range entries {
+		n, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".yaml"))
+		if err != nil || e.IsDir() || !strings.HasSuffix(e.Name(), ".yaml") {
+			continue
+		}
+		v := Version{Version: n}
+		if fi, err := e.Info(); err == nil {
+			v.SavedAt = fi.ModTime()
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+func (s *Store) file(id string, version int) string {
+	return filepath.Join(s.dir, id, strconv.Itoa(version)+".yaml")
+}
+
+// parse reads a stored source, which takes its ID from where it is saved.
+func parse(id string, src []byte) (*receipt.Template, error) {
+	t, err := receipt.ParseTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+	if t.ID != "" && t.ID != id {
+		return nil, fmt.Errorf("template ID %q does not match %q", t.ID, id)
+	}
+	t.ID = id
+	return t, nil
+}
+
+// Source returns a template sent in a JSON request, which may be an object
+// or its JSON/YAML source as a string, as the bytes to store.
+func Source(raw json.RawMessage) ([]byte, error) {
+	var src string
+	if err := json.Unmarshal(raw, &src); err == nil {
+		return []byte(src), nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("template must be an object or a string")
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, raw, "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
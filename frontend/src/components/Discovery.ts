@@ -0,0 +1,121 @@
+import { DiscoverPrinters, AddDiscoveredPrinter } from '../../wailsjs/go/main/App';
+
+export interface DiscoveredDevice {
+    host: string;
+    address?: string; // tcp://host:9100, present when the printer answered as ESC/POS on its raw port
+    ports: number[];
+    name?: string; // Announced over mDNS
+    model?: string;
+    escpos: boolean; // Answered an ESC/POS status query
+    sources: string[];
+    configuredAs?: string; // Name of the configured printer at this address
+}
+
+export class Discovery {
+    private element: HTMLElement;
+    private devices: DiscoveredDevice[] | null = null;
+    private scanning = false;
+    private onAdded: () => void;
+
+    constructor(onAdded: () => void) {
+        this.onAdded = onAdded;
+        this.element = document.createElement('div');
+        this.element.className = "px-6 pb-6";
+        this.render();
+    }
+
+    private async scan() {
+        this.scanning = true;
+        this.render();
+        try {
+            this.devices = (await DiscoverPrinters()) || [];
+        } catch (err) {
+            console.error("Discovery failed", err);
+            alert("Discovery failed: " + err);
+        } finally {
+            this.scanning = false;
+            this.render();
+        }
+    }
+
+    private async add(device: DiscoveredDevice) {
+        const suggested = (device.name || device.model || device.host).replace(/\s+/g, '_');
+        const name = prompt(`Name for the printer at ${device.host}:`, suggested);
+        if (!name) return;
+        try {
+            await AddDiscoveredPrinter(name, device.address!);
+            device.configuredAs = name;
+            this.render();
+            this.onAdded();
+        } catch (err) {
+            console.error("Failed to add printer", err);
+            alert("Failed to add printer: " + err);
+        }
+    }
+
+    render() {
+        this.element.innerHTML = `
+            <div class="flex items-center justify-between mb-4">
+                <h2 class="text-xl font-bold flex items-center gap-2">
+                    Network Discovery
+                    ${this.devices ? `<span class="text-sm font-normal text-gray-500 bg-gray-800 px-2 py-0.5 rounded-full">${this.devices.length}</span>` : ''}
+                </h2>
+                <button class="scan-btn py-2 px-3 bg-blue-600 hover:bg-blue-700 disabled:opacity-50 text-white rounded-lg text-sm font-medium transition-colors flex items-center gap-2" ${this.scanning ? 'disabled' : ''}>
+                    ${this.scanning ? `<svg class="animate-spin h-4 w-4 text-white" xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24"><circle class="opacity-25" cx="12" cy="12" r="10" stroke="currentColor" stroke-width="4"></circle><path class="opacity-75" fill="currentColor" d="M4 12a8 8 0 018-8V0C5.373 0 0 5.373 0 12h4zm2 5.291A7.962 7.962 0 014 12H0c0 3.042 1.135 5.824 3 7.938l3-2.647z"></path></svg> Scanning...` : 'Find Network Printers'}
+                </button>
+            </div>
+        `;
+        const scanBtn = this.element.querySelector('.scan-btn') as HTMLButtonElement;
+        scanBtn.onclick = () => this.scan();
+
+        if (!this.devices) {
+            return;
+        }
+        if (this.devices.length === 0) {
+            this.element.insertAdjacentHTML('beforeend', `
+                <div class="text-center py-6 text-gray-500 bg-gray-800/50 rounded-xl border border-dashed border-gray-700">
+                    <p>No printers found on the network.</p>
+                </div>
+            `);
+        } else {
+            const list = document.createElement('div');
+            list.className = "bg-gray-800 rounded-xl border border-gray-700 divide-y divide-gray-700";
+            this.devices.forEach(device => {
+                const row = document.createElement('div');
+                row.className = "flex items-center gap-3 p-3 text-sm";
+                row.innerHTML = `
+                    <div class="flex-1 min-w-0">
+                        <div class="flex items-center gap-2">
+                            <span class="device-host font-mono text-white"></span>
+                            ${device.escpos ? `<span class="uppercase tracking-wider font-bold text-[10px] px-1.5 py-0.5 rounded bg-green-900/60 text-green-300">ESC/POS</span>` : ''}
+                        </div>
+                        <div class="device-info text-xs text-gray-400 truncate"></div>
+                    </div>
+                    ${device.configuredAs
+                        ? `<span class="text-xs text-gray-400">Added as <span class="configured-as text-gray-200"></span></span>`
+                        : device.address
+                            ? `<button class="add-btn py-1.5 px-3 bg-gray-700 hover:bg-gray-600 text-white rounded-lg text-xs font-medium transition-colors">Add</button>`
+                            : device.ports.includes(9100)
+                                ? `<span class="text-xs text-gray-500" title="Only printers that answer an ESC/POS status query can be added">Not ESC/POS</span>`
+                                : `<span class="text-xs text-gray-500" title="Only printers with a raw port (9100) can be added">No raw port</span>`}
+                `;
+                // Names and models come from the network; set them as text, never as HTML
+                (row.querySelector('.device-host') as HTMLElement).textContent = device.host;
+                (row.querySelector('.device-info') as HTMLElement).textContent =
+                    `${[device.name, device.model].filter(Boolean).join(' · ') || 'Unknown model'} · ports ${device.ports.join(', ')}`;
+                const configuredEl = row.querySelector('.configured-as');
+                if (configuredEl) configuredEl.textContent = device.configuredAs!;
+                const addBtn = row.querySelector('.add-btn') as HTMLButtonElement;
+                if (addBtn) {
+                    addBtn.onclick = () => this.add(device);
+                }
+                list.appendChild(row);
+            });
+            this.element.appendChild(list);
+        }
+    }
+
+    getElement(): HTMLElement {
+        return this.element;
+    }
+}
@@ -1,3 +1,5 @@
+import { GetJobPreview } from '../../wailsjs/go/main/App';
+
 export interface PrintJob {
     id: string;
     invoiceNo: string;
@@ -6,6 +8,9 @@ export interface PrintJob {
     error?: string;
     timestamp: string;
     receiptType: string;
+    station?: string; // Kitchen station of a routed KOT
+    queueJobId?: number; // ID the print queue gave the job
+    hasPreview?: boolean; // The printed bytes are kept and can be rendered
 }
 
 export class JobsLog {
@@ -65,15 +70,59 @@ export class JobsLog {
                     </div>
                     <div class="flex items-center gap-2 text-xs text-gray-400">
                         <span class="uppercase tracking-wider font-bold text-[10px] px-1.5 py-0.5 rounded bg-gray-700">${job.receiptType}</span>
-                        <span class="truncate">via ${job.printerName}</span>
+                        ${job.station ? `<span class="uppercase tracking-wider font-bold text-[10px] px-1.5 py-0.5 rounded bg-gray-700">${job.station}</span>` : ''}
+                        <span class="truncate">via ${job.printerName}${job.queueJobId ? ` (job ${job.queueJobId})` : ''}</span>
                     </div>
                     ${!isSuccess ? `<div class="text-red-400 text-xs mt-1 truncate">${job.error}</div>` : ''}
                 </div>
+                ${job.hasPreview ? `
+                <button class="preview-btn p-2 rounded-lg text-gray-400 hover:text-white hover:bg-gray-700 transition-colors" title="Preview receipt">
+                    <svg xmlns="http://www.w3.org/2000/svg" class="h-4 w-4" fill="none" viewBox="0 0 24 24" stroke="currentColor">
+                        <path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M15 12a3 3 0 11-6 0 3 3 0 016 0z" />
+                        <path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M2.458 12C3.732 7.943 7.523 5 12 5c4.478 0 8.268 2.943 9.542 7-1.274 4.057-5.064 7-9.542 7-4.477 0-8.268-2.943-9.542-7z" />
+                    </svg>
+                </button>` : ''}
             `;
+
+            const previewBtn = row.querySelector('.preview-btn') as HTMLButtonElement;
+            if (previewBtn) {
+                previewBtn.onclick = () => this.showPreview(job);
+            }
             listContainer.appendChild(row);
         });
     }
 
+    // showPreview renders the bytes sent for a job and shows them as paper.
+    async showPreview(job: PrintJob) {
+        let src: string;
+        try {
+            src = await GetJobPreview(job.id);
+        } catch (err) {
+            alert("Failed to render preview: " + err);
+            return;
+        }
+
+        const overlay = document.createElement('div');
+        overlay.className = "fixed inset-0 z-50 bg-black/70 flex items-center justify-center p-6";
+        overlay.innerHTML = `
+            <div class="bg-gray-800 rounded-xl border border-gray-700 shadow-xl max-h-full flex flex-col">
+                <div class="flex justify-between items-center p-3 border-b border-gray-700">
+                    <h3 class="font-medium text-white text-sm">Inv #${job.invoiceNo} <span class="text-gray-500">via ${job.printerName}</span></h3>
+                    <button class="close-btn text-gray-400 hover:text-white px-2">&times;</button>
+                </div>
+                <div class="overflow-y-auto custom-scrollbar p-4">
+                    <img src="${src}" class="mx-auto bg-white" style="image-rendering: pixelated; max-width: 420px;" alt="Receipt preview" />
+                </div>
+            </div>
+        `;
+        overlay.onclick = (e) => {
+            if (e.target === overlay || (e.target as HTMLElement).classList.contains('close-btn')) {
+                overlay.remove();
+            }
+        };
+        document.body.appendChild(overlay);
+    }
+
     render() {
         this.element.innerHTML = `
             <div class="p-4 border-b border-gray-700 bg-gray-800">
@@ -6,6 +6,18 @@ export interface PrinterInfo {
     uniqueId: string;
     windowsId: string;
     status: string;
+    driver?: string;
+    profile?: string;
+    address?: string; // Network printer reached without an OS queue
+    roles?: string[]; // Configured roles that print to this printer now
+    // Live status, present for printers with a status channel
+    online?: boolean;
+    paperLow?: boolean;
+    paperOut?: boolean;
+    coverOpen?: boolean;
+    drawerOpen?: boolean;
+    errors?: string[];
+    stale?: boolean; // The status could not be read; jobs are not held for it
 }
 
 export class PrinterList {
@@ -40,7 +52,8 @@ export class PrinterList {
             const card = document.createElement('div');
             card.className = "bg-gray-800 rounded-xl p-4 border border-gray-700 shadow-sm hover:shadow-md transition-shadow relative overflow-hidden group";
 
-            const isReady = printer.status.includes("Ready");
+            const problems = this.deviceProblems(printer);
+            const isReady = printer.status.includes("Ready") && problems.length === 0;
             const statusColor = isReady ? "text-green-400" : "text-yellow-400";
             const borderColor = isReady ? "border-green-500/20" : "border-yellow-500/20";
 
@@ -58,10 +71,20 @@ export class PrinterList {
                     </span>
                 </div>
                 <h3 class="font-bold text-lg mb-1 truncate" title="${printer.name}">${printer.name}</h3>
+                ${printer.roles && printer.roles.length > 0 ? `<div class="flex flex-wrap gap-1 mb-2">${printer.roles.map(r => `<span class="text-[10px] font-semibold px-1.5 py-0.5 rounded bg-blue-900/60 text-blue-300">${r}</span>`).join('')}</div>` : ''}
+                ${problems.length > 0 ? `<div class="flex flex-wrap gap-1 mb-2">${problems.map(p => `<span class="text-[10px] font-semibold px-1.5 py-0.5 rounded bg-red-900/60 text-red-300">${p}</span>`).join('')}</div>` : ''}
+                ${printer.paperLow && !printer.paperOut ? `<div class="mb-2"><span class="text-[10px] font-semibold px-1.5 py-0.5 rounded bg-yellow-900/60 text-yellow-300">Paper low</span></div>` : ''}
                 <div class="space-y-1 text-xs text-gray-400 mb-3">
-                    <p class="flex justify-between">
+                    ${printer.address ? `<p class="flex justify-between">
+                        <span>Address:</span>
+                        <span class="font-mono text-gray-300 truncate w-32 text-right" title="${printer.address}">${printer.address.replace('tcp://', '')}</span>
+                    </p>` : `<p class="flex justify-between">
                         <span>Win ID:</span>
                         <span class="font-mono text-gray-300">${printer.windowsId}</span>
+                    </p>`}
+                    <p class="flex justify-between">
+                        <span>Profile:</span>
+                        <span class="font-mono text-gray-300 truncate w-24 text-right" title="${printer.driver || ''}">${printer.profile || 'generic'}</span>
                     </p>
                     <p class="flex justify-between">
                         <span>UID:</span>
@@ -75,11 +98,11 @@ export class PrinterList {
                         </svg>
                         Test
                     </button>
-                    <button class="clear-queue-btn px-3 py-2 bg-red-600 hover:bg-red-700 text-white rounded-lg text-sm font-medium transition-colors border border-red-800" title="Clear Printer Queue">
+                    ${printer.address ? '' : `<button class="clear-queue-btn px-3 py-2 bg-red-600 hover:bg-red-700 text-white rounded-lg text-sm font-medium transition-colors border border-red-800" title="Clear Printer Queue">
                          <svg xmlns="http://www.w3.org/2000/svg" class="h-4 w-4" fill="none" viewBox="0 0 24 24" stroke="currentColor">
                             <path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M19 7l-.867 12.142A2 2 0 0116.138 21H7.862a2 2 0 01-1.995-1.858L5 7m5 4v6m4-6v6m1-10V4a1 1 0 00-1-1h-4a1 1 0 00-1 1v3M4 7h16" />
                         </svg>
-                    </button>
+                    </button>`}
                 </div>
             `;
 
@@ -164,6 +187,21 @@ export class PrinterList {
         this.element.appendChild(grid);
     }
 
+    private deviceProblems(printer: PrinterInfo): string[] {
+        if (printer.online === undefined) {
+            return [];
+        }
+        if (printer.stale) {
+            return printer.errors || ["Status unknown"];
+        }
+        const problems: string[] = [];
+        if (!printer.online) problems.push("Offline");
+        if (printer.paperOut) problems.push("Paper out");
+        if (printer.coverOpen) problems.push("Cover open");
+        (printer.errors || []).forEach(e => problems.push(e));
+        return problems;
+    }
+
     getElement(): HTMLElement {
         return this.element;
     }
@@ -6,6 +6,7 @@ import { Header } from './components/Header';
 import { PrinterList } from './components/PrinterList';
 import { JobsLog } from './components/JobsLog';
 import { SystemLog } from './components/SystemLog';
+import { Discovery } from './components/Discovery';
 
 // We need to declare the window.runtime functions i f typing is not yet generated
 // or rely on @ts-ignore.
@@ -21,6 +22,7 @@ class Dashboard {
     private printerList: PrinterList;
     private jobsLog: JobsLog;
     private systemLog: SystemLog;
+    private discovery: Discovery;
     private machineId: string = "";
 
     constructor() {
@@ -31,6 +33,11 @@ class Dashboard {
         this.printerList = new PrinterList();
         this.jobsLog = new JobsLog();
         this.systemLog = new SystemLog();
+        this.discovery = new Discovery(async () => {
+            if (App) {
+                this.printerList.updatePrinters(await App.GetPrinters());
+            }
+        });
 
         this.setupNotifications();
 
@@ -61,6 +68,14 @@ class Dashboard {
                      alert(`${data.title}: ${data.message}`);
                 }
             });
+
+            // Refresh printer cards when a printer reports paper, cover or error changes
+            // @ts-ignore
+            window.runtime.EventsOn("printer_status", async () => {
+                if (App) {
+                    this.printerList.updatePrinters(await App.GetPrinters());
+                }
+            });
         }
     }
 
@@ -76,6 +91,7 @@ class Dashboard {
         const content = document.createElement('div');
         content.className = "flex-1 overflow-y-auto";
         content.appendChild(this.printerList.getElement());
+        content.appendChild(this.discovery.getElement());
 
         main.appendChild(content);
 
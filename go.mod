@@ -4,8 +4,10 @@ go 1.24.0
 
 require (
 	github.com/blang/semver v3.5.1+incompatible
+	github.com/boombuler/barcode v1.1.0
 	github.com/gen2brain/beeep v0.11.2
 	github.com/getlantern/systray v1.2.2
+	github.com/go-text/typesetting v0.3.5
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
@@ -13,6 +15,8 @@ require (
 	github.com/wailsapp/wails/v2 v2.10.2
 	golang.org/x/image v0.35.0
 	golang.org/x/sys v0.40.0
+	golang.org/x/text v0.33.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -53,7 +57,6 @@ require (
 	github.com/wailsapp/mimetype v1.4.1 // indirect
 	golang.org/x/crypto v0.47.0 // indirect
 	golang.org/x/net v0.49.0 // indirect
-	golang.org/x/text v0.33.0 // indirect
 )
 
 // replace github.com/wailsapp/wails/v2 v2.10.2 => /Users/saurabh/go/pkg/mod